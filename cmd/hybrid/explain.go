@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	ggrpc "google.golang.org/grpc"
+
+	"github.com/empirefox/hybrid/grpc"
+)
+
+// explain prints how the running hybrid routes the url:
+//
+//	HYBRID_GRPC_BIND=127.0.0.1:7778 hybrid explain https://example.com
+func explain(args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: %s explain <url>", os.Args[0])
+	}
+
+	grpcBind := os.Getenv("HYBRID_GRPC_BIND")
+	if grpcBind == "" {
+		log.Fatalf("HYBRID_GRPC_BIND should be set to the grpc address of hybrid")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := ggrpc.DialContext(ctx, grpcBind, ggrpc.WithInsecure(), ggrpc.WithBlock())
+	if err != nil {
+		log.Fatalf("dial %s err: %v", grpcBind, err)
+	}
+	defer conn.Close()
+
+	e, err := grpc.NewHybridClient(conn).ExplainRoute(ctx, &grpc.ExplainRouteRequest{Url: args[0]})
+	if err != nil {
+		log.Fatalf("ExplainRoute: %v", err)
+	}
+
+	for _, step := range e.Steps {
+		name := step.Router
+		if step.Late {
+			name += " (late)"
+		}
+		switch {
+		case step.Skipped != "":
+			fmt.Printf("%s: skipped, %s\n", name, step.Skipped)
+		case step.Proxy == "":
+			fmt.Printf("%s: not routed\n", name)
+		default:
+			fmt.Printf("%s: %s\n", name, step.Proxy)
+		}
+		if step.Rule != "" {
+			fmt.Printf("  rule: %s\n", step.Rule)
+		}
+		if step.RuleFile != "" {
+			fmt.Printf("  file: %s\n", step.RuleFile)
+		}
+	}
+	if e.Router == "" {
+		fmt.Printf("=> %s\n", e.Proxy)
+	} else {
+		fmt.Printf("=> %s by %s\n", e.Proxy, e.Router)
+	}
+}
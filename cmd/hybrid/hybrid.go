@@ -9,6 +9,9 @@
 //
 // ssh over hybrid example:
 // ssh root@192.168.1.1 -o "ProxyCommand=nc -n -Xconnect -x127.0.0.1:7777 %h.over.server_name_in_hybrid_json.hybrid %p"
+//
+// explain the route of url with the running hybrid:
+// HYBRID_GRPC_BIND=127.0.0.1:7778 hybrid explain https://example.com
 package main
 
 import (
@@ -27,6 +30,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		explain(os.Args[2:])
+		return
+	}
+
 	err := zapsuit.RegisterTCPSink()
 	if err != nil {
 		log.Fatalf("register zap TCP sink: %v", err)
@@ -20,6 +20,9 @@ type Log struct {
 	// Target accepts "nop", "tcp://host:port?timeout=5s", filepath or sentryDSN.
 	// Register NewTCPSink to support tcp sink. Default is stderr.
 	Target string
+
+	// Access logs every proxied request at info level.
+	Access bool
 }
 
 type Ipfs struct {
@@ -36,6 +39,66 @@ type Ipfs struct {
 	EnableMultiplex  bool
 
 	Token string `validate:"lte=732"`
+
+	// Conns to IpfsServers. MaxConcurrentStreams 0 means no limit, and
+	// PingIntervalMS 0 disables ping. Failed dials are retried after
+	// MinRedialMS, which doubles up to MaxRedialMS. PreDial dials all
+	// IpfsServers on start, and redials when conns are dead.
+	MaxConcurrentStreams uint
+	PingIntervalMS       uint `default:"30000"`
+	PingTimeoutMS        uint `default:"10000"`
+	MinRedialMS          uint `default:"1000"`
+	MaxRedialMS          uint `default:"120000"`
+	PreDial              bool
+}
+
+// Mitm terminates TLS of CONNECT tunnels to Hosts with a local CA, which is
+// generated under the store dir and must be trusted by clients. Host starts
+// with dot matches itself and all subdomains.
+type Mitm struct {
+	Hosts []string `validate:"dive,required"`
+
+	// CAServerName serves the CA certificate as local server.
+	CAServerName string `validate:"omitempty,hostname"`
+}
+
+// Trace appends Hybrid-Trace headers of every node to requests, responses
+// and errors.
+type Trace struct {
+	Enabled bool
+
+	// Name of this node in headers, default is the ipfs peer ID.
+	Name string `validate:"omitempty,hostname"`
+
+	// KeepAtTarget sends traces to target servers, they are stripped before
+	// the final dial by default.
+	KeepAtTarget bool
+}
+
+// HealthCheck probes IpfsServers by opening streams, and HttpProxyServers by
+// dialing them.
+type HealthCheck struct {
+	// IntervalMS 0 disables health check.
+	IntervalMS uint
+	TimeoutMS  uint `default:"5000"`
+
+	// MaxFails consecutive failed probes make the proxy unhealthy.
+	MaxFails uint `default:"2"`
+
+	// SkipUnhealthy routes to the next router if the routed proxy is
+	// unhealthy.
+	SkipUnhealthy bool
+}
+
+// Resolver resolves hostnames for IPNetRouter and GeoIPRouter, answers are
+// shared by all routers.
+type Resolver struct {
+	// Server is host:port of a DNS server, answers are cached for their TTL.
+	// Empty uses the system resolver, answers are cached for MinTTLMS.
+	Server    string
+	TimeoutMS uint `default:"5000"`
+	MinTTLMS  uint `default:"30000"`
+	MaxTTLMS  uint `default:"3600000"`
 }
 
 // server types
@@ -45,6 +108,9 @@ type IpfsServer struct {
 	Peer     string `validate:"required"`
 	Protocol string `validate:"required" default:"/hybrid/1.0"`
 	Token    string `validate:"lte=732"`
+
+	// RateLimitKB is KiB per second, 0 means unlimited.
+	RateLimitKB uint
 }
 
 type FileServer struct {
@@ -55,15 +121,58 @@ type FileServer struct {
 }
 
 type HttpProxyServer struct {
-	Name      string `validate:"omitempty,hostname"`
-	Host      string `validate:"tcp_addr"`
+	Name string `validate:"omitempty,hostname"`
+	// Host is address of http proxy, used when URL is empty.
+	Host string `validate:"omitempty,tcp_addr"`
+	// URL accepts http, https, socks5 and socks5h with optional user:password.
+	URL       string `validate:"omitempty,url"`
 	KeepAlive bool
+
+	// RateLimitKB is KiB per second, 0 means unlimited.
+	RateLimitKB uint
 }
 
 // server types end
 
+// ProxyGroup is a proxy that picks one of Proxies by Strategy, its Name is
+// accepted anywhere a proxy name is. Proxies are names of IpfsServers,
+// HttpProxyServers, DIRECT or groups defined before.
+type ProxyGroup struct {
+	Name     string   `validate:"hostname"`
+	Proxies  []string `validate:"required,unique,dive,required"`
+	Strategy string   `validate:"omitempty,oneof=failover round-robin least-conn latency" default:"failover"`
+
+	// ProbeURL is requested through every member, empty disables probing.
+	// It is required by latency Strategy.
+	ProbeURL        string `validate:"omitempty,url"`
+	ProbeIntervalMS uint   `default:"30000"`
+	ProbeTimeoutMS  uint   `default:"5000"`
+
+	// MaxFails consecutive failures eject the member for EjectMS, 0 never
+	// ejects.
+	MaxFails uint `default:"3"`
+	EjectMS  uint `default:"30000"`
+}
+
 // routers
 
+// AdpSubscription fetches a rule list from URL through Proxy every IntervalMS,
+// then saves it as FileName in B64RuleDirName if Base64, or TxtRuleDirName.
+// The list is verified by ChecksumURL serving hex sha256, or SignatureURL
+// serving ed25519 signature by hex PublicKey, if set.
+type AdpSubscription struct {
+	URL      string `validate:"url"`
+	FileName string `validate:"required"`
+	Base64   bool
+	// Proxy is empty to fetch by DIRECT.
+	Proxy        string
+	IntervalMS   uint   `default:"86400000"`
+	TimeoutMS    uint   `default:"60000"`
+	ChecksumURL  string `validate:"omitempty,url"`
+	SignatureURL string `validate:"omitempty,url"`
+	PublicKey    string `validate:"omitempty,hexadecimal,len=64"`
+}
+
 type AdpRouter struct {
 	B64RuleDirName      string `validate:"omitempty,hostname"`
 	TxtRuleDirName      string `validate:"omitempty,hostname,nefield=B64RuleDirName"`
@@ -71,6 +180,16 @@ type AdpRouter struct {
 	Unblocked           string `validate:"omitempty,hostname,nefield=Blocked"`
 	EtcHostsIPAsBlocked bool
 	Dev                 bool
+
+	// CacheSize bounds the LRU of decisions by request.
+	CacheSize    uint `default:"10000"`
+	CacheTTLMS   uint `default:"600000"`
+	DisableCache bool
+
+	// WatchIntervalMS polls rule dirs and reloads changed rules, 0 disables.
+	WatchIntervalMS uint `default:"60000"`
+
+	Subscriptions []AdpSubscription `validate:"dive"`
 }
 
 type IPNetRouter struct {
@@ -79,15 +198,87 @@ type IPNetRouter struct {
 	Matched   string   `validate:"omitempty,hostname"`
 	Unmatched string   `validate:"omitempty,hostname,nefield=Matched"`
 	FileTest  string   `validate:"omitempty,hostname"`
+
+	// Resolve matches hostnames by resolved IPs. "always" resolves when the
+	// router is reached, "last" resolves only if no router routes.
+	Resolve string `validate:"omitempty,oneof=always last"`
+}
+
+// DomainRuleSet routes hosts matched by rules to Proxy. Rules are loaded from
+// files start with digit in RuleDirName under rules-root, and inline Rules.
+// Rule syntax:
+//
+//	example.com          exact
+//	*.corp.example       subdomains of corp.example
+//	.corp.example        corp.example and its subdomains
+//	keyword:google       hosts contain google
+//	regexp:^ad[0-9]+\.   hosts matched by the regexp
+type DomainRuleSet struct {
+	Proxy       string `validate:"required"`
+	RuleDirName string `validate:"omitempty,hostname"`
+	Rules       []string
+}
+
+type DomainRouter struct {
+	RuleSets []DomainRuleSet `validate:"required,dive"`
+	// Unmatched is empty to let the next router route.
+	Unmatched string
+}
+
+// GeoIPRule routes IPs located in Countries or announced by ASNs to Proxy.
+// Countries are ISO 3166-1 alpha-2 codes.
+type GeoIPRule struct {
+	Proxy     string   `validate:"required"`
+	Countries []string `validate:"dive,len=2"`
+	ASNs      []uint
+}
+
+// GeoIPRouter locates hosts by MaxMind or DB-IP mmdb files under rules-root.
+// Hostnames are resolved by Resolver unless NoResolve.
+type GeoIPRouter struct {
+	DBFileNames []string    `validate:"required,dive,required"`
+	Rules       []GeoIPRule `validate:"required,dive"`
+	NoResolve   bool
+	// Unmatched is empty to let the next router route.
+	Unmatched string
 }
 
 // routers end
 
+// middlewares
+
+type HeaderMiddleware struct {
+	Set    map[string]string
+	Remove []string
+}
+
+type BasicAuthMiddleware struct {
+	Realm string            `default:"hybrid"`
+	Users map[string]string `validate:"required"`
+}
+
+type DenyMiddleware struct {
+	// Hosts starts with dot matches itself and all subdomains.
+	Hosts []string `validate:"required"`
+}
+
+// middlewares end
+
+type MiddlewareItem struct {
+	Name string `validate:"omitempty,hostname"`
+	// middleware
+	Header    *HeaderMiddleware
+	BasicAuth *BasicAuthMiddleware
+	Deny      *DenyMiddleware
+}
+
 type RouterItem struct {
 	Name string `validate:"omitempty,hostname"`
 	// router
-	Adp   *AdpRouter
-	IPNet *IPNetRouter
+	Adp    *AdpRouter
+	IPNet  *IPNetRouter
+	Domain *DomainRouter
+	GeoIP  *GeoIPRouter
 }
 
 type Config struct {
@@ -100,17 +291,58 @@ type Config struct {
 
 	FlushIntervalMS uint `default:"200"`
 
+	// Timeouts of proxied streams, 0 means no timeout.
+	DialTimeoutMS uint `default:"30000"`
+	IdleTimeoutMS uint `default:"300000"`
+	MaxLifetimeMS uint
+
+	// SNISniffTimeoutMS enables routing CONNECT tunnels by TLS SNI, 0 disables.
+	SNISniffTimeoutMS uint
+
+	// Keep-alive of http binds and conns to Direct targets.
+	DisableKeepAlives   bool
+	MaxIdleConnsPerHost uint `default:"4"`
+	IdleConnTimeoutMS   uint `default:"90000"`
+
+	// Rate limits of all streams and every client IP, KiB per second.
+	RateLimitKB       uint
+	ClientRateLimitKB uint
+
+	// ProxyAuth requires Proxy-Authorization of both http binds and peers.
+	// Socks5 binds verify username:secret keys too. Keys are managed by grpc.
+	// Transparent binds cannot carry credentials and are never verified, so
+	// they must only be reachable from trusted networks.
+	ProxyAuth bool
+
+	// ErrorPagesZipName is a zip in files-root, its error.html overrides the
+	// html/template of errors replied to browsers, executed with core.HttpErr.
+	ErrorPagesZipName string `validate:"omitempty,hostname"`
+
+	// MetricsServerName serves prometheus metrics as local server.
+	MetricsServerName string `validate:"omitempty,hostname"`
+
+	// Socks5Users enables username/password auth of socks5 binds, key is username.
+	// Keys of ProxyAuth are accepted too if it is set.
+	Socks5Users map[string]string
+
 	// Token is fallback token that will be veried by servers, both Ipfs
 	Token string `validate:"omitempty,lte=732"`
 
-	Log  Log
-	Ipfs Ipfs
+	Log   Log
+	Ipfs  Ipfs
+	Mitm  Mitm
+	Trace Trace
+
+	HealthCheck HealthCheck
+	Resolver    Resolver
 
 	IpfsServers      []IpfsServer
 	FileServers      []FileServer
 	HttpProxyServers []HttpProxyServer
+	ProxyGroups      []ProxyGroup
 
-	Routers []RouterItem
+	Middlewares []MiddlewareItem
+	Routers     []RouterItem
 
 	tree *ConfigTree
 }
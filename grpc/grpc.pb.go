@@ -26,6 +26,63 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type BindRequest_Mode int32
+
+const (
+	BindRequest_HTTP   BindRequest_Mode = 0
+	BindRequest_SOCKS5 BindRequest_Mode = 1
+	// transparent proxy of iptables, linux only
+	BindRequest_REDIRECT BindRequest_Mode = 2
+	BindRequest_TPROXY   BindRequest_Mode = 3
+)
+
+var BindRequest_Mode_name = map[int32]string{
+	0: "HTTP",
+	1: "SOCKS5",
+	2: "REDIRECT",
+	3: "TPROXY",
+}
+var BindRequest_Mode_value = map[string]int32{
+	"HTTP":     0,
+	"SOCKS5":   1,
+	"REDIRECT": 2,
+	"TPROXY":   3,
+}
+
+func (x BindRequest_Mode) String() string {
+	return proto.EnumName(BindRequest_Mode_name, int32(x))
+}
+func (BindRequest_Mode) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{2, 0}
+}
+
+type RateLimitRequest_Scope int32
+
+const (
+	RateLimitRequest_GLOBAL RateLimitRequest_Scope = 0
+	RateLimitRequest_PROXY  RateLimitRequest_Scope = 1
+	// every client IP
+	RateLimitRequest_CLIENT RateLimitRequest_Scope = 2
+)
+
+var RateLimitRequest_Scope_name = map[int32]string{
+	0: "GLOBAL",
+	1: "PROXY",
+	2: "CLIENT",
+}
+var RateLimitRequest_Scope_value = map[string]int32{
+	"GLOBAL": 0,
+	"PROXY":  1,
+	"CLIENT": 2,
+}
+
+func (x RateLimitRequest_Scope) String() string {
+	return proto.EnumName(RateLimitRequest_Scope_name, int32(x))
+}
+func (RateLimitRequest_Scope) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{11, 0}
+}
+
 type Version struct {
 	HybridStreamProtocol string   `protobuf:"bytes,1,opt,name=hybrid_stream_protocol,json=hybridStreamProtocol,proto3" json:"hybrid_stream_protocol,omitempty"`
 	Ipfs                 string   `protobuf:"bytes,2,opt,name=ipfs,proto3" json:"ipfs,omitempty"`
@@ -43,7 +100,7 @@ func (m *Version) Reset()         { *m = Version{} }
 func (m *Version) String() string { return proto.CompactTextString(m) }
 func (*Version) ProtoMessage()    {}
 func (*Version) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{0}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{0}
 }
 func (m *Version) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Version.Unmarshal(m, b)
@@ -123,7 +180,7 @@ func (m *StartRequest) Reset()         { *m = StartRequest{} }
 func (m *StartRequest) String() string { return proto.CompactTextString(m) }
 func (*StartRequest) ProtoMessage()    {}
 func (*StartRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{1}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{1}
 }
 func (m *StartRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_StartRequest.Unmarshal(m, b)
@@ -151,18 +208,20 @@ func (m *StartRequest) GetRoot() string {
 }
 
 type BindRequest struct {
-	Network              string   `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
-	Address              string   `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Network string `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address,proto3" json:"address,omitempty"`
+	// mode is only used by BindProxy
+	Mode                 BindRequest_Mode `protobuf:"varint,3,opt,name=mode,proto3,enum=protos.BindRequest.Mode" json:"mode,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
 }
 
 func (m *BindRequest) Reset()         { *m = BindRequest{} }
 func (m *BindRequest) String() string { return proto.CompactTextString(m) }
 func (*BindRequest) ProtoMessage()    {}
 func (*BindRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{2}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{2}
 }
 func (m *BindRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_BindRequest.Unmarshal(m, b)
@@ -196,6 +255,13 @@ func (m *BindRequest) GetAddress() string {
 	return ""
 }
 
+func (m *BindRequest) GetMode() BindRequest_Mode {
+	if m != nil {
+		return m.Mode
+	}
+	return BindRequest_HTTP
+}
+
 type BindData struct {
 	Bind                 uint32   `protobuf:"varint,2,opt,name=bind,proto3" json:"bind,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -207,7 +273,7 @@ func (m *BindData) Reset()         { *m = BindData{} }
 func (m *BindData) String() string { return proto.CompactTextString(m) }
 func (*BindData) ProtoMessage()    {}
 func (*BindData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{3}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{3}
 }
 func (m *BindData) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_BindData.Unmarshal(m, b)
@@ -247,7 +313,7 @@ func (m *BackupRequest) Reset()         { *m = BackupRequest{} }
 func (m *BackupRequest) String() string { return proto.CompactTextString(m) }
 func (*BackupRequest) ProtoMessage()    {}
 func (*BackupRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{4}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{4}
 }
 func (m *BackupRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_BackupRequest.Unmarshal(m, b)
@@ -303,7 +369,7 @@ func (m *AddVerifyKeyRequest) Reset()         { *m = AddVerifyKeyRequest{} }
 func (m *AddVerifyKeyRequest) String() string { return proto.CompactTextString(m) }
 func (*AddVerifyKeyRequest) ProtoMessage()    {}
 func (*AddVerifyKeyRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{5}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{5}
 }
 func (m *AddVerifyKeyRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AddVerifyKeyRequest.Unmarshal(m, b)
@@ -364,7 +430,7 @@ func (m *AddVerifyKeyReply) Reset()         { *m = AddVerifyKeyReply{} }
 func (m *AddVerifyKeyReply) String() string { return proto.CompactTextString(m) }
 func (*AddVerifyKeyReply) ProtoMessage()    {}
 func (*AddVerifyKeyReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{6}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{6}
 }
 func (m *AddVerifyKeyReply) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AddVerifyKeyReply.Unmarshal(m, b)
@@ -418,7 +484,7 @@ func (m *VerifyKeySliceRequest) Reset()         { *m = VerifyKeySliceRequest{} }
 func (m *VerifyKeySliceRequest) String() string { return proto.CompactTextString(m) }
 func (*VerifyKeySliceRequest) ProtoMessage()    {}
 func (*VerifyKeySliceRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{7}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{7}
 }
 func (m *VerifyKeySliceRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_VerifyKeySliceRequest.Unmarshal(m, b)
@@ -471,7 +537,7 @@ func (m *AuthKeySliceReply) Reset()         { *m = AuthKeySliceReply{} }
 func (m *AuthKeySliceReply) String() string { return proto.CompactTextString(m) }
 func (*AuthKeySliceReply) ProtoMessage()    {}
 func (*AuthKeySliceReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{8}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{8}
 }
 func (m *AuthKeySliceReply) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_AuthKeySliceReply.Unmarshal(m, b)
@@ -516,7 +582,7 @@ func (m *VerifyKeyIdRequest) Reset()         { *m = VerifyKeyIdRequest{} }
 func (m *VerifyKeyIdRequest) String() string { return proto.CompactTextString(m) }
 func (*VerifyKeyIdRequest) ProtoMessage()    {}
 func (*VerifyKeyIdRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_grpc_0f66c3eff413103d, []int{9}
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{9}
 }
 func (m *VerifyKeyIdRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_VerifyKeyIdRequest.Unmarshal(m, b)
@@ -543,6 +609,571 @@ func (m *VerifyKeyIdRequest) GetId() uint64 {
 	return 0
 }
 
+type AddProxyAuthKeyRequest struct {
+	// username is empty for Bearer token
+	Username string `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	// secret is password for Basic, or token for Bearer, only its sha256 hash
+	// is stored and returned by GetProxyAuthKeys
+	Secret string   `protobuf:"bytes,2,opt,name=secret,proto3" json:"secret,omitempty"`
+	Tags   []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	Desc   string   `protobuf:"bytes,4,opt,name=desc,proto3" json:"desc,omitempty"`
+	// life_seconds 0 means never expires
+	LifeSeconds          uint32   `protobuf:"varint,5,opt,name=life_seconds,json=lifeSeconds,proto3" json:"life_seconds,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddProxyAuthKeyRequest) Reset()         { *m = AddProxyAuthKeyRequest{} }
+func (m *AddProxyAuthKeyRequest) String() string { return proto.CompactTextString(m) }
+func (*AddProxyAuthKeyRequest) ProtoMessage()    {}
+func (*AddProxyAuthKeyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{10}
+}
+func (m *AddProxyAuthKeyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddProxyAuthKeyRequest.Unmarshal(m, b)
+}
+func (m *AddProxyAuthKeyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddProxyAuthKeyRequest.Marshal(b, m, deterministic)
+}
+func (dst *AddProxyAuthKeyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddProxyAuthKeyRequest.Merge(dst, src)
+}
+func (m *AddProxyAuthKeyRequest) XXX_Size() int {
+	return xxx_messageInfo_AddProxyAuthKeyRequest.Size(m)
+}
+func (m *AddProxyAuthKeyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddProxyAuthKeyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddProxyAuthKeyRequest proto.InternalMessageInfo
+
+func (m *AddProxyAuthKeyRequest) GetUsername() string {
+	if m != nil {
+		return m.Username
+	}
+	return ""
+}
+
+func (m *AddProxyAuthKeyRequest) GetSecret() string {
+	if m != nil {
+		return m.Secret
+	}
+	return ""
+}
+
+func (m *AddProxyAuthKeyRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *AddProxyAuthKeyRequest) GetDesc() string {
+	if m != nil {
+		return m.Desc
+	}
+	return ""
+}
+
+func (m *AddProxyAuthKeyRequest) GetLifeSeconds() uint32 {
+	if m != nil {
+		return m.LifeSeconds
+	}
+	return 0
+}
+
+type RateLimitRequest struct {
+	Scope RateLimitRequest_Scope `protobuf:"varint,1,opt,name=scope,proto3,enum=protos.RateLimitRequest.Scope" json:"scope,omitempty"`
+	// name is proxy name, only used by PROXY
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// kb_per_second 0 means unlimited
+	KbPerSecond          uint32   `protobuf:"varint,3,opt,name=kb_per_second,json=kbPerSecond,proto3" json:"kb_per_second,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RateLimitRequest) Reset()         { *m = RateLimitRequest{} }
+func (m *RateLimitRequest) String() string { return proto.CompactTextString(m) }
+func (*RateLimitRequest) ProtoMessage()    {}
+func (*RateLimitRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{11}
+}
+func (m *RateLimitRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RateLimitRequest.Unmarshal(m, b)
+}
+func (m *RateLimitRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RateLimitRequest.Marshal(b, m, deterministic)
+}
+func (dst *RateLimitRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RateLimitRequest.Merge(dst, src)
+}
+func (m *RateLimitRequest) XXX_Size() int {
+	return xxx_messageInfo_RateLimitRequest.Size(m)
+}
+func (m *RateLimitRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RateLimitRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RateLimitRequest proto.InternalMessageInfo
+
+func (m *RateLimitRequest) GetScope() RateLimitRequest_Scope {
+	if m != nil {
+		return m.Scope
+	}
+	return RateLimitRequest_GLOBAL
+}
+
+func (m *RateLimitRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *RateLimitRequest) GetKbPerSecond() uint32 {
+	if m != nil {
+		return m.KbPerSecond
+	}
+	return 0
+}
+
+type ProxyStatusRequest struct {
+	// names filters proxies, empty means all
+	Names                []string `protobuf:"bytes,1,rep,name=names,proto3" json:"names,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProxyStatusRequest) Reset()         { *m = ProxyStatusRequest{} }
+func (m *ProxyStatusRequest) String() string { return proto.CompactTextString(m) }
+func (*ProxyStatusRequest) ProtoMessage()    {}
+func (*ProxyStatusRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{12}
+}
+func (m *ProxyStatusRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProxyStatusRequest.Unmarshal(m, b)
+}
+func (m *ProxyStatusRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProxyStatusRequest.Marshal(b, m, deterministic)
+}
+func (dst *ProxyStatusRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProxyStatusRequest.Merge(dst, src)
+}
+func (m *ProxyStatusRequest) XXX_Size() int {
+	return xxx_messageInfo_ProxyStatusRequest.Size(m)
+}
+func (m *ProxyStatusRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProxyStatusRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProxyStatusRequest proto.InternalMessageInfo
+
+func (m *ProxyStatusRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+type ProxyStatus struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Healthy bool   `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	// checked_at is unix seconds, 0 if not checked yet
+	CheckedAt int64  `protobuf:"varint,3,opt,name=checked_at,json=checkedAt,proto3" json:"checked_at,omitempty"`
+	LatencyMs uint32 `protobuf:"varint,4,opt,name=latency_ms,json=latencyMs,proto3" json:"latency_ms,omitempty"`
+	// fails is consecutive failed probes
+	Fails                uint32   `protobuf:"varint,5,opt,name=fails,proto3" json:"fails,omitempty"`
+	Err                  string   `protobuf:"bytes,6,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProxyStatus) Reset()         { *m = ProxyStatus{} }
+func (m *ProxyStatus) String() string { return proto.CompactTextString(m) }
+func (*ProxyStatus) ProtoMessage()    {}
+func (*ProxyStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{13}
+}
+func (m *ProxyStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProxyStatus.Unmarshal(m, b)
+}
+func (m *ProxyStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProxyStatus.Marshal(b, m, deterministic)
+}
+func (dst *ProxyStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProxyStatus.Merge(dst, src)
+}
+func (m *ProxyStatus) XXX_Size() int {
+	return xxx_messageInfo_ProxyStatus.Size(m)
+}
+func (m *ProxyStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProxyStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProxyStatus proto.InternalMessageInfo
+
+func (m *ProxyStatus) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *ProxyStatus) GetHealthy() bool {
+	if m != nil {
+		return m.Healthy
+	}
+	return false
+}
+
+func (m *ProxyStatus) GetCheckedAt() int64 {
+	if m != nil {
+		return m.CheckedAt
+	}
+	return 0
+}
+
+func (m *ProxyStatus) GetLatencyMs() uint32 {
+	if m != nil {
+		return m.LatencyMs
+	}
+	return 0
+}
+
+func (m *ProxyStatus) GetFails() uint32 {
+	if m != nil {
+		return m.Fails
+	}
+	return 0
+}
+
+func (m *ProxyStatus) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type ReloadRulesRequest struct {
+	// routers filters AdpRouters by name, empty means all
+	Routers              []string `protobuf:"bytes,1,rep,name=routers,proto3" json:"routers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReloadRulesRequest) Reset()         { *m = ReloadRulesRequest{} }
+func (m *ReloadRulesRequest) String() string { return proto.CompactTextString(m) }
+func (*ReloadRulesRequest) ProtoMessage()    {}
+func (*ReloadRulesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{14}
+}
+func (m *ReloadRulesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReloadRulesRequest.Unmarshal(m, b)
+}
+func (m *ReloadRulesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReloadRulesRequest.Marshal(b, m, deterministic)
+}
+func (dst *ReloadRulesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReloadRulesRequest.Merge(dst, src)
+}
+func (m *ReloadRulesRequest) XXX_Size() int {
+	return xxx_messageInfo_ReloadRulesRequest.Size(m)
+}
+func (m *ReloadRulesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReloadRulesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReloadRulesRequest proto.InternalMessageInfo
+
+func (m *ReloadRulesRequest) GetRouters() []string {
+	if m != nil {
+		return m.Routers
+	}
+	return nil
+}
+
+type RulesReloaded struct {
+	Router string `protobuf:"bytes,1,opt,name=router,proto3" json:"router,omitempty"`
+	Rules  uint32 `protobuf:"varint,2,opt,name=rules,proto3" json:"rules,omitempty"`
+	// parse_errors are of rule files, which are skipped
+	ParseErrors []string `protobuf:"bytes,3,rep,name=parse_errors,json=parseErrors,proto3" json:"parse_errors,omitempty"`
+	// err is set if reload failed, then the old rules are kept
+	Err                  string   `protobuf:"bytes,4,opt,name=err,proto3" json:"err,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RulesReloaded) Reset()         { *m = RulesReloaded{} }
+func (m *RulesReloaded) String() string { return proto.CompactTextString(m) }
+func (*RulesReloaded) ProtoMessage()    {}
+func (*RulesReloaded) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{15}
+}
+func (m *RulesReloaded) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RulesReloaded.Unmarshal(m, b)
+}
+func (m *RulesReloaded) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RulesReloaded.Marshal(b, m, deterministic)
+}
+func (dst *RulesReloaded) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RulesReloaded.Merge(dst, src)
+}
+func (m *RulesReloaded) XXX_Size() int {
+	return xxx_messageInfo_RulesReloaded.Size(m)
+}
+func (m *RulesReloaded) XXX_DiscardUnknown() {
+	xxx_messageInfo_RulesReloaded.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RulesReloaded proto.InternalMessageInfo
+
+func (m *RulesReloaded) GetRouter() string {
+	if m != nil {
+		return m.Router
+	}
+	return ""
+}
+
+func (m *RulesReloaded) GetRules() uint32 {
+	if m != nil {
+		return m.Rules
+	}
+	return 0
+}
+
+func (m *RulesReloaded) GetParseErrors() []string {
+	if m != nil {
+		return m.ParseErrors
+	}
+	return nil
+}
+
+func (m *RulesReloaded) GetErr() string {
+	if m != nil {
+		return m.Err
+	}
+	return ""
+}
+
+type ReloadRulesReply struct {
+	Routers              []*RulesReloaded `protobuf:"bytes,1,rep,name=routers,proto3" json:"routers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *ReloadRulesReply) Reset()         { *m = ReloadRulesReply{} }
+func (m *ReloadRulesReply) String() string { return proto.CompactTextString(m) }
+func (*ReloadRulesReply) ProtoMessage()    {}
+func (*ReloadRulesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{16}
+}
+func (m *ReloadRulesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReloadRulesReply.Unmarshal(m, b)
+}
+func (m *ReloadRulesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReloadRulesReply.Marshal(b, m, deterministic)
+}
+func (dst *ReloadRulesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReloadRulesReply.Merge(dst, src)
+}
+func (m *ReloadRulesReply) XXX_Size() int {
+	return xxx_messageInfo_ReloadRulesReply.Size(m)
+}
+func (m *ReloadRulesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReloadRulesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReloadRulesReply proto.InternalMessageInfo
+
+func (m *ReloadRulesReply) GetRouters() []*RulesReloaded {
+	if m != nil {
+		return m.Routers
+	}
+	return nil
+}
+
+type ExplainRouteRequest struct {
+	// url is requested by GET, https is explained as CONNECT
+	Url                  string   `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExplainRouteRequest) Reset()         { *m = ExplainRouteRequest{} }
+func (m *ExplainRouteRequest) String() string { return proto.CompactTextString(m) }
+func (*ExplainRouteRequest) ProtoMessage()    {}
+func (*ExplainRouteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{17}
+}
+func (m *ExplainRouteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExplainRouteRequest.Unmarshal(m, b)
+}
+func (m *ExplainRouteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExplainRouteRequest.Marshal(b, m, deterministic)
+}
+func (dst *ExplainRouteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExplainRouteRequest.Merge(dst, src)
+}
+func (m *ExplainRouteRequest) XXX_Size() int {
+	return xxx_messageInfo_ExplainRouteRequest.Size(m)
+}
+func (m *ExplainRouteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExplainRouteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExplainRouteRequest proto.InternalMessageInfo
+
+func (m *ExplainRouteRequest) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+type RouteStep struct {
+	Router string `protobuf:"bytes,1,opt,name=router,proto3" json:"router,omitempty"`
+	// late is true if routed after all routers not routed
+	Late bool `protobuf:"varint,2,opt,name=late,proto3" json:"late,omitempty"`
+	// proxy is empty if not routed
+	Proxy    string `protobuf:"bytes,3,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	Rule     string `protobuf:"bytes,4,opt,name=rule,proto3" json:"rule,omitempty"`
+	RuleFile string `protobuf:"bytes,5,opt,name=rule_file,json=ruleFile,proto3" json:"rule_file,omitempty"`
+	// skipped is why the router or its proxy is skipped
+	Skipped              string   `protobuf:"bytes,6,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RouteStep) Reset()         { *m = RouteStep{} }
+func (m *RouteStep) String() string { return proto.CompactTextString(m) }
+func (*RouteStep) ProtoMessage()    {}
+func (*RouteStep) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{18}
+}
+func (m *RouteStep) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RouteStep.Unmarshal(m, b)
+}
+func (m *RouteStep) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RouteStep.Marshal(b, m, deterministic)
+}
+func (dst *RouteStep) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RouteStep.Merge(dst, src)
+}
+func (m *RouteStep) XXX_Size() int {
+	return xxx_messageInfo_RouteStep.Size(m)
+}
+func (m *RouteStep) XXX_DiscardUnknown() {
+	xxx_messageInfo_RouteStep.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RouteStep proto.InternalMessageInfo
+
+func (m *RouteStep) GetRouter() string {
+	if m != nil {
+		return m.Router
+	}
+	return ""
+}
+
+func (m *RouteStep) GetLate() bool {
+	if m != nil {
+		return m.Late
+	}
+	return false
+}
+
+func (m *RouteStep) GetProxy() string {
+	if m != nil {
+		return m.Proxy
+	}
+	return ""
+}
+
+func (m *RouteStep) GetRule() string {
+	if m != nil {
+		return m.Rule
+	}
+	return ""
+}
+
+func (m *RouteStep) GetRuleFile() string {
+	if m != nil {
+		return m.RuleFile
+	}
+	return ""
+}
+
+func (m *RouteStep) GetSkipped() string {
+	if m != nil {
+		return m.Skipped
+	}
+	return ""
+}
+
+type RouteExplanation struct {
+	Steps []*RouteStep `protobuf:"bytes,1,rep,name=steps,proto3" json:"steps,omitempty"`
+	// router is empty if no router routes
+	Router               string   `protobuf:"bytes,2,opt,name=router,proto3" json:"router,omitempty"`
+	Proxy                string   `protobuf:"bytes,3,opt,name=proxy,proto3" json:"proxy,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RouteExplanation) Reset()         { *m = RouteExplanation{} }
+func (m *RouteExplanation) String() string { return proto.CompactTextString(m) }
+func (*RouteExplanation) ProtoMessage()    {}
+func (*RouteExplanation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_grpc_a4a8be70e277f17b, []int{19}
+}
+func (m *RouteExplanation) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RouteExplanation.Unmarshal(m, b)
+}
+func (m *RouteExplanation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RouteExplanation.Marshal(b, m, deterministic)
+}
+func (dst *RouteExplanation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RouteExplanation.Merge(dst, src)
+}
+func (m *RouteExplanation) XXX_Size() int {
+	return xxx_messageInfo_RouteExplanation.Size(m)
+}
+func (m *RouteExplanation) XXX_DiscardUnknown() {
+	xxx_messageInfo_RouteExplanation.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RouteExplanation proto.InternalMessageInfo
+
+func (m *RouteExplanation) GetSteps() []*RouteStep {
+	if m != nil {
+		return m.Steps
+	}
+	return nil
+}
+
+func (m *RouteExplanation) GetRouter() string {
+	if m != nil {
+		return m.Router
+	}
+	return ""
+}
+
+func (m *RouteExplanation) GetProxy() string {
+	if m != nil {
+		return m.Proxy
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*Version)(nil), "protos.Version")
 	proto.RegisterType((*StartRequest)(nil), "protos.StartRequest")
@@ -554,6 +1185,18 @@ func init() {
 	proto.RegisterType((*VerifyKeySliceRequest)(nil), "protos.VerifyKeySliceRequest")
 	proto.RegisterType((*AuthKeySliceReply)(nil), "protos.AuthKeySliceReply")
 	proto.RegisterType((*VerifyKeyIdRequest)(nil), "protos.VerifyKeyIdRequest")
+	proto.RegisterType((*AddProxyAuthKeyRequest)(nil), "protos.AddProxyAuthKeyRequest")
+	proto.RegisterType((*RateLimitRequest)(nil), "protos.RateLimitRequest")
+	proto.RegisterType((*ProxyStatusRequest)(nil), "protos.ProxyStatusRequest")
+	proto.RegisterType((*ProxyStatus)(nil), "protos.ProxyStatus")
+	proto.RegisterType((*ReloadRulesRequest)(nil), "protos.ReloadRulesRequest")
+	proto.RegisterType((*RulesReloaded)(nil), "protos.RulesReloaded")
+	proto.RegisterType((*ReloadRulesReply)(nil), "protos.ReloadRulesReply")
+	proto.RegisterType((*ExplainRouteRequest)(nil), "protos.ExplainRouteRequest")
+	proto.RegisterType((*RouteStep)(nil), "protos.RouteStep")
+	proto.RegisterType((*RouteExplanation)(nil), "protos.RouteExplanation")
+	proto.RegisterEnum("protos.BindRequest.Mode", BindRequest_Mode_name, BindRequest_Mode_value)
+	proto.RegisterEnum("protos.RateLimitRequest.Scope", RateLimitRequest_Scope_name, RateLimitRequest_Scope_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -586,6 +1229,16 @@ type HybridClient interface {
 	GetVerifyKeys(ctx context.Context, in *VerifyKeySliceRequest, opts ...grpc.CallOption) (*AuthKeySliceReply, error)
 	FindVerifyKey(ctx context.Context, in *VerifyKeyIdRequest, opts ...grpc.CallOption) (*authstore.AuthKey, error)
 	DeleteVerifyKey(ctx context.Context, in *VerifyKeyIdRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	AddProxyAuthKey(ctx context.Context, in *AddProxyAuthKeyRequest, opts ...grpc.CallOption) (*AddVerifyKeyReply, error)
+	GetProxyAuthKeys(ctx context.Context, in *VerifyKeySliceRequest, opts ...grpc.CallOption) (*AuthKeySliceReply, error)
+	DeleteProxyAuthKey(ctx context.Context, in *VerifyKeyIdRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	SetRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// GetProxyStatus sends current status of proxies, then every probe result.
+	GetProxyStatus(ctx context.Context, in *ProxyStatusRequest, opts ...grpc.CallOption) (Hybrid_GetProxyStatusClient, error)
+	// ReloadRules reloads rule dirs of AdpRouters without restarting.
+	ReloadRules(ctx context.Context, in *ReloadRulesRequest, opts ...grpc.CallOption) (*ReloadRulesReply, error)
+	// ExplainRoute dry runs routers with the url without dialing.
+	ExplainRoute(ctx context.Context, in *ExplainRouteRequest, opts ...grpc.CallOption) (*RouteExplanation, error)
 }
 
 type hybridClient struct {
@@ -758,6 +1411,92 @@ func (c *hybridClient) DeleteVerifyKey(ctx context.Context, in *VerifyKeyIdReque
 	return out, nil
 }
 
+func (c *hybridClient) AddProxyAuthKey(ctx context.Context, in *AddProxyAuthKeyRequest, opts ...grpc.CallOption) (*AddVerifyKeyReply, error) {
+	out := new(AddVerifyKeyReply)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/AddProxyAuthKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hybridClient) GetProxyAuthKeys(ctx context.Context, in *VerifyKeySliceRequest, opts ...grpc.CallOption) (*AuthKeySliceReply, error) {
+	out := new(AuthKeySliceReply)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/GetProxyAuthKeys", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hybridClient) DeleteProxyAuthKey(ctx context.Context, in *VerifyKeyIdRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/DeleteProxyAuthKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hybridClient) SetRateLimit(ctx context.Context, in *RateLimitRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/SetRateLimit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hybridClient) GetProxyStatus(ctx context.Context, in *ProxyStatusRequest, opts ...grpc.CallOption) (Hybrid_GetProxyStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Hybrid_serviceDesc.Streams[0], "/protos.Hybrid/GetProxyStatus", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hybridGetProxyStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Hybrid_GetProxyStatusClient interface {
+	Recv() (*ProxyStatus, error)
+	grpc.ClientStream
+}
+
+type hybridGetProxyStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *hybridGetProxyStatusClient) Recv() (*ProxyStatus, error) {
+	m := new(ProxyStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hybridClient) ReloadRules(ctx context.Context, in *ReloadRulesRequest, opts ...grpc.CallOption) (*ReloadRulesReply, error) {
+	out := new(ReloadRulesReply)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/ReloadRules", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hybridClient) ExplainRoute(ctx context.Context, in *ExplainRouteRequest, opts ...grpc.CallOption) (*RouteExplanation, error) {
+	out := new(RouteExplanation)
+	err := c.cc.Invoke(ctx, "/protos.Hybrid/ExplainRoute", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HybridServer is the server API for Hybrid service.
 type HybridServer interface {
 	GetVersion(context.Context, *empty.Empty) (*Version, error)
@@ -778,6 +1517,16 @@ type HybridServer interface {
 	GetVerifyKeys(context.Context, *VerifyKeySliceRequest) (*AuthKeySliceReply, error)
 	FindVerifyKey(context.Context, *VerifyKeyIdRequest) (*authstore.AuthKey, error)
 	DeleteVerifyKey(context.Context, *VerifyKeyIdRequest) (*empty.Empty, error)
+	AddProxyAuthKey(context.Context, *AddProxyAuthKeyRequest) (*AddVerifyKeyReply, error)
+	GetProxyAuthKeys(context.Context, *VerifyKeySliceRequest) (*AuthKeySliceReply, error)
+	DeleteProxyAuthKey(context.Context, *VerifyKeyIdRequest) (*empty.Empty, error)
+	SetRateLimit(context.Context, *RateLimitRequest) (*empty.Empty, error)
+	// GetProxyStatus sends current status of proxies, then every probe result.
+	GetProxyStatus(*ProxyStatusRequest, Hybrid_GetProxyStatusServer) error
+	// ReloadRules reloads rule dirs of AdpRouters without restarting.
+	ReloadRules(context.Context, *ReloadRulesRequest) (*ReloadRulesReply, error)
+	// ExplainRoute dry runs routers with the url without dialing.
+	ExplainRoute(context.Context, *ExplainRouteRequest) (*RouteExplanation, error)
 }
 
 func RegisterHybridServer(s *grpc.Server, srv HybridServer) {
@@ -1108,6 +1857,135 @@ func _Hybrid_DeleteVerifyKey_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Hybrid_AddProxyAuthKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddProxyAuthKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).AddProxyAuthKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/AddProxyAuthKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).AddProxyAuthKey(ctx, req.(*AddProxyAuthKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hybrid_GetProxyAuthKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyKeySliceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).GetProxyAuthKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/GetProxyAuthKeys",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).GetProxyAuthKeys(ctx, req.(*VerifyKeySliceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hybrid_DeleteProxyAuthKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyKeyIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).DeleteProxyAuthKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/DeleteProxyAuthKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).DeleteProxyAuthKey(ctx, req.(*VerifyKeyIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hybrid_SetRateLimit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RateLimitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).SetRateLimit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/SetRateLimit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).SetRateLimit(ctx, req.(*RateLimitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hybrid_GetProxyStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProxyStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HybridServer).GetProxyStatus(m, &hybridGetProxyStatusServer{stream})
+}
+
+type Hybrid_GetProxyStatusServer interface {
+	Send(*ProxyStatus) error
+	grpc.ServerStream
+}
+
+type hybridGetProxyStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *hybridGetProxyStatusServer) Send(m *ProxyStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Hybrid_ReloadRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).ReloadRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/ReloadRules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).ReloadRules(ctx, req.(*ReloadRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hybrid_ExplainRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExplainRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HybridServer).ExplainRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protos.Hybrid/ExplainRoute",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HybridServer).ExplainRoute(ctx, req.(*ExplainRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Hybrid_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Hybrid",
 	HandlerType: (*HybridServer)(nil),
@@ -1184,67 +2062,134 @@ var _Hybrid_serviceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteVerifyKey",
 			Handler:    _Hybrid_DeleteVerifyKey_Handler,
 		},
+		{
+			MethodName: "AddProxyAuthKey",
+			Handler:    _Hybrid_AddProxyAuthKey_Handler,
+		},
+		{
+			MethodName: "GetProxyAuthKeys",
+			Handler:    _Hybrid_GetProxyAuthKeys_Handler,
+		},
+		{
+			MethodName: "DeleteProxyAuthKey",
+			Handler:    _Hybrid_DeleteProxyAuthKey_Handler,
+		},
+		{
+			MethodName: "SetRateLimit",
+			Handler:    _Hybrid_SetRateLimit_Handler,
+		},
+		{
+			MethodName: "ReloadRules",
+			Handler:    _Hybrid_ReloadRules_Handler,
+		},
+		{
+			MethodName: "ExplainRoute",
+			Handler:    _Hybrid_ExplainRoute_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetProxyStatus",
+			Handler:       _Hybrid_GetProxyStatus_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protos/grpc.proto",
 }
 
-func init() { proto.RegisterFile("protos/grpc.proto", fileDescriptor_grpc_0f66c3eff413103d) }
-
-var fileDescriptor_grpc_0f66c3eff413103d = []byte{
-	// 853 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x56, 0xdd, 0x4f, 0xe3, 0x46,
-	0x10, 0x2f, 0xe4, 0x7b, 0x48, 0x08, 0x2c, 0x10, 0xa5, 0x46, 0x54, 0xd4, 0xad, 0x54, 0x9e, 0x12,
-	0x89, 0xa2, 0xd2, 0x52, 0xa9, 0x52, 0xf8, 0xa6, 0xf4, 0x81, 0x3a, 0xa5, 0x95, 0xda, 0x87, 0xc8,
-	0xb1, 0x37, 0x66, 0x15, 0x27, 0xeb, 0xee, 0x6e, 0x0e, 0xcc, 0x1f, 0x7c, 0x7f, 0xc4, 0x3d, 0xdd,
-	0x7e, 0xd8, 0x4e, 0x8e, 0x5c, 0x10, 0x70, 0x4f, 0x99, 0xf9, 0xcd, 0xcc, 0x6f, 0x66, 0x67, 0x77,
-	0x26, 0x86, 0xf5, 0x88, 0x51, 0x41, 0x79, 0x3b, 0x60, 0x91, 0xd7, 0xd2, 0x32, 0x2a, 0x1a, 0xc8,
-	0xda, 0x0e, 0x28, 0x0d, 0x42, 0xdc, 0xd6, 0x6a, 0x7f, 0x32, 0x68, 0xe3, 0x51, 0x24, 0x62, 0xe3,
-	0x64, 0x6d, 0x24, 0x71, 0x1e, 0x1d, 0x0f, 0x48, 0x90, 0x80, 0x8d, 0x04, 0x74, 0x27, 0xe2, 0x8e,
-	0x0b, 0xca, 0xb0, 0xc1, 0xed, 0xf7, 0x4b, 0x50, 0xfa, 0x1b, 0x33, 0x4e, 0xe8, 0x18, 0x1d, 0x40,
-	0xe3, 0x2e, 0xee, 0x33, 0xe2, 0xf7, 0xb8, 0x60, 0xd8, 0x1d, 0xf5, 0xb4, 0x8b, 0x47, 0xc3, 0xe6,
-	0xd2, 0xee, 0xd2, 0x5e, 0xc5, 0xd9, 0x34, 0xd6, 0xae, 0x36, 0xde, 0x24, 0x36, 0x84, 0x20, 0x4f,
-	0xa2, 0x01, 0x6f, 0x2e, 0x6b, 0x1f, 0x2d, 0xa3, 0x6d, 0xa8, 0xa8, 0xdf, 0x1e, 0xc3, 0x11, 0x6d,
-	0xe6, 0xa4, 0xa1, 0xe0, 0x94, 0x15, 0xe0, 0x48, 0x1d, 0xfd, 0x00, 0xf5, 0x90, 0xf4, 0x6f, 0xf6,
-	0x6f, 0xa6, 0xfc, 0x79, 0x1d, 0xbb, 0x6a, 0xe0, 0x8c, 0x59, 0xb2, 0x04, 0xb4, 0x67, 0xc0, 0x66,
-	0x41, 0xbb, 0x94, 0x03, 0xfa, 0x87, 0xd6, 0x51, 0x03, 0x8a, 0x01, 0x0d, 0xdd, 0x71, 0xd0, 0x2c,
-	0x6a, 0x4b, 0xa2, 0x29, 0x9c, 0xc7, 0x5c, 0xe0, 0x51, 0xb3, 0x64, 0x70, 0xa3, 0xd9, 0x36, 0x54,
-	0xbb, 0xc2, 0x65, 0xc2, 0xc1, 0xff, 0x4f, 0x30, 0x17, 0xaa, 0x6c, 0x46, 0xa9, 0x48, 0x8e, 0xa6,
-	0x65, 0xbb, 0x03, 0x2b, 0xc7, 0x64, 0xec, 0xa7, 0x2e, 0x4d, 0x28, 0x8d, 0xb1, 0xb8, 0xa7, 0x6c,
-	0x98, 0x78, 0xa5, 0xaa, 0xb2, 0xb8, 0xbe, 0xcf, 0x30, 0x4f, 0x8f, 0x9d, 0xaa, 0xf6, 0x37, 0x50,
-	0x56, 0x14, 0xa7, 0xae, 0x70, 0x55, 0x8a, 0xbe, 0x94, 0xb5, 0x4b, 0xcd, 0xd1, 0xb2, 0xfd, 0x27,
-	0xd4, 0x8e, 0x5d, 0x6f, 0x38, 0x89, 0x9e, 0xa9, 0x03, 0xad, 0x41, 0x4e, 0x04, 0x8f, 0x09, 0xb5,
-	0x12, 0x91, 0x05, 0xe5, 0xc8, 0xe5, 0x5c, 0x26, 0xf7, 0x75, 0x3f, 0x65, 0x27, 0x52, 0xdd, 0x66,
-	0xb0, 0xd1, 0xf1, 0x7d, 0x79, 0x89, 0x64, 0x10, 0x5f, 0xe3, 0x38, 0x25, 0x96, 0x24, 0x43, 0x1c,
-	0x6b, 0xde, 0xaa, 0xa3, 0x44, 0x95, 0x4a, 0xb8, 0x81, 0x2a, 0x39, 0xa7, 0x52, 0x29, 0x59, 0x61,
-	0x3e, 0xe6, 0x5e, 0x42, 0xaa, 0x65, 0xf4, 0x2d, 0x54, 0x43, 0x32, 0xc0, 0x3d, 0x8e, 0xe5, 0x13,
-	0xf2, 0xb9, 0xbe, 0x9d, 0x9a, 0xb3, 0xa2, 0xb0, 0xae, 0x81, 0x6c, 0x17, 0xd6, 0x3f, 0xcd, 0x19,
-	0x85, 0x31, 0x5a, 0x85, 0x65, 0xe2, 0xeb, 0x84, 0x79, 0x47, 0x4a, 0x68, 0x07, 0xc0, 0x93, 0x4f,
-	0x45, 0x60, 0xbf, 0xe7, 0x0a, 0x7d, 0x9a, 0x9c, 0x53, 0x49, 0x90, 0x8e, 0x50, 0x66, 0xfc, 0x10,
-	0x11, 0xd9, 0x36, 0x65, 0xce, 0x19, 0x73, 0x82, 0x74, 0x84, 0xfd, 0x1f, 0x6c, 0x65, 0xfc, 0xdd,
-	0x90, 0x78, 0x38, 0x3d, 0xd8, 0x26, 0x14, 0xb8, 0xba, 0xc9, 0x24, 0x93, 0x51, 0xd4, 0x41, 0x38,
-	0x79, 0xc4, 0x69, 0xb3, 0x95, 0xac, 0xae, 0x89, 0xe1, 0x77, 0xf2, 0x75, 0x63, 0x4d, 0x5f, 0x76,
-	0x52, 0xd5, 0xfe, 0x5d, 0xd6, 0x2f, 0x27, 0x61, 0x4a, 0xad, 0xea, 0xff, 0x0e, 0xf2, 0xb2, 0x4d,
-	0x5c, 0xf2, 0xe6, 0xf6, 0x56, 0xf6, 0xeb, 0x66, 0x42, 0x78, 0x2b, 0x71, 0x74, 0xb4, 0x51, 0xb5,
-	0x15, 0x33, 0x96, 0xde, 0x8d, 0x14, 0xed, 0xef, 0x01, 0x65, 0x85, 0x5e, 0x65, 0x8f, 0xe7, 0x49,
-	0x33, 0xf6, 0x3f, 0x94, 0xa1, 0x78, 0xa9, 0xe7, 0x07, 0x1d, 0x02, 0x5c, 0x60, 0x91, 0x4e, 0x5d,
-	0xa3, 0x65, 0x86, 0xb9, 0x95, 0x0e, 0x73, 0xeb, 0x4c, 0x0d, 0xb3, 0x95, 0xe5, 0x4f, 0x1c, 0xed,
-	0xaf, 0xd0, 0xaf, 0x50, 0x93, 0x81, 0x27, 0x7a, 0xae, 0xff, 0x62, 0x18, 0xa3, 0xcd, 0xd4, 0x67,
-	0xf6, 0x69, 0x5b, 0x28, 0x45, 0xa7, 0x9e, 0x32, 0xf8, 0x10, 0x0a, 0xda, 0x6b, 0x41, 0xd0, 0x82,
-	0x32, 0x64, 0xe0, 0xcf, 0x90, 0xef, 0x0a, 0x1a, 0x2d, 0x2c, 0x74, 0x71, 0xe4, 0x29, 0xac, 0xfd,
-	0xe3, 0x12, 0x71, 0x3b, 0x16, 0x24, 0x54, 0x14, 0x11, 0xf6, 0xdf, 0xc0, 0x72, 0x02, 0x75, 0x35,
-	0x52, 0xe6, 0x30, 0x72, 0x39, 0x3c, 0xc4, 0x6f, 0x20, 0x39, 0x83, 0xf5, 0xdb, 0x71, 0xff, 0x8b,
-	0x69, 0x0e, 0xa0, 0xa2, 0x6a, 0x31, 0xe1, 0x1b, 0x69, 0x23, 0x67, 0x96, 0x86, 0xb5, 0x36, 0x0b,
-	0xaa, 0x35, 0x20, 0xa3, 0x7e, 0x32, 0x7b, 0xe5, 0x4a, 0x6e, 0xc0, 0x4e, 0x44, 0x5e, 0x1e, 0x77,
-	0x64, 0x4e, 0xae, 0xe2, 0x2e, 0xe4, 0xd0, 0xdc, 0xbb, 0xaf, 0xc8, 0x79, 0x00, 0x45, 0x73, 0x60,
-	0x34, 0x67, 0x7d, 0xe6, 0x7c, 0xbf, 0x41, 0xf5, 0x2a, 0xd9, 0xd3, 0xe7, 0xdc, 0x1b, 0xbe, 0xfa,
-	0xad, 0xfc, 0x02, 0x45, 0xb3, 0xde, 0xd0, 0x56, 0x96, 0x75, 0x76, 0xdd, 0x3d, 0x13, 0x7a, 0x04,
-	0x25, 0x07, 0xeb, 0xbf, 0xa6, 0xd7, 0xc7, 0x5e, 0x42, 0x75, 0x76, 0x1d, 0xa1, 0xed, 0x6c, 0x76,
-	0xe7, 0x17, 0xa3, 0xf5, 0xf5, 0xe7, 0x8d, 0x72, 0x03, 0x48, 0xa6, 0x6b, 0x3d, 0x62, 0x19, 0xcc,
-	0xd1, 0xce, 0xcc, 0x18, 0xce, 0x2f, 0xa3, 0x19, 0xb2, 0xa7, 0xeb, 0x44, 0x77, 0xb3, 0x76, 0x2e,
-	0x7b, 0x3e, 0xad, 0xcb, 0x9a, 0x23, 0xcb, 0x16, 0x86, 0xf5, 0x74, 0xdf, 0xc8, 0xf8, 0x0b, 0xa8,
-	0x9f, 0xe2, 0x10, 0x0b, 0xfc, 0x32, 0x86, 0x85, 0xfd, 0x39, 0xb6, 0xff, 0xdd, 0x0d, 0x88, 0xb8,
-	0x9b, 0xf4, 0x5b, 0x1e, 0x1d, 0xa9, 0x8f, 0x05, 0xb9, 0x63, 0x07, 0xf4, 0xa1, 0x6d, 0xfe, 0xd0,
-	0xf5, 0x17, 0x46, 0xdf, 0x7c, 0x5b, 0xfc, 0xf8, 0x31, 0x00, 0x00, 0xff, 0xff, 0x98, 0x89, 0x5c,
-	0x78, 0x77, 0x08, 0x00, 0x00,
+func init() { proto.RegisterFile("protos/grpc.proto", fileDescriptor_grpc_a4a8be70e277f17b) }
+
+var fileDescriptor_grpc_a4a8be70e277f17b = []byte{
+	// 1454 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa4, 0x57, 0x4b, 0x6f, 0x1b, 0xc9,
+	0x11, 0x26, 0xc5, 0x87, 0xc8, 0x22, 0x29, 0x51, 0x2d, 0x5b, 0x60, 0x28, 0xd8, 0x50, 0x26, 0x01,
+	0x6c, 0x18, 0x01, 0x15, 0x28, 0x8a, 0x9d, 0x38, 0x40, 0x00, 0x3d, 0x68, 0x59, 0xb6, 0x6c, 0x2b,
+	0x43, 0xd9, 0x49, 0x76, 0x0f, 0xc4, 0x90, 0x53, 0xa4, 0x06, 0x1c, 0x72, 0x66, 0xbb, 0x9b, 0x6b,
+	0xd1, 0xb7, 0xfd, 0x15, 0xbb, 0xd7, 0x3d, 0xed, 0x65, 0x7f, 0xd7, 0xfe, 0x8e, 0x45, 0xf5, 0x63,
+	0x38, 0x12, 0x25, 0xc1, 0xb2, 0x4f, 0xd3, 0xf5, 0xe8, 0xea, 0xef, 0xab, 0xae, 0xee, 0xae, 0x81,
+	0xb5, 0x98, 0x47, 0x32, 0x12, 0xdb, 0x43, 0x1e, 0xf7, 0x5b, 0x6a, 0xcc, 0x8a, 0x5a, 0xd5, 0xdc,
+	0x1c, 0x46, 0xd1, 0x30, 0xc4, 0x6d, 0x25, 0xf6, 0xa6, 0x83, 0x6d, 0x1c, 0xc7, 0x72, 0xa6, 0x9d,
+	0x9a, 0xeb, 0x66, 0x5e, 0x3f, 0x9a, 0x0c, 0x82, 0xa1, 0x51, 0x6e, 0x18, 0xa5, 0x37, 0x95, 0xe7,
+	0x42, 0x46, 0x1c, 0xb5, 0xde, 0xf9, 0x2d, 0x0b, 0xcb, 0x1f, 0x90, 0x8b, 0x20, 0x9a, 0xb0, 0x5d,
+	0xd8, 0x38, 0x9f, 0xf5, 0x78, 0xe0, 0x77, 0x85, 0xe4, 0xe8, 0x8d, 0xbb, 0xca, 0xa5, 0x1f, 0x85,
+	0x8d, 0xec, 0x56, 0xf6, 0x71, 0xd9, 0xbd, 0xa7, 0xad, 0x1d, 0x65, 0x3c, 0x35, 0x36, 0xc6, 0x20,
+	0x1f, 0xc4, 0x03, 0xd1, 0x58, 0x52, 0x3e, 0x6a, 0xcc, 0x36, 0xa1, 0x4c, 0xdf, 0x2e, 0xc7, 0x38,
+	0x6a, 0xe4, 0xb6, 0xb2, 0x8f, 0x0b, 0x6e, 0x89, 0x14, 0x2e, 0xc6, 0x11, 0x7b, 0x04, 0xab, 0x61,
+	0xd0, 0x3b, 0xdd, 0x39, 0x9d, 0xc7, 0xcf, 0xab, 0xb9, 0x2b, 0x5a, 0x9d, 0x44, 0xde, 0x84, 0xf2,
+	0x30, 0xea, 0x6a, 0x65, 0xa3, 0xa0, 0x5c, 0x4a, 0xc3, 0xe8, 0x44, 0xc9, 0x6c, 0x03, 0x8a, 0xc3,
+	0x28, 0xf4, 0x26, 0xc3, 0x46, 0x51, 0x59, 0x8c, 0x44, 0x7a, 0x31, 0x13, 0x12, 0xc7, 0x8d, 0x65,
+	0xad, 0xd7, 0x92, 0xe3, 0x40, 0xb5, 0x23, 0x3d, 0x2e, 0x5d, 0xfc, 0x6e, 0x8a, 0x42, 0x12, 0x6c,
+	0x1e, 0x45, 0xd2, 0x50, 0x53, 0x63, 0xe7, 0x97, 0x2c, 0x54, 0xf6, 0x83, 0x89, 0x6f, 0x7d, 0x1a,
+	0xb0, 0x3c, 0x41, 0xf9, 0x31, 0xe2, 0x23, 0xe3, 0x66, 0x45, 0xb2, 0x78, 0xbe, 0xcf, 0x51, 0x58,
+	0xde, 0x56, 0x64, 0x7f, 0x81, 0xfc, 0x38, 0xf2, 0x51, 0xb1, 0x5e, 0xd9, 0x69, 0xe8, 0x34, 0x8b,
+	0x56, 0x2a, 0x6c, 0xeb, 0x4d, 0xe4, 0xa3, 0xab, 0xbc, 0x9c, 0xa7, 0x90, 0x27, 0x89, 0x95, 0x20,
+	0xff, 0xf2, 0xec, 0xec, 0xb4, 0x9e, 0x61, 0x00, 0xc5, 0xce, 0xbb, 0x83, 0xd7, 0x9d, 0xbf, 0xd7,
+	0xb3, 0xac, 0x0a, 0x25, 0xb7, 0x7d, 0x78, 0xec, 0xb6, 0x0f, 0xce, 0xea, 0x4b, 0x64, 0x39, 0x3b,
+	0x75, 0xdf, 0xfd, 0xef, 0xff, 0xf5, 0x9c, 0xf3, 0x10, 0x4a, 0x14, 0xf1, 0xd0, 0x93, 0x1e, 0x31,
+	0xe9, 0x05, 0x13, 0x5f, 0x01, 0xa9, 0xb9, 0x6a, 0xec, 0xfc, 0x07, 0x6a, 0xfb, 0x5e, 0x7f, 0x34,
+	0x8d, 0x6f, 0xa1, 0xcb, 0xea, 0x90, 0x93, 0xc3, 0x4f, 0x86, 0x00, 0x0d, 0x59, 0x13, 0x4a, 0xb1,
+	0x27, 0xc4, 0xc7, 0x88, 0xfb, 0x8a, 0x40, 0xd9, 0x4d, 0x64, 0x87, 0xc3, 0xfa, 0x9e, 0xef, 0x7f,
+	0x40, 0x1e, 0x0c, 0x66, 0xaf, 0x71, 0x66, 0x03, 0xd7, 0x21, 0x37, 0xc2, 0x99, 0x8a, 0x5b, 0x75,
+	0x69, 0x48, 0x4b, 0x49, 0x6f, 0x48, 0x89, 0xc9, 0xd1, 0x52, 0x34, 0x26, 0x9d, 0x8f, 0xa2, 0x6f,
+	0x82, 0xaa, 0x31, 0xfb, 0x23, 0x54, 0xc3, 0x60, 0x80, 0x5d, 0x81, 0xfd, 0x68, 0xe2, 0x0b, 0x55,
+	0x04, 0x35, 0xb7, 0x42, 0xba, 0x8e, 0x56, 0x39, 0x1e, 0xac, 0x5d, 0x5e, 0x33, 0x0e, 0x67, 0x6c,
+	0x05, 0x96, 0x02, 0x5f, 0x2d, 0x98, 0x77, 0x97, 0x02, 0x9f, 0x3d, 0x00, 0xe8, 0x73, 0xf4, 0x24,
+	0xfa, 0x5d, 0x4f, 0x2a, 0x36, 0x39, 0xb7, 0x6c, 0x34, 0x7b, 0x92, 0xcc, 0x78, 0x11, 0x07, 0x1c,
+	0x05, 0x99, 0x73, 0xda, 0x6c, 0x34, 0x7b, 0xd2, 0xf9, 0x16, 0xee, 0x27, 0xf1, 0x3b, 0x61, 0xd0,
+	0x47, 0x4b, 0xec, 0x1e, 0x14, 0x04, 0x15, 0x8c, 0x59, 0x49, 0x0b, 0x44, 0x44, 0x04, 0x9f, 0xd0,
+	0x26, 0x9b, 0xc6, 0x54, 0x0c, 0x1c, 0xbf, 0x47, 0x2e, 0xf4, 0xae, 0x97, 0x5c, 0x2b, 0x3a, 0xaf,
+	0x60, 0x6d, 0x6f, 0x2a, 0xcf, 0xe7, 0xa1, 0x09, 0xff, 0x9f, 0x20, 0x3f, 0xc2, 0x99, 0x68, 0x64,
+	0xb7, 0x72, 0x8f, 0x2b, 0x3b, 0xab, 0xb6, 0x42, 0x8c, 0xa3, 0xab, 0x8c, 0x94, 0x56, 0xe4, 0xdc,
+	0xee, 0x0d, 0x72, 0xee, 0xfc, 0x19, 0x58, 0x02, 0xf4, 0x38, 0x29, 0xd1, 0x2b, 0xc9, 0x70, 0x7e,
+	0xcc, 0xc2, 0xc6, 0x9e, 0xef, 0x9f, 0xf2, 0xe8, 0x62, 0x66, 0x23, 0x1a, 0xd7, 0x26, 0x94, 0xa6,
+	0x02, 0xf9, 0xc4, 0x1b, 0xa3, 0x29, 0x83, 0x44, 0x56, 0xa7, 0x06, 0xfb, 0x1c, 0xa5, 0x59, 0xd1,
+	0x48, 0xc9, 0x5e, 0xe6, 0xae, 0xd9, 0xcb, 0xfc, 0x2d, 0x7b, 0x59, 0x58, 0xdc, 0xcb, 0x5f, 0xb3,
+	0x50, 0x77, 0x3d, 0x89, 0x27, 0xc1, 0x38, 0x48, 0x4e, 0xe1, 0x2e, 0x14, 0x44, 0x3f, 0x8a, 0x35,
+	0xa0, 0x95, 0x9d, 0x87, 0x36, 0x19, 0x57, 0x1d, 0x5b, 0x1d, 0xf2, 0x72, 0xb5, 0x33, 0x21, 0x50,
+	0x2c, 0xcc, 0x95, 0xa3, 0x18, 0x38, 0x50, 0x1b, 0xf5, 0xba, 0x31, 0x72, 0x83, 0x41, 0x6d, 0x45,
+	0xcd, 0xad, 0x8c, 0x7a, 0xa7, 0xc8, 0x35, 0x06, 0xe7, 0x09, 0x14, 0x54, 0x1c, 0x3a, 0x4a, 0x47,
+	0x27, 0xef, 0xf6, 0xf7, 0x4e, 0xea, 0x19, 0x56, 0x86, 0x82, 0x3e, 0x55, 0x59, 0x52, 0x1f, 0x9c,
+	0x1c, 0xb7, 0xdf, 0x9e, 0xd5, 0x97, 0x9c, 0x27, 0xc0, 0x54, 0x12, 0x3b, 0xd2, 0x93, 0x53, 0x91,
+	0x2a, 0x0a, 0x5a, 0x4d, 0x6f, 0x5e, 0xd9, 0xd5, 0x82, 0xf3, 0x73, 0x16, 0x2a, 0x29, 0xe7, 0x04,
+	0x5f, 0x36, 0x85, 0xaf, 0x01, 0xcb, 0xe7, 0xe8, 0x85, 0xf2, 0x7c, 0xa6, 0x60, 0x97, 0x5c, 0x2b,
+	0xaa, 0xfa, 0x3d, 0xc7, 0xfe, 0x48, 0xd7, 0xaf, 0x29, 0x50, 0xa3, 0xd1, 0xf5, 0x1b, 0x7a, 0x12,
+	0x27, 0xfd, 0x59, 0x77, 0x6c, 0x0f, 0x49, 0xd9, 0x68, 0xde, 0x08, 0x42, 0x34, 0xf0, 0x82, 0xd0,
+	0xa6, 0x5c, 0x0b, 0xb6, 0x7c, 0x8a, 0xf3, 0xf2, 0x69, 0x01, 0x73, 0x31, 0x8c, 0x3c, 0xdf, 0x9d,
+	0x86, 0x28, 0x52, 0x37, 0x1c, 0x8f, 0xa6, 0x12, 0xb9, 0x65, 0x64, 0x45, 0x87, 0x43, 0xcd, 0x78,
+	0xd2, 0x24, 0xf4, 0xa9, 0x44, 0xb4, 0xcd, 0xd0, 0x32, 0x12, 0x01, 0xe0, 0xe4, 0x68, 0x8e, 0x84,
+	0x16, 0xa8, 0x20, 0x62, 0x8f, 0x0b, 0xec, 0x22, 0xe7, 0x11, 0xb7, 0x05, 0x54, 0x51, 0xba, 0xb6,
+	0x52, 0x59, 0x8c, 0xf9, 0x39, 0xc6, 0x03, 0xa8, 0x5f, 0xc2, 0x48, 0xa7, 0x65, 0xfb, 0x32, 0xc2,
+	0xca, 0xce, 0xfd, 0xa4, 0x46, 0xd2, 0xf0, 0xe6, 0xc0, 0x1f, 0xc1, 0x7a, 0xfb, 0x22, 0x0e, 0xbd,
+	0x60, 0xe2, 0x92, 0x26, 0x75, 0x4f, 0x4d, 0xb9, 0x7d, 0xc9, 0x68, 0xe8, 0xfc, 0x94, 0x85, 0xb2,
+	0x72, 0xe9, 0x48, 0x8c, 0x6f, 0xa4, 0xc7, 0x20, 0x4f, 0xc9, 0x36, 0x9b, 0xa6, 0xc6, 0x44, 0x39,
+	0xa6, 0xed, 0x36, 0xd7, 0x99, 0x16, 0xc8, 0x93, 0xb8, 0xdb, 0x73, 0x41, 0x63, 0x7a, 0xc2, 0xe8,
+	0xdb, 0x1d, 0x04, 0x21, 0xda, 0x27, 0x8c, 0x14, 0x2f, 0x82, 0x50, 0x95, 0x84, 0x18, 0x05, 0x71,
+	0x8c, 0xbe, 0xd9, 0x28, 0x2b, 0x3a, 0x01, 0xd4, 0x15, 0x32, 0x45, 0x64, 0xe2, 0x49, 0x7a, 0x9d,
+	0x1f, 0xd1, 0x7d, 0x84, 0xb1, 0x4d, 0xc3, 0x5a, 0x92, 0x06, 0x4b, 0xc1, 0xd5, 0xf6, 0x14, 0x93,
+	0xa5, 0xab, 0x1b, 0xb5, 0x88, 0x7a, 0xe7, 0x87, 0x1a, 0x14, 0x5f, 0xaa, 0x77, 0x9d, 0x3d, 0x03,
+	0x38, 0x42, 0x69, 0xbb, 0x81, 0x8d, 0x96, 0x6e, 0x32, 0x5a, 0xb6, 0xc9, 0x68, 0xb5, 0xa9, 0xc9,
+	0x68, 0x26, 0x17, 0x96, 0x71, 0x74, 0x32, 0xec, 0x5f, 0x50, 0x3b, 0x42, 0x79, 0xa0, 0xfa, 0x8d,
+	0x33, 0x8e, 0xc8, 0xee, 0x59, 0x9f, 0xf4, 0x93, 0xdb, 0x64, 0x56, 0x3b, 0xf7, 0x74, 0x32, 0xec,
+	0x19, 0x14, 0x94, 0xd7, 0x0d, 0x93, 0x6e, 0x80, 0xe1, 0x64, 0xd8, 0x3f, 0x20, 0xdf, 0x91, 0x51,
+	0x7c, 0x23, 0xd0, 0x9b, 0x67, 0x1e, 0x42, 0xfd, 0xbf, 0x5e, 0x20, 0xdf, 0x4f, 0x64, 0x10, 0x52,
+	0x88, 0x98, 0xca, 0xfb, 0xce, 0x51, 0x0e, 0x60, 0x95, 0xde, 0x60, 0x4d, 0x46, 0x1d, 0xff, 0x2f,
+	0x08, 0xd2, 0x86, 0xb5, 0xf7, 0x93, 0xde, 0x57, 0x87, 0xd9, 0x85, 0x32, 0x61, 0xd1, 0xd3, 0xd7,
+	0xaf, 0x69, 0x3a, 0x9a, 0xf5, 0xb4, 0x92, 0xfa, 0x06, 0x27, 0xc3, 0x9e, 0xea, 0x76, 0xe7, 0x38,
+	0x1e, 0x88, 0xbd, 0x38, 0xf8, 0xfc, 0x79, 0xcf, 0x61, 0xd5, 0xce, 0x3b, 0xf2, 0x24, 0x7e, 0xf4,
+	0xee, 0xb0, 0xe6, 0x2e, 0x14, 0x35, 0x61, 0xb6, 0x60, 0xbd, 0x85, 0xdf, 0xbf, 0xa1, 0x7a, 0x6c,
+	0xfa, 0xc7, 0x17, 0xa2, 0x3f, 0xba, 0x73, 0xad, 0xfc, 0x13, 0x8a, 0xba, 0x1f, 0x62, 0xc9, 0xf5,
+	0x71, 0xa9, 0x3f, 0xba, 0x65, 0xea, 0x73, 0x58, 0x76, 0x51, 0xb5, 0xcc, 0x77, 0x9f, 0xfb, 0x12,
+	0xaa, 0xe9, 0xfe, 0x85, 0x6d, 0x26, 0x8f, 0xfd, 0x62, 0x27, 0xd5, 0xfc, 0xc3, 0xf5, 0xc6, 0x38,
+	0xa4, 0x48, 0xaf, 0xd5, 0x11, 0x4b, 0xd4, 0x82, 0x3d, 0x48, 0x1d, 0xc3, 0xc5, 0xee, 0x25, 0x15,
+	0xec, 0x6a, 0xff, 0xa1, 0xb2, 0x59, 0x7b, 0x11, 0x4c, 0x52, 0xb8, 0x9a, 0x0b, 0xc1, 0x92, 0x0e,
+	0xa3, 0x79, 0xb5, 0x41, 0x71, 0x32, 0xec, 0x08, 0x56, 0x0f, 0x31, 0x44, 0x89, 0x9f, 0x17, 0xe1,
+	0xe6, 0xfc, 0xbc, 0x85, 0xd5, 0x2b, 0xcd, 0x0a, 0x7b, 0x98, 0xca, 0xc2, 0x35, 0x5d, 0xcc, 0xed,
+	0x59, 0x7a, 0x0b, 0xf5, 0x23, 0x94, 0xe9, 0x69, 0x5f, 0x97, 0xa8, 0x57, 0xc0, 0x34, 0xd1, 0x4b,
+	0x10, 0xbf, 0x8c, 0xeb, 0x3e, 0x54, 0x3b, 0x28, 0x93, 0xc6, 0x86, 0x35, 0x6e, 0xea, 0x75, 0x6e,
+	0xbd, 0x2d, 0x56, 0x2c, 0x3f, 0xd3, 0x6a, 0x24, 0x58, 0x16, 0x9b, 0x95, 0xe6, 0xfa, 0x35, 0x36,
+	0x27, 0xf3, 0xd7, 0x2c, 0x6b, 0x43, 0x25, 0xf5, 0xce, 0xce, 0x63, 0x2c, 0x36, 0x08, 0xcd, 0xc6,
+	0xb5, 0x36, 0x9d, 0x9d, 0x23, 0xa8, 0xa6, 0x5f, 0xda, 0x79, 0x75, 0x5f, 0xf3, 0xfe, 0xa6, 0x02,
+	0x5d, 0x79, 0xd8, 0x9c, 0xcc, 0xbe, 0xf3, 0xcd, 0xd6, 0x30, 0x90, 0xe7, 0xd3, 0x5e, 0xab, 0x1f,
+	0x8d, 0xe9, 0x5f, 0x36, 0xe0, 0x38, 0x88, 0x2e, 0xb6, 0xf5, 0xff, 0xa6, 0xfa, 0x01, 0xee, 0xe9,
+	0x5f, 0xdf, 0xbf, 0xfd, 0x3e, 0x00, 0xf3, 0xe8, 0xb2, 0x18, 0x16, 0x0f, 0x00, 0x00,
 }
@@ -0,0 +1,93 @@
+package grpc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/authstore"
+	"go.uber.org/zap"
+)
+
+// ProxyAuthVerifier verifies Proxy-Authorization with keys in store.
+// Key is sha256 of "Basic username:password" or "Bearer token", so that
+// secrets are never stored or listed.
+type ProxyAuthVerifier struct {
+	log   *zap.Logger
+	store *authstore.KeyStore
+
+	mu sync.RWMutex
+	// keys: hashed key => ExpiresAt
+	keys map[string]int64
+}
+
+func NewProxyAuthVerifier(store *authstore.KeyStore, log *zap.Logger) (*ProxyAuthVerifier, error) {
+	v := &ProxyAuthVerifier{
+		log:   log,
+		store: store,
+	}
+	err := v.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ProxyAuthKey returns the stored key of username and secret.
+func ProxyAuthKey(username, secret string) []byte {
+	if username == "" {
+		return hashProxyAuthKey("Bearer " + secret)
+	}
+	return hashProxyAuthKey("Basic " + username + ":" + secret)
+}
+
+func hashProxyAuthKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// Reload must be called after store changed.
+func (v *ProxyAuthVerifier) Reload() error {
+	const size = 100
+	keys := make(map[string]int64)
+	var start uint64
+	for {
+		aks, err := v.store.Slice(start, size, false)
+		if err != nil {
+			return err
+		}
+		for _, ak := range aks {
+			keys[string(ak.Key)] = ak.ExpiresAt
+			start = ak.Id + 1
+		}
+		if len(aks) < size {
+			break
+		}
+	}
+
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func (v *ProxyAuthVerifier) Verify(authorization string) bool {
+	key := authorization
+	if strings.HasPrefix(authorization, "Basic ") {
+		decoded, err := base64.StdEncoding.DecodeString(authorization[len("Basic "):])
+		if err != nil {
+			v.log.Debug("ProxyAuth", zap.Error(err))
+			return false
+		}
+		key = "Basic " + string(decoded)
+	} else if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	v.mu.RLock()
+	expiresAt, ok := v.keys[string(hashProxyAuthKey(key))]
+	v.mu.RUnlock()
+	return ok && (expiresAt == 0 || expiresAt > time.Now().Unix())
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/empirefox/hybrid/config"
 	"github.com/empirefox/hybrid/pkg/authstore"
 	"github.com/empirefox/hybrid/pkg/ipfs"
+	"github.com/empirefox/hybrid/pkg/proxy"
+	"github.com/empirefox/hybrid/pkg/tproxy"
 	"github.com/golang/protobuf/ptypes/empty"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -32,6 +34,7 @@ var (
 	ErrServiceAlreadyStarted = errors.New("service already started")
 	ErrIpfsRepoLocked        = errors.New("ipfs repo locked")
 	ErrNotImplememted        = errors.New("grpc api not implemented")
+	ErrHealthCheckDisabled   = errors.New("health check disabled")
 )
 
 type ListenFunc func(network, address string) (net.Listener, error)
@@ -158,7 +161,22 @@ func (s *Server) UnbindConfigProxy(ctx context.Context, req *empty.Empty) (*empt
 }
 
 func (s *Server) BindProxy(_ context.Context, req *BindRequest) (*BindData, error) {
-	return s.doBind(req, s.service.node.StartProxy)
+	switch req.Mode {
+	case BindRequest_HTTP:
+		return s.doBind(req, s.service.node.StartProxy)
+	case BindRequest_SOCKS5:
+		return s.doBind(req, s.service.node.StartSocks5Proxy)
+	case BindRequest_REDIRECT:
+		return s.doBind(req, func(id uint32, ln net.Listener) {
+			s.service.node.StartTransparentProxy(id, ln, tproxy.OriginalDst)
+		})
+	case BindRequest_TPROXY:
+		return s.doBindListen(req, tproxy.Listen, func(id uint32, ln net.Listener) {
+			s.service.node.StartTransparentProxy(id, ln, tproxy.LocalAddr)
+		})
+	default:
+		return nil, ErrNotImplememted
+	}
 }
 func (s *Server) BindIpfsApi(_ context.Context, req *BindRequest) (*BindData, error) {
 	return s.doBind(req, s.service.node.StartIpfsApi)
@@ -168,13 +186,17 @@ func (s *Server) BindIpfsGateway(_ context.Context, req *BindRequest) (*BindData
 }
 
 func (s *Server) doBind(req *BindRequest, startServe func(uint32, net.Listener)) (*BindData, error) {
+	return s.doBindListen(req, s.config.Listen, startServe)
+}
+
+func (s *Server) doBindListen(req *BindRequest, listen ListenFunc, startServe func(uint32, net.Listener)) (*BindData, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.service == nil {
 		return nil, ErrNoService
 	}
 
-	ln, err := s.config.Listen(req.Network, req.Address)
+	ln, err := listen(req.Network, req.Address)
 	if err != nil {
 		return nil, err
 	}
@@ -286,3 +308,214 @@ func (s *Server) DeleteVerifyKey(_ context.Context, req *VerifyKeyIdRequest) (*e
 	}
 	return nil, s.service.verifyKeystore.Delete(req.Id)
 }
+
+func (s *Server) AddProxyAuthKey(_ context.Context, req *AddProxyAuthKeyRequest) (*AddVerifyKeyReply, error) {
+	now := time.Now().Unix()
+	ak := authstore.AuthKey{
+		Key:       ProxyAuthKey(req.Username, req.Secret),
+		Tags:      req.Tags,
+		Desc:      req.Desc,
+		CreatedAt: now,
+	}
+	if req.LifeSeconds != 0 {
+		ak.ExpiresAt = now + int64(req.LifeSeconds)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service == nil {
+		return nil, ErrNoService
+	}
+
+	err := s.service.proxyAuthStore.Save(&ak)
+	if err != nil {
+		return nil, err
+	}
+	err = s.service.proxyAuth.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddVerifyKeyReply{
+		Id:        ak.Id,
+		CreatedAt: ak.CreatedAt,
+		ExpiresAt: ak.ExpiresAt,
+	}, nil
+}
+func (s *Server) GetProxyAuthKeys(_ context.Context, req *VerifyKeySliceRequest) (*AuthKeySliceReply, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service == nil {
+		return nil, ErrNoService
+	}
+
+	aks, err := s.service.proxyAuthStore.Slice(req.Start, int(req.Size), req.Reverse)
+	var errmsg string
+	if err != nil {
+		errmsg = err.Error()
+	}
+	return &AuthKeySliceReply{
+		Keys: aks,
+		Err:  errmsg,
+	}, nil
+}
+func (s *Server) DeleteProxyAuthKey(_ context.Context, req *VerifyKeyIdRequest) (*empty.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service == nil {
+		return nil, ErrNoService
+	}
+
+	err := s.service.proxyAuthStore.Delete(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.service.proxyAuth.Reload()
+}
+
+func (s *Server) SetRateLimit(_ context.Context, req *RateLimitRequest) (*empty.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.service == nil {
+		return nil, ErrNoService
+	}
+
+	rl := s.service.node.RateLimits()
+	bps := int(req.KbPerSecond) << 10
+	switch req.Scope {
+	case RateLimitRequest_GLOBAL:
+		rl.SetGlobal(bps)
+	case RateLimitRequest_PROXY:
+		rl.SetProxy(req.Name, bps)
+	case RateLimitRequest_CLIENT:
+		rl.SetClient(bps)
+	default:
+		return nil, ErrNotImplememted
+	}
+	return nil, nil
+}
+
+func (s *Server) GetProxyStatus(req *ProxyStatusRequest, stream Hybrid_GetProxyStatusServer) error {
+	s.mu.Lock()
+	if s.service == nil {
+		s.mu.Unlock()
+		return ErrNoService
+	}
+	health := s.service.node.Health()
+	s.mu.Unlock()
+	if health == nil {
+		return ErrHealthCheckDisabled
+	}
+
+	names := make(map[string]bool, len(req.Names))
+	for _, name := range req.Names {
+		names[name] = true
+	}
+	send := func(ps proxy.ProxyStatus) error {
+		if len(names) != 0 && !names[ps.Name] {
+			return nil
+		}
+		return stream.Send(newProxyStatus(ps))
+	}
+
+	results, cancel := health.Subscribe()
+	defer cancel()
+	for _, ps := range health.Status() {
+		if err := send(ps); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case ps := <-results:
+			if err := send(ps); err != nil {
+				return err
+			}
+		case <-health.Done():
+			return nil
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *Server) ReloadRules(ctx context.Context, req *ReloadRulesRequest) (*ReloadRulesReply, error) {
+	s.mu.Lock()
+	if s.service == nil {
+		s.mu.Unlock()
+		return nil, ErrNoService
+	}
+	routers := s.service.node.AdpRouters()
+	s.mu.Unlock()
+
+	names := make(map[string]bool, len(req.Routers))
+	for _, name := range req.Routers {
+		names[name] = true
+	}
+	reply := new(ReloadRulesReply)
+	for _, r := range routers {
+		if len(names) != 0 && !names[r.Name()] {
+			continue
+		}
+		reloaded := &RulesReloaded{Router: r.Name()}
+		result, err := r.Reload()
+		if result != nil {
+			reloaded.Rules = uint32(result.Rules)
+			for _, e := range result.Errors {
+				reloaded.ParseErrors = append(reloaded.ParseErrors, e.Error())
+			}
+		}
+		if err != nil {
+			reloaded.Err = err.Error()
+		}
+		reply.Routers = append(reply.Routers, reloaded)
+	}
+	return reply, nil
+}
+
+func (s *Server) ExplainRoute(ctx context.Context, req *ExplainRouteRequest) (*RouteExplanation, error) {
+	s.mu.Lock()
+	if s.service == nil {
+		s.mu.Unlock()
+		return nil, ErrNoService
+	}
+	node := s.service.node
+	s.mu.Unlock()
+
+	e, err := node.ExplainRoute(ctx, req.Url)
+	if err != nil {
+		return nil, err
+	}
+	reply := &RouteExplanation{
+		Router: e.Router,
+		Proxy:  e.Proxy,
+		Steps:  make([]*RouteStep, len(e.Steps)),
+	}
+	for i, step := range e.Steps {
+		reply.Steps[i] = &RouteStep{
+			Router:   step.Router,
+			Late:     step.Late,
+			Proxy:    step.Proxy,
+			Rule:     step.Rule.Text,
+			RuleFile: step.Rule.File,
+			Skipped:  step.Skipped,
+		}
+	}
+	return reply, nil
+}
+
+func newProxyStatus(ps proxy.ProxyStatus) *ProxyStatus {
+	status := &ProxyStatus{
+		Name:      ps.Name,
+		Healthy:   ps.Healthy,
+		LatencyMs: uint32(ps.Latency / time.Millisecond),
+		Fails:     uint32(ps.Fails),
+	}
+	if !ps.CheckedAt.IsZero() {
+		status.CheckedAt = ps.CheckedAt.Unix()
+	}
+	if ps.Err != nil {
+		status.Err = ps.Err.Error()
+	}
+	return status
+}
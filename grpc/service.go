@@ -22,6 +22,7 @@ import (
 var (
 	StorePrefixVerifyKey = []byte("v/")
 	StorePrefixSignKey   = []byte("s/")
+	StorePrefixProxyAuth = []byte("p/")
 )
 
 type Service struct {
@@ -31,6 +32,8 @@ type Service struct {
 	ipfs           *ipfs.Ipfs
 	db             *badger.DB
 	verifyKeystore *authstore.KeyStore
+	proxyAuthStore *authstore.KeyStore
+	proxyAuth      *ProxyAuthVerifier
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -92,6 +95,21 @@ func Start(ctx context.Context, root string, configBindId uint32) (*Service, err
 	// TODO support more verify? or support load from ~/.ssh/?
 	verifier := NewVerifier(verifyKeystore, log)
 
+	proxyAuthStore, err := authstore.New(&authstore.Config{
+		DB:         db,
+		Prefix:     StorePrefixProxyAuth,
+		BufferPool: bufpool.Default1K,
+	})
+	if err != nil {
+		log.Error("New proxy auth keystore", zap.Error(err))
+		return nil, err
+	}
+	proxyAuth, err := NewProxyAuthVerifier(proxyAuthStore, log)
+	if err != nil {
+		log.Error("NewProxyAuthVerifier", zap.Error(err))
+		return nil, err
+	}
+
 	// 6. create ipfs
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithCancel(ctx)
@@ -112,6 +130,7 @@ func Start(ctx context.Context, root string, configBindId uint32) (*Service, err
 		Config:       c,
 		Ipfs:         hi,
 		Verify:       verifier.HybridVerify,
+		ProxyAuth:    proxyAuth.Verify,
 		LocalServers: map[string]http.Handler{},
 		ConfigBindId: configBindId,
 	})
@@ -138,6 +157,8 @@ func Start(ctx context.Context, root string, configBindId uint32) (*Service, err
 	s.ipfs = hi
 	s.db = db
 	s.verifyKeystore = verifyKeystore
+	s.proxyAuthStore = proxyAuthStore
+	s.proxyAuth = proxyAuth
 	s.ctx = ctx
 	s.cancel = cancel
 	s.stopped = make(chan struct{})
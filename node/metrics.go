@@ -0,0 +1,81 @@
+package node
+
+import (
+	"strconv"
+
+	"github.com/empirefox/hybrid/pkg/core"
+	"github.com/empirefox/hybrid/pkg/metrics"
+	"github.com/empirefox/hybrid/pkg/proxy"
+)
+
+type nodeMetrics struct {
+	registry *metrics.Registry
+	requests *metrics.CounterVec
+	bytes    *metrics.CounterVec
+	latency  *metrics.HistogramVec
+}
+
+func newNodeMetrics() *nodeMetrics {
+	r := metrics.NewRegistry()
+	return &nodeMetrics{
+		registry: r,
+		requests: r.NewCounterVec("hybrid_requests_total", "Proxied requests.", "router", "proxy", "status"),
+		bytes:    r.NewCounterVec("hybrid_bytes_total", "Proxied bytes.", "proxy", "direction"),
+		latency:  r.NewHistogramVec("hybrid_request_duration_seconds", "Proxied request duration.", nil, "proxy"),
+	}
+}
+
+func (m *nodeMetrics) registerH2(h2 *proxy.H2Client) {
+	h2Func := func(value func(s proxy.H2Stats) float64) func(emit func(float64, ...string)) {
+		return func(emit func(float64, ...string)) {
+			for name, s := range h2.Stats() {
+				emit(value(s), name)
+			}
+		}
+	}
+	m.registry.NewFuncVec("hybrid_h2_dials_total", "Successful dials to ipfs servers.", "counter",
+		h2Func(func(s proxy.H2Stats) float64 { return float64(s.Dials) }), "proxy")
+	m.registry.NewFuncVec("hybrid_h2_dial_failures_total", "Failed dials to ipfs servers.", "counter",
+		h2Func(func(s proxy.H2Stats) float64 { return float64(s.DialFailures) }), "proxy")
+	m.registry.NewFuncVec("hybrid_h2_ping_failures_total", "Failed pings to ipfs servers.", "counter",
+		h2Func(func(s proxy.H2Stats) float64 { return float64(s.PingFailures) }), "proxy")
+	m.registry.NewFuncVec("hybrid_h2_streams_total", "Streams to ipfs servers.", "counter",
+		h2Func(func(s proxy.H2Stats) float64 { return float64(s.Streams) }), "proxy")
+	m.registry.NewFuncVec("hybrid_h2_active_streams", "Active streams to ipfs servers.", "gauge",
+		h2Func(func(s proxy.H2Stats) float64 { return float64(s.ActiveStreams) }), "proxy")
+	m.registry.NewFuncVec("hybrid_h2_connected", "1 if connected to the ipfs server.", "gauge",
+		h2Func(func(s proxy.H2Stats) float64 {
+			if s.Connected {
+				return 1
+			}
+			return 0
+		}), "proxy")
+}
+
+func (m *nodeMetrics) registerAdp(routers []*proxy.AdpRouter) {
+	adpFunc := func(value func(s proxy.AdpCacheStats) float64) func(emit func(float64, ...string)) {
+		return func(emit func(float64, ...string)) {
+			for _, r := range routers {
+				emit(value(r.CacheStats()), r.Name())
+			}
+		}
+	}
+	m.registry.NewFuncVec("hybrid_adp_cache_hits_total", "Decisions of AdpRouter got from cache.", "counter",
+		adpFunc(func(s proxy.AdpCacheStats) float64 { return float64(s.Hits) }), "router")
+	m.registry.NewFuncVec("hybrid_adp_cache_misses_total", "Decisions of AdpRouter matched by rules.", "counter",
+		adpFunc(func(s proxy.AdpCacheStats) float64 { return float64(s.Misses) }), "router")
+	m.registry.NewFuncVec("hybrid_adp_cache_size", "Decisions in the cache of AdpRouter.", "gauge",
+		adpFunc(func(s proxy.AdpCacheStats) float64 { return float64(s.Size) }), "router")
+}
+
+func (n *Node) onAccess(c *core.Context) {
+	if n.c.Log.Access {
+		n.log.Info("access", core.AccessFields(c)...)
+	}
+
+	a := &c.Access
+	n.metrics.requests.Inc(a.Router, a.Proxy, strconv.Itoa(a.Status))
+	n.metrics.bytes.Add(float64(a.BytesUp()), a.Proxy, "up")
+	n.metrics.bytes.Add(float64(a.BytesDown()), a.Proxy, "down")
+	n.metrics.latency.Observe(a.Latency().Seconds(), a.Proxy)
+}
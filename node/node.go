@@ -1,9 +1,15 @@
 package node
 
 import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"html/template"
 	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -13,18 +19,32 @@ import (
 	"github.com/empirefox/hybrid/pkg/bufpool"
 	"github.com/empirefox/hybrid/pkg/core"
 	"github.com/empirefox/hybrid/pkg/ipfs"
+	"github.com/empirefox/hybrid/pkg/middleware"
+	"github.com/empirefox/hybrid/pkg/mitm"
 	"github.com/empirefox/hybrid/pkg/netutil"
 	"github.com/empirefox/hybrid/pkg/proxy"
+	"github.com/empirefox/hybrid/pkg/socks5"
+	"github.com/empirefox/hybrid/pkg/tproxy"
+	"github.com/empirefox/hybrid/pkg/zipfs"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 
 	inet "github.com/ipsn/go-ipfs/gxlibs/github.com/libp2p/go-libp2p-net"
 )
 
-const PathTokenPrefix = "/token/"
+const (
+	PathTokenPrefix = "/token/"
+
+	// MitmDirName is the dir of mitm CA files under StorePath.
+	MitmDirName = "mitm"
+
+	// ErrorTemplateFile is the template in Config.ErrorPagesZipName.
+	ErrorTemplateFile = "error.html"
+)
 
 var (
 	ErrConfigBindNotSet = errors.New("Config.Bind not set")
+	ErrProxyAuthNotSet  = errors.New("ProxyAuth func not set")
 )
 
 type VerifyFunc func(peerID, token []byte) bool
@@ -35,6 +55,9 @@ type Config struct {
 	Ipfs   *ipfs.Ipfs
 	Verify VerifyFunc
 
+	// ProxyAuth verifies Proxy-Authorization if Config.ProxyAuth is set.
+	ProxyAuth func(authorization string) bool
+
 	// LocalServers can be nil
 	LocalServers map[string]http.Handler
 
@@ -48,10 +71,19 @@ type Node struct {
 	c              config.Config
 	ipfs           *ipfs.Ipfs
 	core           *core.Core
+	metrics        *nodeMetrics
+	rateLimits     *core.RateLimits
+	socks5         socks5.Server
 	ipfsListeners  []*ipfs.Listener
 	groupListeners sync.Map
 	configBindId   uint32
 	proxies        map[string]core.Proxy
+	h2             *proxy.H2Client
+	groups         []*proxy.Group
+	health         *proxy.Health
+	resolver       *proxy.HostResolver
+	adpRouters     []*proxy.AdpRouter
+	geoRouters     []*proxy.GeoIPRouter
 	fileClients    map[string]*proxy.FileProxyRouterClient
 	fsDisabled     map[string]bool
 	routerDisabled map[string]bool
@@ -86,11 +118,27 @@ func New(nc Config) (*Node, error) {
 		fileRootDir:    t.FilesRootPath,
 		ruleRootDir:    t.RulesRootPath,
 		token:          []byte(nc.Config.Token),
+		rateLimits:     core.NewRateLimits(),
+		resolver: &proxy.HostResolver{
+			Server:  dnsServerAddr(c.Resolver.Server),
+			Timeout: time.Duration(c.Resolver.TimeoutMS) * time.Millisecond,
+			MinTTL:  time.Duration(c.Resolver.MinTTLMS) * time.Millisecond,
+			MaxTTL:  time.Duration(c.Resolver.MaxTTLMS) * time.Millisecond,
+		},
 	}
+	n.rateLimits.SetGlobal(int(c.RateLimitKB) << 10)
+	n.rateLimits.SetClient(int(c.ClientRateLimitKB) << 10)
 
 	h2 := proxy.NewH2Client(proxy.H2ClientConfig{
-		Log: log,
+		Log:                  log,
+		MaxConcurrentStreams: int(c.Ipfs.MaxConcurrentStreams),
+		PingInterval:         time.Duration(c.Ipfs.PingIntervalMS) * time.Millisecond,
+		PingTimeout:          time.Duration(c.Ipfs.PingTimeoutMS) * time.Millisecond,
+		MinBackoff:           time.Duration(c.Ipfs.MinRedialMS) * time.Millisecond,
+		MaxBackoff:           time.Duration(c.Ipfs.MaxRedialMS) * time.Millisecond,
+		PreDial:              c.Ipfs.PreDial,
 	})
+	n.h2 = h2
 
 	// IpfsServers
 	ipfsToken := []byte(c.Ipfs.Token)
@@ -115,6 +163,7 @@ func New(nc Config) (*Node, error) {
 		}
 
 		n.proxies[s.Name] = h2Proxy
+		n.rateLimits.SetProxy(s.Name, int(r.RateLimitKB)<<10)
 	}
 
 	// FileServers
@@ -126,6 +175,7 @@ func New(nc Config) (*Node, error) {
 		}
 		fs, err := proxy.NewFileProxyRouterClient(proxy.FileClientConfig{
 			Log:      log,
+			Name:     name,
 			Dev:      s.Dev,
 			Disabled: n.fsDisabled[name],
 			RootZip:  filepath.Join(n.fileRootDir, s.RootZipName),
@@ -140,15 +190,44 @@ func New(nc Config) (*Node, error) {
 
 	// HttpProxyServers
 	for _, s := range c.HttpProxyServers {
+		rawurl := s.URL
+		if rawurl == "" {
+			rawurl = "http://" + s.Host
+		}
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
 		name := s.Name
 		if name == "" {
-			name = strings.Replace(s.Host, ":", "-", -1)
+			name = strings.Replace(u.Host, ":", "-", -1)
 		}
-		p, err := core.NewExistProxy(name, s.Host, s.KeepAlive)
+		p, err := core.NewExistProxy(name, u, s.KeepAlive)
 		if err != nil {
 			return nil, err
 		}
 		n.proxies[name] = p
+		n.rateLimits.SetProxy(name, int(s.RateLimitKB)<<10)
+	}
+
+	// ProxyGroups
+	for _, s := range c.ProxyGroups {
+		g, err := n.newProxyGroup(s)
+		if err != nil {
+			return nil, err
+		}
+		n.proxies[s.Name] = g
+		n.groups = append(n.groups, g)
+	}
+
+	if c.HealthCheck.IntervalMS != 0 {
+		n.health = proxy.NewHealth(proxy.HealthConfig{
+			Log:      log,
+			Proxies:  n.proxies,
+			Interval: time.Duration(c.HealthCheck.IntervalMS) * time.Millisecond,
+			Timeout:  time.Duration(c.HealthCheck.TimeoutMS) * time.Millisecond,
+			MaxFails: int(c.HealthCheck.MaxFails),
+		})
 	}
 
 	routers := make([]core.Router, len(c.Routers))
@@ -171,11 +250,63 @@ func New(nc Config) (*Node, error) {
 	if c.Ipfs.GatewayServerName != "" {
 		localServers[c.Ipfs.GatewayServerName] = n.ipfs.GatewayServer()
 	}
+	n.metrics = newNodeMetrics()
+	n.metrics.registerH2(h2)
+	n.metrics.registerAdp(n.adpRouters)
+	h2.Start()
+	if c.MetricsServerName != "" {
+		localServers[c.MetricsServerName] = n.metrics.registry
+	}
+	var interceptor *mitm.Interceptor
+	if len(c.Mitm.Hosts) != 0 || c.Mitm.CAServerName != "" {
+		ca, err := mitm.LoadOrCreateCA(filepath.Join(t.StorePath, MitmDirName))
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		if len(c.Mitm.Hosts) != 0 {
+			interceptor = &mitm.Interceptor{CA: ca, Hosts: c.Mitm.Hosts}
+		}
+		if c.Mitm.CAServerName != "" {
+			localServers[c.Mitm.CAServerName] = caServer(ca)
+		}
+	}
 
 	cc := &core.ContextConfig{
 		Transport:     http.DefaultTransport,
 		BufferPool:    bufpool.Default,
 		FlushInterval: time.Duration(c.FlushIntervalMS) * time.Millisecond,
+		DialTimeout:   time.Duration(c.DialTimeoutMS) * time.Millisecond,
+		IdleTimeout:   time.Duration(c.IdleTimeoutMS) * time.Millisecond,
+		MaxLifetime:   time.Duration(c.MaxLifetimeMS) * time.Millisecond,
+		RateLimits:    n.rateLimits,
+		SNITimeout:    time.Duration(c.SNISniffTimeoutMS) * time.Millisecond,
+	}
+	if c.Trace.Enabled {
+		cc.TraceName = c.Trace.Name
+		if cc.TraceName == "" && n.ipfs != nil {
+			cc.TraceName = n.ipfs.PeerID()
+		}
+		cc.KeepTrace = c.Trace.KeepAtTarget
+	}
+	if c.ErrorPagesZipName != "" {
+		tmpl, err := loadErrorTemplate(filepath.Join(n.fileRootDir, c.ErrorPagesZipName) + ".zip")
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		cc.ErrorTemplate = tmpl
+	}
+	if !c.DisableKeepAlives {
+		cc.ConnPool = core.NewConnPool(int(c.MaxIdleConnsPerHost),
+			time.Duration(c.IdleConnTimeoutMS)*time.Millisecond)
+	}
+
+	for _, g := range n.groups {
+		g.Start(cc)
+	}
+	for _, r := range n.adpRouters {
+		r.Start(cc)
 	}
 
 	n.core = &core.Core{
@@ -184,9 +315,51 @@ func New(nc Config) (*Node, error) {
 		Routers:       routers,
 		Proxies:       n.proxies,
 		LocalServers:  localServers,
+		OnAccess:      n.onAccess,
+		MITM:          interceptor,
+	}
+	if n.health != nil {
+		if c.HealthCheck.SkipUnhealthy {
+			n.core.Unhealthy = n.health.Unhealthy
+		}
+		n.health.Start()
+	}
+	if c.ProxyAuth {
+		if nc.ProxyAuth == nil {
+			n.Close()
+			return nil, ErrProxyAuthNotSet
+		}
+		n.core.Use(&middleware.ProxyAuth{
+			Realm:  "hybrid",
+			Verify: nc.ProxyAuth,
+		})
+	}
+	for _, mi := range c.Middlewares {
+		m, err := newMiddleware(mi)
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		n.core.Use(m)
+	}
+
+	// socks5 binds verify Socks5Users, then keys of ProxyAuth as Basic
+	if len(c.Socks5Users) != 0 || c.ProxyAuth {
+		n.socks5.Verify = func(username, password []byte) bool {
+			expected, ok := c.Socks5Users[string(username)]
+			if ok && subtle.ConstantTimeCompare([]byte(expected), password) == 1 {
+				return true
+			}
+			if !c.ProxyAuth {
+				return false
+			}
+			userpass := string(username) + ":" + string(password)
+			return nc.ProxyAuth("Basic " + base64.StdEncoding.EncodeToString([]byte(userpass)))
+		}
 	}
 
-	ipfsListeners := make([]*ipfs.Listener, 0, len(c.Ipfs.ListenProtocols))
+	// listeners are closed by Close, even if a later one fails
+	n.ipfsListeners = make([]*ipfs.Listener, 0, len(c.Ipfs.ListenProtocols))
 	for _, p := range c.Ipfs.ListenProtocols {
 		// /hybrid/1.0/token/xxx
 		tokenPrefix := config.HybridIpfsProtocol + PathTokenPrefix
@@ -194,6 +367,7 @@ func New(nc Config) (*Node, error) {
 		// TODO what if p!=HybridIpfsProtocol
 		ln, err := n.ipfs.Listen(p, match)
 		if err != nil {
+			n.Close()
 			return nil, err
 		}
 
@@ -202,9 +376,8 @@ func New(nc Config) (*Node, error) {
 			token := []byte(strings.TrimPrefix(string(is.Protocol()), tokenPrefix))
 			return nc.Verify(target, token)
 		})
-		ipfsListeners = append(ipfsListeners, ln)
+		n.ipfsListeners = append(n.ipfsListeners, ln)
 	}
-	n.ipfsListeners = ipfsListeners
 
 	for _, ln := range n.ipfsListeners {
 		n.eg.Go(func() error { return n.core.Serve(ln) })
@@ -239,6 +412,25 @@ func (n *Node) StartProxy(uniqueId uint32, ln net.Listener) {
 	})
 }
 
+func (n *Node) StartSocks5Proxy(uniqueId uint32, ln net.Listener) {
+	n.groupListeners.Store(uniqueId, ln)
+	n.eg.Go(func() error {
+		defer n.groupListeners.Delete(uniqueId)
+		return netutil.SimpleServe(ln, n.socks5Proxy)
+	})
+}
+
+// StartTransparentProxy serves conns redirected by iptables, dst recovers
+// their destinations, see tproxy.OriginalDst and tproxy.LocalAddr.
+func (n *Node) StartTransparentProxy(uniqueId uint32, ln net.Listener, dst tproxy.OriginalDstFunc) {
+	s := &tproxy.Server{OriginalDst: dst, Listen: ln.Addr()}
+	n.groupListeners.Store(uniqueId, ln)
+	n.eg.Go(func() error {
+		defer n.groupListeners.Delete(uniqueId)
+		return netutil.SimpleServe(ln, func(conn net.Conn) { n.transparentProxy(s, conn) })
+	})
+}
+
 func (n *Node) StartIpfsApi(uniqueId uint32, ln net.Listener) {
 	n.groupListeners.Store(uniqueId, ln)
 	n.eg.Go(func() error {
@@ -263,6 +455,31 @@ func (n *Node) StopListener(uniqueId uint32) error {
 	return value.(net.Listener).Close()
 }
 
+// RateLimits can be adjusted at runtime.
+func (n *Node) RateLimits() *core.RateLimits { return n.rateLimits }
+
+// dnsServerAddr appends the default port 53 to server if missing.
+func dnsServerAddr(server string) string {
+	if server == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(server, "53")
+	}
+	return server
+}
+
+// ExplainRoute dry runs routers with rawurl, lookups are canceled with ctx.
+func (n *Node) ExplainRoute(ctx context.Context, rawurl string) (*core.RouteExplanation, error) {
+	return n.core.ExplainURL(ctx, rawurl)
+}
+
+// AdpRouters returns all AdpRouters in config order.
+func (n *Node) AdpRouters() []*proxy.AdpRouter { return n.adpRouters }
+
+// Health is nil if HealthCheck is disabled.
+func (n *Node) Health() *proxy.Health { return n.health }
+
 func (n *Node) ErrGroupWait() error { return n.eg.Wait() }
 func (n *Node) Go(f func() error)   { n.eg.Go(f) }
 
@@ -275,6 +492,24 @@ func (n *Node) Close() (err error) {
 			value.(net.Listener).Close()
 			return true
 		})
+		if n.h2 != nil {
+			n.h2.Close()
+		}
+		for _, g := range n.groups {
+			g.Close()
+		}
+		if n.health != nil {
+			n.health.Close()
+		}
+		for _, r := range n.adpRouters {
+			r.Close()
+		}
+		for _, r := range n.geoRouters {
+			r.Close()
+		}
+		if n.core != nil && n.core.ContextConfig.ConnPool != nil {
+			n.core.ContextConfig.ConnPool.CloseIdle()
+		}
 		for _, fc := range n.fileClients {
 			if err != nil {
 				err = fc.Close()
@@ -288,7 +523,8 @@ func (n *Node) Close() (err error) {
 
 func (n *Node) proxy(conn net.Conn) {
 	defer conn.Close()
-	ctx, err := core.NewContextWithConn(n.core.ContextConfig, conn)
+	br := bufio.NewReader(conn)
+	ctx, err := core.NewContextWithReader(n.core.ContextConfig, conn, br)
 	if err != nil {
 		he := core.HttpErr{
 			Code:       http.StatusBadRequest,
@@ -301,4 +537,77 @@ func (n *Node) proxy(conn net.Conn) {
 		return
 	}
 	n.core.Proxy(ctx)
+
+	for ctx.KeepAlive && !n.c.DisableKeepAlives {
+		if d := n.core.ContextConfig.IdleTimeout; d != 0 {
+			conn.SetReadDeadline(time.Now().Add(d))
+		}
+		if _, err = br.Peek(1); err != nil {
+			// closed or idle
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		ctx, err = core.NewContextWithReader(n.core.ContextConfig, conn, br)
+		if err != nil {
+			return
+		}
+		n.core.Proxy(ctx)
+	}
+}
+
+func (n *Node) socks5Proxy(conn net.Conn) {
+	defer conn.Close()
+	hostport, err := n.socks5.Handshake(conn)
+	if err != nil {
+		n.log.Debug("socks5 handshake", zap.Error(err))
+		return
+	}
+
+	ctx, err := core.NewContextWithSocks5(n.core.ContextConfig, conn, hostport)
+	if err != nil {
+		conn.Write(socks5.Reply(socks5.RepGeneralFailure))
+		return
+	}
+	n.core.Proxy(ctx)
+}
+
+func (n *Node) transparentProxy(s *tproxy.Server, conn net.Conn) {
+	defer conn.Close()
+	hostport, br, err := s.Handshake(conn)
+	if err != nil {
+		n.log.Debug("transparent handshake", zap.Error(err))
+		return
+	}
+
+	ctx, err := core.NewContextWithTransparent(n.core.ContextConfig, conn, br, hostport)
+	if err != nil {
+		n.log.Debug("transparent context", zap.String("host", hostport), zap.Error(err))
+		return
+	}
+	n.core.Proxy(ctx)
+}
+
+// loadErrorTemplate parses ErrorTemplateFile in the zip at zipPath.
+func loadErrorTemplate(zipPath string) (*template.Template, error) {
+	hfs, closer, err := zipfs.New(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	b, err := hfs.ReadFile("/" + ErrorTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(ErrorTemplateFile).Parse(string(b))
+}
+
+// caServer serves the mitm CA certificate for clients to install.
+func caServer(ca *mitm.CA) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", "attachment; filename="+mitm.CertFile)
+		w.Write(ca.CertPEM())
+	})
 }
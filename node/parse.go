@@ -1,17 +1,19 @@
 package node
 
 import (
+	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 
 	"github.com/empirefox/hybrid/config"
 	"github.com/empirefox/hybrid/pkg/core"
+	"github.com/empirefox/hybrid/pkg/middleware"
 	"github.com/empirefox/hybrid/pkg/proxy"
+	"github.com/oschwald/maxminddb-golang"
 	"go.uber.org/zap"
 
 	peer "github.com/ipsn/go-ipfs/gxlibs/github.com/libp2p/go-libp2p-peer"
@@ -58,18 +60,80 @@ func parseEnvList(name string) map[string]bool {
 }
 
 func (n *Node) newRouter(raw config.RouterItem) (core.Router, error) {
-	if raw.Adp != nil && raw.IPNet == nil {
-		return n.newAdpRouter(raw.Name, raw.Adp)
+	set := 0
+	for _, ok := range []bool{raw.Adp != nil, raw.IPNet != nil, raw.Domain != nil, raw.GeoIP != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("one and only one router can be set in RouterItem(%s)", raw.Name)
 	}
-	if raw.Adp == nil && raw.IPNet != nil {
+
+	switch {
+	case raw.Adp != nil:
+		return n.newAdpRouter(raw.Name, raw.Adp)
+	case raw.IPNet != nil:
 		return n.newNetRouter(raw.Name, raw.IPNet)
+	case raw.Domain != nil:
+		return n.newDomainRouter(raw.Name, raw.Domain)
+	default:
+		return n.newGeoIPRouter(raw.Name, raw.GeoIP)
 	}
-	return nil, fmt.Errorf("one and only one router can be set in RouterItem(%s)", raw.Name)
+}
+
+func (n *Node) newProxyGroup(raw config.ProxyGroup) (*proxy.Group, error) {
+	if _, ok := n.proxies[raw.Name]; ok {
+		return nil, fmt.Errorf("ProxyGroup(%s) name is used", raw.Name)
+	}
+	members := make([]proxy.GroupMember, len(raw.Proxies))
+	for i, name := range raw.Proxies {
+		p, ok := n.proxies[name]
+		if !ok {
+			return nil, fmt.Errorf("proxy(%s) of ProxyGroup(%s) not found", name, raw.Name)
+		}
+		members[i] = proxy.GroupMember{Name: name, Proxy: p}
+	}
+	return proxy.NewGroup(proxy.GroupConfig{
+		Log:           n.log,
+		Name:          raw.Name,
+		Members:       members,
+		Strategy:      raw.Strategy,
+		ProbeURL:      raw.ProbeURL,
+		ProbeInterval: time.Duration(raw.ProbeIntervalMS) * time.Millisecond,
+		ProbeTimeout:  time.Duration(raw.ProbeTimeoutMS) * time.Millisecond,
+		MaxFails:      int(raw.MaxFails),
+		EjectDuration: time.Duration(raw.EjectMS) * time.Millisecond,
+	})
+}
+
+func newMiddleware(raw config.MiddlewareItem) (core.Middleware, error) {
+	var ms []core.Middleware
+	if raw.Header != nil {
+		ms = append(ms, &middleware.Header{
+			Set:    raw.Header.Set,
+			Remove: raw.Header.Remove,
+		})
+	}
+	if raw.BasicAuth != nil {
+		ms = append(ms, &middleware.BasicAuth{
+			Realm: raw.BasicAuth.Realm,
+			Users: raw.BasicAuth.Users,
+		})
+	}
+	if raw.Deny != nil {
+		ms = append(ms, &middleware.Deny{Hosts: raw.Deny.Hosts})
+	}
+	if len(ms) != 1 {
+		return nil, fmt.Errorf("one and only one middleware can be set in MiddlewareItem(%s)", raw.Name)
+	}
+	return ms[0], nil
 }
 
 func (n *Node) newAdpRouter(name string, raw *config.AdpRouter) (*proxy.AdpRouter, error) {
 	config := proxy.AdpRouterConfig{
 		Log:                 n.log,
+		Name:                name,
 		Disabled:            n.routerDisabled[name],
 		EtcHostsIPAsBlocked: raw.EtcHostsIPAsBlocked,
 		Dev:                 raw.Dev,
@@ -91,55 +155,159 @@ func (n *Node) newAdpRouter(name string, raw *config.AdpRouter) (*proxy.AdpRoute
 	}
 
 	if raw.B64RuleDirName != "" {
-		b64, err := n.readRulesDir(raw.B64RuleDirName)
-		if err != nil {
-			return nil, err
+		config.B64RuleDir = filepath.Join(n.ruleRootDir, raw.B64RuleDirName)
+	}
+	if raw.TxtRuleDirName != "" {
+		config.TxtRuleDir = filepath.Join(n.ruleRootDir, raw.TxtRuleDirName)
+	}
+	config.WatchInterval = time.Duration(raw.WatchIntervalMS) * time.Millisecond
+	config.CacheSize = int(raw.CacheSize)
+	if raw.DisableCache {
+		config.CacheSize = -1
+	}
+	config.CacheTTL = time.Duration(raw.CacheTTLMS) * time.Millisecond
+
+	for _, sub := range raw.Subscriptions {
+		rs := proxy.RuleSubscription{
+			URL:          sub.URL,
+			FileName:     sub.FileName,
+			Base64:       sub.Base64,
+			Interval:     time.Duration(sub.IntervalMS) * time.Millisecond,
+			Timeout:      time.Duration(sub.TimeoutMS) * time.Millisecond,
+			ChecksumURL:  sub.ChecksumURL,
+			SignatureURL: sub.SignatureURL,
 		}
-		config.B64Rules = b64
+		if sub.Proxy != "" {
+			p, ok := n.proxies[sub.Proxy]
+			if !ok {
+				return nil, fmt.Errorf("AdpRouter(%s) subscription proxy name(%s) not found", name, sub.Proxy)
+			}
+			rs.Proxy = p
+		}
+		if sub.PublicKey != "" {
+			key, err := hex.DecodeString(sub.PublicKey)
+			if err != nil {
+				return nil, fmt.Errorf("AdpRouter(%s) subscription PublicKey: %v", name, err)
+			}
+			rs.PublicKey = key
+		}
+		config.Subscriptions = append(config.Subscriptions, rs)
 	}
 
-	if raw.TxtRuleDirName != "" {
-		txt, err := n.readRulesDir(raw.TxtRuleDirName)
-		if err != nil {
-			return nil, err
+	r, err := proxy.NewAdpRouter(config)
+	if err != nil {
+		return nil, err
+	}
+	n.adpRouters = append(n.adpRouters, r)
+	return r, nil
+}
+
+func (n *Node) newDomainRouter(name string, raw *config.DomainRouter) (*proxy.DomainRouter, error) {
+	config := proxy.DomainRouterConfig{
+		Name:     name,
+		Disabled: n.routerDisabled[name],
+	}
+
+	for _, set := range raw.RuleSets {
+		p, ok := n.proxies[set.Proxy]
+		if !ok {
+			return nil, fmt.Errorf("DomainRouter(%s) proxy name(%s) not found", name, set.Proxy)
 		}
-		config.TxtRules = txt
+		if set.RuleDirName != "" {
+			files, err := n.readRulesDir(set.RuleDirName)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				config.Rules = append(config.Rules, proxy.DomainRules{
+					Proxy: p,
+					File:  filepath.Join(set.RuleDirName, f.Name),
+					Rules: proxy.ParseDomainRules(f.Content),
+				})
+			}
+		}
+		config.Rules = append(config.Rules, proxy.DomainRules{
+			Proxy: p,
+			Rules: set.Rules,
+		})
+	}
+
+	if raw.Unmatched != "" {
+		p, ok := n.proxies[raw.Unmatched]
+		if !ok {
+			return nil, fmt.Errorf("DomainRouter(%s) unmatched name(%s) not found", name, raw.Unmatched)
+		}
+		config.Unmatched = p
 	}
 
-	return proxy.NewAdpRouter(config)
+	return proxy.NewDomainRouter(config)
 }
 
-func (n *Node) readRulesDir(dirname string) ([][]byte, error) {
-	dir := filepath.Join(n.ruleRootDir, dirname)
+func (n *Node) newGeoIPRouter(name string, raw *config.GeoIPRouter) (*proxy.GeoIPRouter, error) {
+	config := proxy.GeoIPRouterConfig{
+		Name:     name,
+		Disabled: n.routerDisabled[name],
+		Rules:    make([]proxy.GeoIPRule, len(raw.Rules)),
+	}
 
-	infos, err := ioutil.ReadDir(dir)
-	if err != nil {
-		n.log.Error("readRulesDir", zap.Error(err))
-		return nil, err
+	for i, rule := range raw.Rules {
+		p, ok := n.proxies[rule.Proxy]
+		if !ok {
+			return nil, fmt.Errorf("GeoIPRouter(%s) proxy name(%s) not found", name, rule.Proxy)
+		}
+		config.Rules[i] = proxy.GeoIPRule{
+			Proxy:     p,
+			Countries: rule.Countries,
+			ASNs:      rule.ASNs,
+		}
 	}
 
-	var names []string
-	for _, info := range infos {
-		name := info.Name()
-		n0 := name[0]
-		if !info.IsDir() && n0 >= '0' && n0 <= '9' {
-			names = append(names, name)
-		} else {
-			n.log.Info("readRulesDir", zap.String("file", name), zap.String("dir", dir))
+	if raw.Unmatched != "" {
+		p, ok := n.proxies[raw.Unmatched]
+		if !ok {
+			return nil, fmt.Errorf("GeoIPRouter(%s) unmatched name(%s) not found", name, raw.Unmatched)
 		}
+		config.Unmatched = p
 	}
 
-	sort.Strings(names)
-	contents := make([][]byte, 0, len(names))
-	for _, name := range names {
-		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if !raw.NoResolve {
+		config.Resolver = n.resolver
+	}
+
+	for _, filename := range raw.DBFileNames {
+		db, err := maxminddb.Open(filepath.Join(n.ruleRootDir, filename))
 		if err != nil {
-			n.log.Error("readRulesDir", zap.Error(err))
-			return nil, err
+			for _, db := range config.DBs {
+				db.Close()
+			}
+			return nil, fmt.Errorf("GeoIPRouter(%s) open db(%s): %v", name, filename, err)
 		}
-		contents = append(contents, content)
+		config.DBs = append(config.DBs, db)
+	}
+
+	r, err := proxy.NewGeoIPRouter(config)
+	if err != nil {
+		for _, db := range config.DBs {
+			db.Close()
+		}
+		return nil, err
+	}
+	n.geoRouters = append(n.geoRouters, r)
+	return r, nil
+}
+
+func (n *Node) readRulesDir(dirname string) ([]proxy.RuleFile, error) {
+	dir := filepath.Join(n.ruleRootDir, dirname)
+
+	files, skipped, err := proxy.ReadRuleFiles(dir)
+	if err != nil {
+		n.log.Error("readRulesDir", zap.Error(err))
+		return nil, err
+	}
+	for _, name := range skipped {
+		n.log.Info("readRulesDir", zap.String("file", name), zap.String("dir", dir))
 	}
-	return contents, nil
+	return files, nil
 }
 
 type netRouter struct {
@@ -171,9 +339,18 @@ func (n *Node) newNetRouter(name string, raw *config.IPNetRouter) (*proxy.IPNetR
 	}
 
 	router := proxy.IPNetRouter{
-		Skip: n.routerDisabled[name],
-		IPs:  ips,
-		Nets: nets,
+		RouterName: name,
+		Skip:       n.routerDisabled[name],
+		IPs:        ips,
+		Nets:       nets,
+	}
+
+	switch raw.Resolve {
+	case "always":
+		router.Resolver = n.resolver
+	case "last":
+		router.Resolver = n.resolver
+		router.ResolveLast = true
 	}
 
 	if raw.Matched != "" {
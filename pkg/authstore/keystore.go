@@ -188,7 +188,7 @@ func (s *KeyStore) Slice(start uint64, size int, reverse bool) (aks []*AuthKey,
 		opts.Reverse = reverse
 		it := txn.NewIterator(opts)
 		defer it.Close()
-		for it.Seek(key); it.ValidForPrefix(s.prefix); it.Next() {
+		for it.Seek(key); it.ValidForPrefix(s.prefix) && total < size; it.Next() {
 			item := it.Item()
 			if reverse && bytes.Equal(item.Key(), s.metaKey) {
 				// ignote meta
@@ -92,6 +92,13 @@ func TestKeystore(t *testing.T) {
 	if len(aks) != 2 {
 		t.Fatalf("Slice should get 2 result, but got: %d", len(aks))
 	}
+	aks1, err := s.Slice(1, 1, false)
+	if err != nil {
+		t.Errorf("Slice 1 should get no err, but got: %v", err)
+	}
+	if len(aks1) != 1 || aks1[0].Id != 1 {
+		t.Fatalf("Slice 1 should get the first ak only, but got: %v", aks1)
+	}
 
 	// Find
 	ak, err = s.Find(2)
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Namer is optionally implemented by Router and Proxy, used by access log.
+type Namer interface {
+	Name() string
+}
+
+// Access records route decision and traffic of one Context.
+type Access struct {
+	Start  time.Time
+	Router string
+	Proxy  string
+	// Status is 0 if the response is not parsed.
+	Status int
+	// Err is returned by Do of Proxy.
+	Err error
+
+	bytesUp   int64
+	bytesDown int64
+}
+
+func (a *Access) BytesUp() int64         { return atomic.LoadInt64(&a.bytesUp) }
+func (a *Access) BytesDown() int64       { return atomic.LoadInt64(&a.bytesDown) }
+func (a *Access) Latency() time.Duration { return time.Since(a.Start) }
+
+func (a *Access) addUp(n int64)   { atomic.AddInt64(&a.bytesUp, n) }
+func (a *Access) addDown(n int64) { atomic.AddInt64(&a.bytesDown, n) }
+
+func (a *Access) setStatus(code int) {
+	if a.Status == 0 {
+		a.Status = code
+	}
+}
+
+// setProxy names p by Namer, or by its type.
+func (a *Access) setProxy(p Proxy) {
+	if namer, ok := p.(Namer); ok {
+		a.Proxy = namer.Name()
+	} else {
+		a.Proxy = fmt.Sprintf("%T", p)
+	}
+}
+
+// AccessFields returns zap fields of finished c.
+func AccessFields(c *Context) []zap.Field {
+	return []zap.Field{
+		zap.String("host", c.HostPort),
+		zap.String("dial", c.DialHostPort),
+		zap.String("hop", c.Domain.Next),
+		zap.String("router", c.Access.Router),
+		zap.String("proxy", c.Access.Proxy),
+		zap.Int("status", c.Access.Status),
+		zap.NamedError("error", c.Access.Err),
+		zap.Int64("up", c.Access.BytesUp()),
+		zap.Int64("down", c.Access.BytesDown()),
+		zap.Duration("latency", c.Access.Latency()),
+	}
+}
+
+type accessWriter struct {
+	io.Writer
+	a *Access
+}
+
+func (w accessWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.a.addDown(int64(n))
+	return n, err
+}
+
+type accessReadCloser struct {
+	io.ReadCloser
+	a *Access
+}
+
+func (r accessReadCloser) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.a.addUp(int64(n))
+	return n, err
+}
+
+type accessResponseWriter struct {
+	http.ResponseWriter
+	a *Access
+}
+
+func (w accessResponseWriter) WriteHeader(code int) {
+	w.a.setStatus(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w accessResponseWriter) Write(b []byte) (int, error) {
+	w.a.setStatus(http.StatusOK)
+	n, err := w.ResponseWriter.Write(b)
+	w.a.addDown(int64(n))
+	return n, err
+}
+
+func (w accessResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
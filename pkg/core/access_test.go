@@ -0,0 +1,43 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+)
+
+func TestAccessProxyAndErr(t *testing.T) {
+	newContext := func() *Context {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		c, err := NewContextFromHandler(&ContextConfig{BufferPool: bufpool.Default}, httptest.NewRecorder(), req)
+		if err != nil {
+			t.Fatalf("NewContextFromHandler should get no err, but got: %v", err)
+		}
+		return c
+	}
+
+	c := newContext()
+	c.proxy(failProxy{})
+	if c.Access.Proxy != "core.failProxy" {
+		t.Errorf("proxy without Name should be named by type, but got: %q", c.Access.Proxy)
+	}
+	if c.Access.Err == nil || c.Access.Err.Error() != "upstream down" {
+		t.Errorf("Access should record the err of Do, but got: %v", c.Access.Err)
+	}
+	var logged bool
+	for _, f := range AccessFields(c) {
+		if f.Key == "error" {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Errorf("AccessFields should log the err")
+	}
+
+	c = newContext()
+	c.proxy(namedProxy{testProxy("ok"), "up"})
+	if c.Access.Proxy != "up" || c.Access.Err != nil {
+		t.Errorf("Access should record the proxy name without err, but got: %q %v", c.Access.Proxy, c.Access.Err)
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+// ConnPool keeps idle upstream conns of Direct reverse requests, keyed by
+// DialHostPort.
+type ConnPool struct {
+	MaxIdlePerHost int
+	IdleTimeout    time.Duration // zero means no timeout
+
+	mu   sync.Mutex
+	idle map[string][]*pooledConn
+}
+
+type pooledConn struct {
+	net.Conn
+	br     *bufio.Reader
+	idleAt time.Time
+}
+
+func NewConnPool(maxIdlePerHost int, idleTimeout time.Duration) *ConnPool {
+	return &ConnPool{
+		MaxIdlePerHost: maxIdlePerHost,
+		IdleTimeout:    idleTimeout,
+		idle:           make(map[string][]*pooledConn),
+	}
+}
+
+// get returns the most recently used idle conn of key, or nil.
+func (p *ConnPool) get(key string) *pooledConn {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conns := p.idle[key]
+	for len(conns) > 0 {
+		pc := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if p.IdleTimeout != 0 && now.Sub(pc.idleAt) > p.IdleTimeout || !pc.alive() {
+			pc.Close()
+			continue
+		}
+		p.setIdle(key, conns)
+		return pc
+	}
+	p.setIdle(key, conns)
+	return nil
+}
+
+// put returns pc to the pool, pc is closed if the pool of key is full.
+func (p *ConnPool) put(key string, pc *pooledConn) {
+	if pc.br.Buffered() > 0 {
+		// unexpected bytes after response
+		pc.Close()
+		return
+	}
+	pc.idleAt = time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conns := p.idle[key]
+	if len(conns) >= p.MaxIdlePerHost {
+		pc.Close()
+		return
+	}
+	p.idle[key] = append(conns, pc)
+}
+
+// alive checks pc is not closed by remote, without blocking.
+func (pc *pooledConn) alive() bool {
+	pc.SetReadDeadline(time.Now())
+	_, err := pc.br.Peek(1)
+	pc.SetReadDeadline(time.Time{})
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	// EOF, or unexpected bytes
+	return false
+}
+
+func (p *ConnPool) setIdle(key string, conns []*pooledConn) {
+	if len(conns) == 0 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = conns
+	}
+}
+
+// CloseIdle closes all idle conns.
+func (p *ConnPool) CloseIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, conns := range p.idle {
+		for _, pc := range conns {
+			pc.Close()
+		}
+		delete(p.idle, key)
+	}
+}
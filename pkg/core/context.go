@@ -4,16 +4,22 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/empirefox/hybrid/pkg/domain"
 	"github.com/empirefox/hybrid/pkg/netutil"
+	"github.com/empirefox/hybrid/pkg/socks5"
 )
 
 var (
@@ -21,12 +27,42 @@ var (
 	StandardConnectOK = []byte("HTTP/1.1 200 OK\r\n\r\n")
 
 	ErrRequestURI = errors.New("bad RequestURI")
+	ErrProxyLoop  = errors.New("request to proxy itself")
 )
 
+// DialFunc has the signature of net.Dial.
+type DialFunc func(network, address string) (net.Conn, error)
+
 type ContextConfig struct {
 	Transport     http.RoundTripper
 	BufferPool    httputil.BufferPool
 	FlushInterval time.Duration
+
+	// Timeouts, zero means no timeout.
+	DialTimeout time.Duration
+	IdleTimeout time.Duration // no bytes copied in both directions
+	MaxLifetime time.Duration // since Context created
+
+	// RateLimits can be nil.
+	RateLimits *RateLimits
+
+	// SNITimeout enables peeking TLS ClientHello of CONNECT tunnels when a
+	// router asks RouteHost, the CONNECT is replied before dial then. Zero
+	// disables it.
+	SNITimeout time.Duration
+
+	// ConnPool keeps upstream conns of Direct reverse requests alive, nil
+	// closes them after every response.
+	ConnPool *ConnPool
+
+	// ErrorTemplate renders HttpErr to clients accepting html, nil uses
+	// DefaultErrorTemplate.
+	ErrorTemplate *template.Template
+
+	// TraceName is the node name in TraceHeader, empty disables tracing.
+	TraceName string
+	// KeepTrace sends TraceHeader to target servers.
+	KeepTrace bool
 }
 
 type Context struct {
@@ -45,20 +81,180 @@ type Context struct {
 	IP           net.IP
 	DialHostPort string
 	Domain       domain.Domain
+	Socks5       bool   // reply socks5 to Writer, not http
+	Transparent  bool   // redirected conn, nothing replied to Writer
+	SNI          string // server name of the TLS tunneled, if sniffed
+	Intercepted  bool   // decrypted from a MITM tunnel
+	Local        bool   // from local bind, not from peers
+	KeepAlive    bool   // read next request from conn, unframed responses clear it
+	Access       Access
+
+	// replied is true if CONNECT replied before dial, or no reply needed.
+	// Errors cannot be replied then.
+	replied bool
+
+	// sniffed is true if sniffSNI is tried by RouteHost.
+	sniffed bool
+
+	// errCode is the ErrCode of the failed Proxy.Do.
+	errCode ErrCode
 
 	nopCloser io.ReadCloser
+	// clientBody wraps Request.Body read from conn if KeepAlive.
+	clientBody *clientBody
 	// responseWriter non nil, if not given, wrap one.
 	responseWriter http.ResponseWriter
+
+	guardOnce sync.Once
+	guard     *guard
 }
 
 func NewContextWithConn(cc *ContextConfig, conn net.Conn) (*Context, error) {
-	bufConn := bufio.NewReader(conn)
-	req, err := http.ReadRequest(bufConn)
+	return NewContextWithReader(cc, conn, bufio.NewReader(conn))
+}
+
+// NewContextWithReader reads request from br which buffers conn. The same br
+// must be used to read the next request if KeepAlive.
+func NewContextWithReader(cc *ContextConfig, conn net.Conn, br *bufio.Reader) (*Context, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = conn.RemoteAddr().String()
+
+	if req.Method != "CONNECT" && req.URL.Scheme == "" && req.URL.Host == "" && req.Host != "" {
+		// origin-form from clients without proxy config, route it by Host
+		// as absolute-form:
+		//	GET /index.html HTTP/1.1
+		//	Host: a.over.b.hybrid
+		if isLocalAddr(conn, req.Host) {
+			return nil, ErrProxyLoop
+		}
+		req.URL.Scheme = "http"
+		req.URL.Host = req.Host
+	}
+
+	c, err := newContext(cc, req, nil, conn)
+	if err != nil {
+		return nil, err
+	}
+	// bytes buffered after the request belong to the stream
+	c.UnsafeReader = bufferedConn{br, conn}
+	c.Local = true
+	c.KeepAlive = !c.Connect && !req.Close
+	if c.KeepAlive && req.Body != nil && req.Body != http.NoBody {
+		c.clientBody = &clientBody{ReadCloser: req.Body}
+		req.Body = c.clientBody
+	}
+	return c, nil
+}
+
+// clientBody records whether the request body is read to EOF, so that the
+// next request can be read from the conn. Close does not drain the body,
+// which would race with reading the next request.
+type clientBody struct {
+	io.ReadCloser
+	eof    int32
+	closed int32
+}
+
+func (b *clientBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.closed) == 1 {
+		return 0, http.ErrBodyReadAfterClose
+	}
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		atomic.StoreInt32(&b.eof, 1)
+	}
+	return n, err
+}
+
+func (b *clientBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return nil
+}
+
+func (b *clientBody) done() bool { return atomic.LoadInt32(&b.eof) == 1 }
+
+// checkKeepAlive clears KeepAlive if the request body is not fully read, the
+// rest of the body cannot be told from the next request.
+func (c *Context) checkKeepAlive() {
+	if c.KeepAlive && c.clientBody != nil && !c.clientBody.done() {
+		c.KeepAlive = false
+	}
+}
+
+// isLocalAddr reports whether host is the listening address of conn.
+func isLocalAddr(conn net.Conn, host string) bool {
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	hostNoPort, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostNoPort, port = host, "80"
+	}
+	if port != strconv.Itoa(local.Port) {
+		return false
+	}
+	ip := net.ParseIP(hostNoPort)
+	if ip == nil {
+		return hostNoPort == "localhost"
+	}
+	return ip.Equal(local.IP) || ip.IsLoopback() && local.IP.IsLoopback() ||
+		ip.IsUnspecified()
+}
+
+type bufferedConn struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// NewContextWithSocks5 creates a CONNECT context for the socks5 handshaked conn.
+func NewContextWithSocks5(cc *ContextConfig, conn net.Conn, hostport string) (*Context, error) {
+	req := &http.Request{
+		Method:     "CONNECT",
+		URL:        &url.URL{Host: hostport},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Host:       hostport,
+		RemoteAddr: conn.RemoteAddr().String(),
+	}
+	c, err := newContext(cc, req, nil, conn)
 	if err != nil {
 		return nil, err
 	}
+	c.Socks5 = true
+	c.Local = true
+	return c, nil
+}
 
-	return newContext(cc, req, nil, conn)
+// NewContextWithTransparent creates a CONNECT context for the redirected
+// conn, br keeps the sniffed bytes of conn.
+func NewContextWithTransparent(cc *ContextConfig, conn net.Conn, br *bufio.Reader, hostport string) (*Context, error) {
+	req := &http.Request{
+		Method:     "CONNECT",
+		URL:        &url.URL{Host: hostport},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Host:       hostport,
+		RemoteAddr: conn.RemoteAddr().String(),
+	}
+	c, err := newContext(cc, req, nil, conn)
+	if err != nil {
+		return nil, err
+	}
+	c.UnsafeReader = bufferedConn{br, conn}
+	c.Transparent = true
+	c.replied = true
+	c.Local = true
+	return c, nil
 }
 
 func NewContextFromHandler(cc *ContextConfig, w http.ResponseWriter, req *http.Request) (*Context, error) {
@@ -96,14 +292,12 @@ func newContext(cc *ContextConfig, req *http.Request, resp http.ResponseWriter,
 		UnsafeReader: unsafeReader,
 		Connect:      isConnect,
 		HasPort:      true,
+		Access:       Access{Start: time.Now()},
 	}
 
 	if c.Connect {
 		req.ContentLength = -1
 	} else {
-		// Do not keep-alive any reverse request.
-		// Server will auto add Connection: close?
-		// Every response add too?
 		for k := range req.Header {
 			if strings.EqualFold(k, "upgrade") {
 				c.Connect = true
@@ -114,8 +308,8 @@ func newContext(cc *ContextConfig, req *http.Request, resp http.ResponseWriter,
 	req.RequestURI = ""
 
 	if req.Host == "" {
-		// TODO enable this:
-		//	GET /index.html HTTP/1.1
+		// nothing to route:
+		//	GET /index.html HTTP/1.0
 		return nil, ErrRequestURI
 	}
 
@@ -221,29 +415,36 @@ func (c *Context) SendRequest(remote net.Conn, withProxy bool) (err error) {
 
 // ProxyUp dial to exist http server, SendRequest to remote, waits for remote close.
 // Used by final node.
-func (c *Context) ProxyUp(proxyaddr string, tp http.RoundTripper, keepAlive bool) error {
+func (c *Context) ProxyUp(dial DialFunc, proxyaddr, proxyAuth string, tp http.RoundTripper, keepAlive bool) error {
 	if !c.Connect && c.Writer == nil {
 		// reverse to c.ResponseWriter
-		c.ReverseToResponse(tp)
-		return nil
+		return c.ReverseToResponse(tp)
 	}
 
-	remote, err := net.Dial("tcp", proxyaddr)
+	remote, err := c.dial(dial, proxyaddr)
 	if err != nil {
 		return err
 	}
 	defer remote.Close()
 
 	req := c.Request
-	if keepAlive {
-		req.Header.Del("Connection")
-	} else {
-		req.Header.Set("Connection", "close")
+	if proxyAuth != "" {
+		req.Header.Set("Proxy-Authorization", proxyAuth)
+	}
+	if !c.isUpgrade() {
+		if keepAlive {
+			req.Header.Del("Connection")
+		} else {
+			req.Header.Set("Connection", "close")
+		}
 	}
 
+	// response is copied until remote closed
+	c.KeepAlive = false
 	go c.SendRequest(remote, true)
-	if c.Connect && c.Writer == nil {
-		// connect to c.ResponseWriter
+	if c.Connect && (c.Writer == nil || c.Socks5 || c.replied) {
+		// connect to c.ResponseWriter, or socks5 which needs its own reply,
+		// or already replied
 		bw := bufio.NewReader(remote)
 		res, err := http.ReadResponse(bw, req)
 		if err != nil {
@@ -270,61 +471,201 @@ func (c *Context) ProxyUp(proxyaddr string, tp http.RoundTripper, keepAlive bool
 		}
 	}
 
-	c.copyFromRemote(remote)
-	return nil
+	return c.copyFromRemote(remote)
 }
 
-func (c *Context) writeBytesFromRemote(b []byte) (int, error) {
+func (c *Context) writeBytesFromRemote(b []byte) (n int, err error) {
 	if c.Writer != nil {
-		return c.Writer.Write(b)
+		n, err = c.Writer.Write(b)
+	} else {
+		n, err = c.ResponseWriter.Write(b)
 	}
-	return c.ResponseWriter.Write(b)
+	c.Access.addDown(int64(n))
+	return
 }
 
 // Direct dial to final target, SendRequest to remote, waits for remote close.
-// Used by final node.
-func (c *Context) Direct() error {
+// Reverse requests to Writer reuse conns of ConnPool. Used by final node.
+func (c *Context) Direct() error { return c.DirectDial(c.Transport, net.Dial) }
+
+// DirectDial is Direct but dial final target with dial, reverse to
+// ResponseWriter with tp.
+func (c *Context) DirectDial(tp http.RoundTripper, dial DialFunc) error {
 	if !c.Connect && c.Writer == nil {
 		// reverse to c.ResponseWriter
-		c.ReverseToResponse(c.Transport)
-		return nil
+		return c.ReverseToResponse(tp)
+	}
+
+	if c.Request.URL.Scheme == "https" && c.Request.Method != "CONNECT" {
+		// intercepted
+		dial = c.tlsDial(dial)
+	}
+	if !c.Connect {
+		// reverse to c.Writer
+		return c.roundTrip(dial)
 	}
 
-	remote, err := net.Dial("tcp", c.DialHostPort)
+	remote, err := c.dial(dial, c.DialHostPort)
 	if err != nil {
 		return err
 	}
 	defer remote.Close()
 
+	if c.isUpgrade() {
+		// remote replies the upgrade
+		go c.SendRequest(remote, false)
+		return c.copyFromRemote(remote)
+	}
+	c.writeConncectOK()
+	go c.copyToRemote(remote)
+	return c.copyFromRemote(remote)
+}
+
+// roundTrip writes one request to a pooled or new conn, then writes the
+// parsed response to c.Writer.
+func (c *Context) roundTrip(dial DialFunc) error {
 	req := c.Request
-	if c.Connect {
-		c.writeConncectOK()
-		go c.copyToRemote(remote)
-		c.copyFromRemote(remote)
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	removeHopHeaders(req.Header)
+	req.Close = c.ConnPool == nil
+
+	g := c.startGuard()
+	pc, res, err := c.sendPooled(dial, g)
+	if err != nil {
+		return guardErr(g, err)
+	}
+	defer res.Body.Close()
+
+	c.Access.setStatus(res.StatusCode)
+	reusable := !res.Close && c.ConnPool != nil
+	c.frameResponse(res)
+
+	var dst io.Writer = c.Writer
+	if g != nil {
+		dst = guardWriter{dst, g}
+	}
+	dst = c.rateLimitWriter(dst)
+	err = res.Write(accessWriter{dst, &c.Access})
+	if err != nil {
+		// response is partially written, nothing can be replied
+		c.KeepAlive = false
+		pc.Close()
 		return nil
 	}
 
-	// reverse to c.Writer
-	// must remove connection header
-	if req.Header != nil {
-		req.Header = make(http.Header)
+	if reusable {
+		c.ConnPool.put(c.poolKey(), pc)
+	} else {
+		pc.Close()
 	}
-	delete(req.Header, "Proxy-Connection")
-	req.Header.Set("Connection", "close")
-	// TODO modify stream to set Connection: close
-	go c.SendRequest(remote, false)
-	c.copyFromRemote(remote)
 	return nil
 }
 
+// sendPooled tries idle conns first. Requests without body are resent to a
+// new conn if the idle conn failed, others cannot be resent.
+func (c *Context) sendPooled(dial DialFunc, g *guard) (*pooledConn, *http.Response, error) {
+	req := c.Request
+	if c.ConnPool != nil {
+		resendable := req.Body == nil || req.Body == http.NoBody
+		for {
+			pc := c.ConnPool.get(c.poolKey())
+			if pc == nil {
+				break
+			}
+			res, err := c.send(pc, g)
+			if err == nil {
+				return pc, res, nil
+			}
+			pc.Close()
+			if !resendable {
+				return nil, nil, err
+			}
+		}
+	}
+
+	remote, err := c.dial(dial, c.DialHostPort)
+	if err != nil {
+		return nil, nil, err
+	}
+	pc := &pooledConn{Conn: remote, br: bufio.NewReader(remote)}
+	res, err := c.send(pc, g)
+	if err != nil {
+		pc.Close()
+		return nil, nil, err
+	}
+	return pc, res, nil
+}
+
+// poolKey separates TLS conns of intercepted requests.
+func (c *Context) poolKey() string {
+	return c.Request.URL.Scheme + "://" + c.DialHostPort
+}
+
+func (c *Context) send(pc *pooledConn, g *guard) (*http.Response, error) {
+	if g != nil {
+		g.watch(pc)
+	}
+	req := c.Request
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = accessReadCloser{req.Body, &c.Access}
+	}
+	err := req.Write(pc)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(pc.br, req)
+}
+
+// frameResponse makes res reusable for the client conn if KeepAlive, or
+// closes it.
+func (c *Context) frameResponse(res *http.Response) {
+	removeHopHeaders(res.Header)
+	c.traceResponse(res.Header)
+	c.checkKeepAlive()
+	if !c.KeepAlive {
+		res.Close = true
+		return
+	}
+	if res.ContentLength == -1 && len(res.TransferEncoding) == 0 && bodyAllowed(res) {
+		// unknown length of HTTP/1.0 or closing response
+		res.TransferEncoding = []string{"chunked"}
+	}
+	res.Close = false
+	res.ProtoMajor, res.ProtoMinor = 1, 1
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, k := range []string{"Connection", "Proxy-Connection", "Keep-Alive"} {
+		delete(h, k)
+	}
+}
+
+func bodyAllowed(res *http.Response) bool {
+	if res.Request != nil && res.Request.Method == "HEAD" {
+		return false
+	}
+	switch {
+	case res.StatusCode >= 100 && res.StatusCode <= 199:
+		return false
+	case res.StatusCode == http.StatusNoContent, res.StatusCode == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func (c *Context) isUpgrade() bool {
+	return c.Connect && c.Request.Method != "CONNECT"
+}
+
 // PipeTransport requests with rp, waits for pipe end.
 func (c *Context) PipeTransport(tp http.RoundTripper) error {
 	req := c.Request
 
 	if !c.Connect && c.Writer == nil {
 		// reverse to c.ResponseWriter
-		c.ReverseToResponse(tp)
-		return nil
+		return c.ReverseToResponse(tp)
 	}
 
 	res, err := tp.RoundTrip(req)
@@ -335,8 +676,11 @@ func (c *Context) PipeTransport(tp http.RoundTripper) error {
 
 	if !c.Connect {
 		// reverse to c.Writer
-		res.Close = true
-		res.Write(c.Writer)
+		c.Access.setStatus(res.StatusCode)
+		c.frameResponse(res)
+		if res.Write(accessWriter{c.rateLimitWriter(c.Writer), &c.Access}) != nil {
+			c.KeepAlive = false
+		}
 		return nil
 	}
 
@@ -347,23 +691,40 @@ func (c *Context) PipeTransport(tp http.RoundTripper) error {
 	}
 
 	c.writeConncectOK()
-	c.copyFromRemote(res.Body)
-	return nil
+	return c.copyFromRemote(res.Body)
 }
 
-func (c *Context) ReverseToResponse(tp http.RoundTripper) {
-	rp := httputil.ReverseProxy{
-		Director:       func(r *http.Request) {},
-		Transport:      tp,
-		FlushInterval:  c.FlushInterval,
-		BufferPool:     c.BufferPool,
-		ModifyResponse: nil,
+// ReverseToResponse reverses the request to ResponseWriter with tp. Errors
+// before anything written are returned, so that callers can reply or fail
+// over.
+func (c *Context) ReverseToResponse(tp http.RoundTripper) (err error) {
+	req := c.Request
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = accessReadCloser{req.Body, &c.Access}
 	}
-	rp.ServeHTTP(c.ResponseWriter, c.Request)
+	rp := httputil.ReverseProxy{
+		Director:      func(r *http.Request) {},
+		Transport:     tp,
+		FlushInterval: c.FlushInterval,
+		BufferPool:    c.BufferPool,
+		ModifyResponse: func(res *http.Response) error {
+			c.traceResponse(res.Header)
+			return nil
+		},
+		ErrorHandler: func(rw http.ResponseWriter, r *http.Request, e error) { err = e },
+	}
+	rp.ServeHTTP(accessResponseWriter{c.rateLimitResponseWriter(c.ResponseWriter), &c.Access}, req)
+	return err
 }
 
 func (c *Context) writeConncectOK() {
-	if c.Writer != nil {
+	c.Access.setStatus(http.StatusOK)
+	if c.replied {
+		return
+	}
+	if c.Socks5 {
+		c.Writer.Write(socks5.Reply(socks5.RepSucceeded))
+	} else if c.Writer != nil {
 		c.Writer.Write(StandardConnectOK)
 	} else {
 		c.ResponseWriter.WriteHeader(http.StatusOK)
@@ -375,16 +736,26 @@ func (c *Context) writeConncectOK() {
 }
 
 func (c *Context) copyToRemote(remote io.WriteCloser) {
-	c.Copy(remote, c.bodyReader())
+	n, _ := c.Copy(remote, c.bodyReader())
+	c.Access.addUp(n)
 	remote.Close()
 }
 
-func (c *Context) copyFromRemote(remote io.Reader) {
+// copyFromRemote returns TimeoutError only if nothing written to client, so
+// that it can be written as HttpErr.
+func (c *Context) copyFromRemote(remote io.Reader) error {
+	var n int64
+	var err error
 	if c.Writer != nil {
-		c.Copy(c.Writer, remote)
+		n, err = c.Copy(c.Writer, remote)
 	} else {
-		c.Copy(c.ResponseWriter, remote)
+		n, err = c.Copy(c.ResponseWriter, remote)
+	}
+	c.Access.addDown(n)
+	if _, ok := err.(*TimeoutError); ok && n == 0 && c.Access.Status == 0 {
+		return err
 	}
+	return nil
 }
 
 func (c *Context) NopCloserBody() io.ReadCloser {
@@ -407,34 +778,43 @@ func (c *Context) ResponseWriterOrWrapOne() http.ResponseWriter {
 		if c.ResponseWriter != nil {
 			c.responseWriter = c.ResponseWriter
 		} else {
-			c.responseWriter = netutil.NewResponseWriter(c.Writer)
+			// response is not framed
+			c.KeepAlive = false
+			w := netutil.NewResponseWriter(c.Writer)
+			w.Header().Set("Connection", "close")
+			c.responseWriter = w
 		}
 	}
 	return c.responseWriter
 }
 
 // Copy copies src to dst with BufferPool and interval flush(CONNECT only).
+// Copy returns TimeoutError if IdleTimeout or MaxLifetime reached.
 func (c *Context) Copy(dst io.Writer, src io.Reader) (int64, error) {
-	buf := c.BufferPool.Get()
-	defer c.BufferPool.Put(buf)
 	if c.Connect {
-		return netutil.CopyBufferFlush(dst, src, buf, c.FlushInterval)
+		return c.CopyFlush(dst, src)
 	}
-	return io.CopyBuffer(dst, src, buf)
+	return c.CopyNoFlush(dst, src)
 }
 
 // CopyFlush copies src to dst with BufferPool and interval flush.
 func (c *Context) CopyFlush(dst io.Writer, src io.Reader) (int64, error) {
 	buf := c.BufferPool.Get()
 	defer c.BufferPool.Put(buf)
-	return netutil.CopyBufferFlush(dst, src, buf, c.FlushInterval)
+	dst, src, g := c.guardEnds(dst, src)
+	dst = c.rateLimitWriter(dst)
+	n, err := netutil.CopyBufferFlush(dst, src, buf, c.FlushInterval)
+	return n, guardErr(g, err)
 }
 
 // CopyNoFlush copies src to dst with BufferPool and no interval flush.
 func (c *Context) CopyNoFlush(dst io.Writer, src io.Reader) (int64, error) {
 	buf := c.BufferPool.Get()
 	defer c.BufferPool.Put(buf)
-	return io.CopyBuffer(dst, src, buf)
+	dst, src, g := c.guardEnds(dst, src)
+	dst = c.rateLimitWriter(dst)
+	n, err := io.CopyBuffer(dst, src, buf)
+	return n, guardErr(g, err)
 }
 
 // HybridHttpErr global level HttpErr.
@@ -450,16 +830,109 @@ func (c *Context) HybridHttpErr(code int, info string) {
 }
 
 func (c *Context) HttpErr(he *HttpErr) {
-	if c.Writer != nil {
+	c.Access.setStatus(he.Code)
+	if c.replied {
+		// tunnel is established or client does not know the proxy, just close
+		return
+	}
+	if he.ErrCode == "" {
+		he.ErrCode = c.errCode
+	}
+	if he.Hops == nil {
+		hops, current := domain.Hops(c.HostNoPort)
+		he.Hops = hops
+		if current != -1 {
+			he.Hop = hops[current]
+		}
+	}
+	if he.Template == nil {
+		he.Template = c.ErrorTemplate
+	}
+	he.HTML = c.Request != nil && acceptsHTML(c.Request.Header.Get("Accept"))
+	if c.TraceName != "" {
+		if he.Header == nil {
+			he.Header = make(http.Header)
+		}
+		c.traceResponse(he.Header)
+	}
+	if c.Socks5 {
+		c.Writer.Write(socks5.Reply(socks5Rep(he.Code)))
+	} else if c.Writer != nil {
+		if c.KeepAlive && c.Request.Body != http.NoBody {
+			// request body may be partially read
+			c.KeepAlive = false
+		}
+		if !c.KeepAlive && !c.Connect {
+			if he.Header == nil {
+				he.Header = make(http.Header)
+			}
+			he.Header.Set("Connection", "close")
+		}
 		he.Write(c.Writer)
 	} else {
 		he.WriteResponse(c.ResponseWriter)
 	}
 }
 
+// serveTunnel serves one request read from the CONNECT tunnel, then closes.
+func (c *Context) serveTunnel(h http.Handler) {
+	c.writeConncectOK()
+	req, err := http.ReadRequest(bufio.NewReader(c.UnsafeReader))
+	if err != nil {
+		return
+	}
+	req.URL.Scheme = "http"
+	req.URL.Host = req.Host
+
+	w := netutil.NewResponseWriter(c.Writer)
+	w.Header().Set("Connection", "close")
+	h.ServeHTTP(w, req)
+}
+
+// acceptsHTML reports whether text/html is acceptable in Accept header.
+func acceptsHTML(accept string) bool {
+	for _, v := range strings.Split(accept, ",") {
+		params := strings.Split(v, ";")
+		mtype := strings.ToLower(strings.TrimSpace(params[0]))
+		if mtype != "text/html" && mtype != "application/xhtml+xml" {
+			continue
+		}
+		for _, p := range params[1:] {
+			q := strings.Replace(p, " ", "", -1)
+			if strings.HasPrefix(q, "q=0") && strings.Trim(q[3:], ".0") == "" {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func socks5Rep(code int) byte {
+	switch code {
+	case http.StatusForbidden:
+		return socks5.RepNotAllowed
+	case http.StatusNotFound, http.StatusBadGateway:
+		return socks5.RepHostUnreachable
+	default:
+		return socks5.RepGeneralFailure
+	}
+}
+
 func (c *Context) proxy(p Proxy) {
+	c.Access.setProxy(p)
+	c.traceRequest(!c.Domain.IsHybrid || c.Domain.IsEnd)
 	err := p.Do(c)
+	c.Access.Err = err
 	if err != nil {
-		p.HttpErr(c, http.StatusBadGateway, err.Error())
+		code := http.StatusBadGateway
+		c.errCode = ErrCodeUpstream
+		if _, ok := err.(*TimeoutError); ok {
+			code = http.StatusGatewayTimeout
+			c.errCode = ErrCodeTimeout
+		} else if oe, ok := err.(*net.OpError); ok && oe.Op == "dial" {
+			c.errCode = ErrCodeDialFailed
+		}
+		p.HttpErr(c, code, err.Error())
 	}
 }
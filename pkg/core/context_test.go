@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeProxy reverses requests with tp, like H2Client.
+type pipeProxy struct {
+	tp http.RoundTripper
+}
+
+func (p pipeProxy) Do(c *Context) error                       { return c.PipeTransport(p.tp) }
+func (p pipeProxy) HttpErr(c *Context, code int, info string) { DirectProxy.HttpErr(c, code, info) }
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestKeepAliveUnreadBody(t *testing.T) {
+	smuggled := "GET http://example.com/smuggled HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	for _, tc := range []struct {
+		name      string
+		readBody  bool
+		keepAlive bool
+	}{
+		{"early response", false, false},
+		{"body read", true, true},
+	} {
+		paths := make(chan string, 2)
+		tp := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			paths <- req.URL.Path
+			code := http.StatusUnauthorized
+			if tc.readBody {
+				ioutil.ReadAll(req.Body)
+				code = http.StatusOK
+			}
+			return &http.Response{
+				StatusCode:    code,
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        make(http.Header),
+				Body:          http.NoBody,
+				ContentLength: 0,
+				Request:       req,
+			}, nil
+		})
+		ln := serveCore(t, newTestCore(&testRouter{p: pipeProxy{tp}}))
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial should get no err, but got: %v", err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(conn, "POST http://example.com/upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: %d\r\n\r\n%s",
+			len(smuggled), smuggled)
+		br := bufio.NewReader(conn)
+		res, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("%s should get response, but got: %v", tc.name, err)
+		}
+		res.Body.Close()
+
+		if tc.keepAlive {
+			if res.Close {
+				t.Errorf("%s should keep alive", tc.name)
+			}
+			io.WriteString(conn, "GET http://example.com/next HTTP/1.1\r\nHost: example.com\r\n\r\n")
+			res, err = http.ReadResponse(br, nil)
+			if err != nil {
+				t.Errorf("%s should get the next response, but got: %v", tc.name, err)
+			}
+		} else {
+			if !res.Close {
+				t.Errorf("%s should close the conn", tc.name)
+			}
+			if _, err := br.ReadByte(); err != io.EOF {
+				t.Errorf("%s should get EOF, but got: %v", tc.name, err)
+			}
+		}
+		conn.Close()
+		ln.Close()
+
+		close(paths)
+		var got []string
+		for path := range paths {
+			got = append(got, path)
+		}
+		want := "/upload"
+		if tc.keepAlive {
+			want = "/upload /next"
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%s should request %s, but got: %v", tc.name, want, got)
+		}
+	}
+}
+
+func TestOriginFormByHost(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.RequestURI())
+	}))
+	defer target.Close()
+	targetHost := strings.TrimPrefix(target.URL, "http://")
+
+	routed := make(chan string, 1)
+	router := &routeFunc{func(c *Context) Proxy {
+		routed <- c.HostPort
+		return DirectProxy
+	}}
+	ln := serveCore(t, newTestCore(router))
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(conn)
+	for _, path := range []string{"/index.html?a=1", "/next"} {
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\n\r\n", path, targetHost)
+		res, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("origin-form should get response, but got: %v", err)
+		}
+		b, _ := ioutil.ReadAll(res.Body)
+		if want := targetHost + " " + path; string(b) != want {
+			t.Errorf("origin-form should be reversed to %q, but got: %q", want, b)
+		}
+		if got := <-routed; got != targetHost {
+			t.Errorf("origin-form should be routed by Host %s, but got: %s", targetHost, got)
+		}
+	}
+}
+
+func TestOriginFormProxyLoop(t *testing.T) {
+	ln := listen(t)
+	defer ln.Close()
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		_, err = NewContextWithConn(new(ContextConfig), conn)
+		errs <- err
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\n\r\n", ln.Addr())
+	if err := <-errs; err != ErrProxyLoop {
+		t.Errorf("request to the listener itself should get ErrProxyLoop, but got: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/empirefox/hybrid/pkg/mitm"
 	"go.uber.org/zap"
 )
 
@@ -13,9 +14,37 @@ type Core struct {
 	Routers       []Router
 	Proxies       map[string]Proxy
 	LocalServers  map[string]http.Handler
+
+	// OnAccess is called when c is finished, can be nil.
+	OnAccess func(c *Context)
+
+	// MITM intercepts CONNECT tunnels of matched hosts, can be nil.
+	MITM *mitm.Interceptor
+
+	// Unhealthy reports proxies that routers should not route to, the next
+	// router is tried then. Can be nil.
+	Unhealthy func(p Proxy) bool
+
+	// Middlewares must be set by Use.
+	Middlewares []Middleware
+	handler     HandlerFunc
 }
 
 func (core *Core) Proxy(c *Context) {
+	defer c.stopGuard()
+	if core.OnAccess != nil {
+		defer core.OnAccess(c)
+	}
+
+	if core.handler != nil {
+		core.handler(c)
+	} else {
+		core.serve(c)
+	}
+	c.checkKeepAlive()
+}
+
+func (core *Core) serve(c *Context) {
 	if c.ResponseWriter != nil {
 		req := c.Request
 		ctx := req.Context()
@@ -42,7 +71,12 @@ func (core *Core) Proxy(c *Context) {
 			if core.LocalServers != nil {
 				handler, ok := core.LocalServers[c.Domain.DialHostname]
 				if ok {
-					handler.ServeHTTP(c.ResponseWriterOrWrapOne(), c.Request)
+					c.traceRequest(true)
+					if c.Socks5 || c.Transparent {
+						c.serveTunnel(handler)
+					} else {
+						handler.ServeHTTP(c.ResponseWriterOrWrapOne(), c.Request)
+					}
 					return
 				}
 			}
@@ -66,23 +100,74 @@ func (core *Core) Proxy(c *Context) {
 		return
 	}
 
+	if core.intercept(c) {
+		return
+	}
 	core.routeProxy(c)
 }
 
 func (core *Core) routeProxy(c *Context) {
+	rc, p := core.route(c, nil)
+	if namer, ok := rc.(Namer); ok {
+		c.Access.Router = namer.Name()
+	}
+	c.proxy(p)
+}
+
+// route returns the proxy and its router, or DirectProxy if no router routes.
+// Routers tried are recorded to e if not nil.
+func (core *Core) route(c *Context, e *RouteExplanation) (Router, Proxy) {
 	for _, rc := range core.Routers {
 		if rc.Disabled() {
+			if e != nil {
+				e.Steps = append(e.Steps, RouteStep{Router: routerName(rc), Skipped: "disabled"})
+			}
 			continue
 		}
+		if p := core.routeBy(c, rc, false, e); p != nil {
+			return rc, p
+		}
+	}
 
-		p := rc.Route(c)
-		if p == nil {
+	for _, rc := range core.Routers {
+		if _, ok := rc.(LateRouter); !ok || rc.Disabled() {
 			continue
 		}
+		if p := core.routeBy(c, rc, true, e); p != nil {
+			return rc, p
+		}
+	}
 
-		c.proxy(p)
-		return
+	return nil, DirectProxy
+}
+
+// routeBy returns the proxy routed by rc, nil if unhealthy.
+func (core *Core) routeBy(c *Context, rc Router, late bool, e *RouteExplanation) Proxy {
+	var p Proxy
+	var rule MatchedRule
+	if ex, ok := rc.(Explainer); ok && e != nil {
+		p, rule = ex.Explain(c, late)
+	} else if late {
+		p = rc.(LateRouter).RouteLate(c)
+	} else {
+		p = rc.Route(c)
 	}
 
-	c.proxy(DirectProxy)
+	var skipped string
+	if p != nil && core.Unhealthy != nil && core.Unhealthy(p) {
+		skipped = "unhealthy"
+	}
+	if e != nil {
+		e.Steps = append(e.Steps, RouteStep{
+			Router:  routerName(rc),
+			Late:    late,
+			Proxy:   core.proxyName(p),
+			Rule:    rule,
+			Skipped: skipped,
+		})
+	}
+	if skipped != "" {
+		return nil
+	}
+	return p
 }
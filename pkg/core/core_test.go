@@ -0,0 +1,141 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+)
+
+type testRouter struct {
+	name     string
+	p        Proxy
+	disabled bool
+}
+
+func (r *testRouter) Disabled() bool         { return r.disabled }
+func (r *testRouter) Name() string           { return r.name }
+func (r *testRouter) Route(c *Context) Proxy { return r.p }
+
+type routeFunc struct {
+	route func(c *Context) Proxy
+}
+
+func (r *routeFunc) Disabled() bool         { return false }
+func (r *routeFunc) Route(c *Context) Proxy { return r.route(c) }
+
+func newTestCore(routers ...Router) *Core {
+	return &Core{
+		ContextConfig: &ContextConfig{
+			Transport:     http.DefaultTransport,
+			BufferPool:    bufpool.Default,
+			FlushInterval: 100 * time.Millisecond,
+		},
+		Routers: routers,
+	}
+}
+
+func listen(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	return ln
+}
+
+// serveCore proxies conns of the returned listener with co, requests are
+// read from a conn while KeepAlive, like the node does.
+func serveCore(t *testing.T, co *Core) net.Listener {
+	ln := listen(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					c, err := NewContextWithReader(co.ContextConfig, conn, br)
+					if err != nil {
+						return
+					}
+					co.Proxy(c)
+					if !c.KeepAlive {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+// echoServer writes back what read from its conns.
+func echoServer(t *testing.T) net.Listener {
+	ln := listen(t)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+// pipeConns copies between a and b until one closed.
+func pipeConns(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// connectThrough sends CONNECT hostport to the proxy at addr, and returns the
+// tunnel after 200 read.
+func connectThrough(t *testing.T, addr, hostport string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.WriteString(conn, "CONNECT "+hostport+" HTTP/1.1\r\nHost: "+hostport+"\r\n\r\n")
+	if err != nil {
+		t.Fatalf("write CONNECT should get no err, but got: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		t.Fatalf("CONNECT should get response, but got: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT should get 200, but got: %d", res.StatusCode)
+	}
+	return conn, br
+}
+
+// expectEcho writes msg to the tunnel and reads it back.
+func expectEcho(t *testing.T, conn net.Conn, br *bufio.Reader, msg string) {
+	_, err := io.WriteString(conn, msg)
+	if err != nil {
+		t.Fatalf("write tunnel should get no err, but got: %v", err)
+	}
+	b := make([]byte, len(msg))
+	_, err = io.ReadFull(br, b)
+	if err != nil || string(b) != msg {
+		t.Errorf("tunnel should echo %q, but got: %q %v", msg, b, err)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// MatchedRule is the rule of a route decision.
+type MatchedRule struct {
+	Text string // the matched rule, or why no rule matched
+	File string // the list file of Text, if known
+}
+
+// Explainer is optionally implemented by Router. Explain routes like Route,
+// or RouteLate if late, with the matched rule.
+type Explainer interface {
+	Explain(c *Context, late bool) (Proxy, MatchedRule)
+}
+
+// RouteStep is a router tried by Explain.
+type RouteStep struct {
+	Router  string
+	Late    bool
+	Proxy   string // empty if not routed
+	Rule    MatchedRule
+	Skipped string // why the router or its proxy is skipped
+}
+
+// RouteExplanation is the dry run of routing a Context.
+type RouteExplanation struct {
+	Steps  []RouteStep
+	Router string // empty if no router routes
+	Proxy  string
+}
+
+// Explain routes c over Routers without dialing. SNI is not sniffed.
+func (core *Core) Explain(c *Context) *RouteExplanation {
+	e := new(RouteExplanation)
+	if c.Domain.IsHybrid && !c.Domain.IsEnd {
+		e.Proxy = c.Domain.Next
+		return e
+	}
+	if c.Domain.IsHybrid && core.LocalServers != nil {
+		if _, ok := core.LocalServers[c.Domain.DialHostname]; ok {
+			e.Proxy = "local:" + c.Domain.DialHostname
+			return e
+		}
+	}
+
+	rc, p := core.route(c, e)
+	e.Router = routerName(rc)
+	e.Proxy = core.proxyName(p)
+	return e
+}
+
+// ExplainURL explains a GET request to rawurl with ctx, which bounds lookups
+// of routers. https urls are explained as CONNECT tunnels, like browsers
+// request.
+func (core *Core) ExplainURL(ctx context.Context, rawurl string) (*RouteExplanation, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, ErrRequestURI
+	}
+
+	var req *http.Request
+	if u.Scheme == "https" {
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		hostport := net.JoinHostPort(u.Hostname(), port)
+		req = &http.Request{
+			Method: "CONNECT",
+			URL:    &url.URL{Host: hostport},
+			Host:   hostport,
+			Header: make(http.Header),
+		}
+	} else {
+		req, err = http.NewRequest("GET", rawurl, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c, err := NewContextFromHandler(core.ContextConfig, discardResponseWriter{}, req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return core.Explain(c), nil
+}
+
+func routerName(rc Router) string {
+	if namer, ok := rc.(Namer); ok {
+		return namer.Name()
+	}
+	return ""
+}
+
+func (core *Core) proxyName(p Proxy) string {
+	if p == nil {
+		return ""
+	}
+	for name, v := range core.Proxies {
+		if v == p {
+			return name
+		}
+	}
+	if p == DirectProxy {
+		return "DIRECT"
+	}
+	if namer, ok := p.(Namer); ok {
+		return namer.Name()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+type discardResponseWriter struct{}
+
+func (discardResponseWriter) Header() http.Header         { return make(http.Header) }
+func (discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardResponseWriter) WriteHeader(int)             {}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type testProxy string
+
+func (p testProxy) Do(c *Context) error                       { return nil }
+func (p testProxy) HttpErr(c *Context, code int, info string) {}
+
+// testExplainer routes to p by rule, or to late by late rule in RouteLate.
+type testExplainer struct {
+	testRouter
+	rule string
+	late Proxy
+}
+
+func (r *testExplainer) RouteLate(c *Context) Proxy { return r.late }
+
+func (r *testExplainer) Explain(c *Context, late bool) (Proxy, MatchedRule) {
+	if late {
+		if r.late == nil {
+			return nil, MatchedRule{}
+		}
+		return r.late, MatchedRule{Text: "late " + r.rule}
+	}
+	if r.p == nil {
+		return nil, MatchedRule{}
+	}
+	return r.p, MatchedRule{Text: r.rule, File: "rules.txt"}
+}
+
+func TestRouteSteps(t *testing.T) {
+	sick, direct, late := testProxy("sick"), testProxy("direct"), testProxy("late")
+	co := newTestCore(
+		&testRouter{name: "disabled", p: direct, disabled: true},
+		&testRouter{name: "sick", p: sick},
+		&testExplainer{testRouter: testRouter{name: "none"}, rule: "none"},
+		&testExplainer{testRouter: testRouter{name: "late"}, rule: "late.example", late: late},
+		&testRouter{name: "disabled-late", disabled: true},
+	)
+	co.Proxies = map[string]Proxy{"SICK": sick, "LATE": late}
+	co.Unhealthy = func(p Proxy) bool { return p == sick }
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	c, err := NewContextFromHandler(co.ContextConfig, discardResponseWriter{}, req)
+	if err != nil {
+		t.Fatalf("NewContextFromHandler should get no err, but got: %v", err)
+	}
+
+	e := co.Explain(c)
+	want := []RouteStep{
+		{Router: "disabled", Skipped: "disabled"},
+		{Router: "sick", Proxy: "SICK", Skipped: "unhealthy"},
+		{Router: "none"},
+		{Router: "late"},
+		{Router: "disabled-late", Skipped: "disabled"},
+		{Router: "none", Late: true},
+		{Router: "late", Late: true, Proxy: "LATE", Rule: MatchedRule{Text: "late late.example"}},
+	}
+	if !reflect.DeepEqual(e.Steps, want) {
+		t.Errorf("Explain should record steps:\n%+v\nbut got:\n%+v", want, e.Steps)
+	}
+	if e.Router != "late" || e.Proxy != "LATE" {
+		t.Errorf("Explain should get the late router and proxy, but got: %s %s", e.Router, e.Proxy)
+	}
+
+	// route without explanation by Route and RouteLate
+	rc, p := co.route(c, nil)
+	if routerName(rc) != "late" || p != late {
+		t.Errorf("route should get the late router and proxy, but got: %v %v", rc, p)
+	}
+
+	co.Unhealthy = nil
+	rc, p = co.route(c, nil)
+	if routerName(rc) != "sick" || p != sick {
+		t.Errorf("route should get the healthy proxy, but got: %v %v", rc, p)
+	}
+
+	co = newTestCore(&testRouter{name: "none"})
+	e = co.Explain(c)
+	if len(e.Steps) != 1 || e.Router != "" || e.Proxy != "DIRECT" {
+		t.Errorf("Explain should get DIRECT if no router routes, but got: %+v", e)
+	}
+}
+
+type explainKey struct{}
+
+func TestExplainURL(t *testing.T) {
+	ctx := context.WithValue(context.Background(), explainKey{}, "rpc")
+	var routed []*Context
+	co := newTestCore(&routeFunc{func(c *Context) Proxy {
+		routed = append(routed, c)
+		return nil
+	}})
+
+	for _, tt := range []struct {
+		rawurl   string
+		connect  bool
+		hostPort string
+	}{
+		{"https://example.com/path?q=1", true, "example.com:443"},
+		{"https://example.com:8443/", true, "example.com:8443"},
+		{"http://example.com/path", false, "example.com:80"},
+	} {
+		routed = nil
+		e, err := co.ExplainURL(ctx, tt.rawurl)
+		if err != nil {
+			t.Fatalf("ExplainURL(%s) should get no err, but got: %v", tt.rawurl, err)
+		}
+		if e.Proxy != "DIRECT" || len(routed) != 1 {
+			t.Fatalf("ExplainURL(%s) should route once to DIRECT, but got: %+v", tt.rawurl, e)
+		}
+		c := routed[0]
+		if c.Request.Context().Value(explainKey{}) != "rpc" {
+			t.Errorf("ExplainURL(%s) should route with ctx", tt.rawurl)
+		}
+		if c.Connect != tt.connect || c.DialHostPort != tt.hostPort || c.HostNoPort != "example.com" {
+			t.Errorf("ExplainURL(%s) should route connect:%v %s, but got: %v %s %s",
+				tt.rawurl, tt.connect, tt.hostPort, c.Connect, c.DialHostPort, c.HostNoPort)
+		}
+	}
+
+	if _, err := co.ExplainURL(ctx, "/path"); err != ErrRequestURI {
+		t.Errorf("ExplainURL without host should get ErrRequestURI, but got: %v", err)
+	}
+}
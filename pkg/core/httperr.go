@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"html/template"
 	"io"
 	"net/http"
 	"net/http/httputil"
@@ -11,12 +12,111 @@ import (
 	"github.com/empirefox/hybrid/pkg/bufpool"
 )
 
+// ErrCode is the stable machine-readable reason of HttpErr.
+type ErrCode string
+
+const (
+	ErrCodeBadRequest   ErrCode = "BAD_REQUEST"
+	ErrCodeAuthRequired ErrCode = "AUTH_REQUIRED"
+	ErrCodeDenied       ErrCode = "DENIED"
+	ErrCodeNotFound     ErrCode = "NOT_FOUND"
+	ErrCodeDialFailed   ErrCode = "DIAL_FAILED"
+	ErrCodeTimeout      ErrCode = "TIMEOUT"
+	ErrCodeUpstream     ErrCode = "UPSTREAM_ERROR"
+	ErrCodeInternal     ErrCode = "INTERNAL_ERROR"
+)
+
+var errCodeHints = map[ErrCode]string{
+	ErrCodeBadRequest:   "Check the request url and headers sent to the proxy.",
+	ErrCodeAuthRequired: "Configure the proxy username and password of the client.",
+	ErrCodeDenied:       "The target is denied by the proxy config, ask the admin to allow it.",
+	ErrCodeNotFound:     "Check the hybrid names in the url, and that the node is connected.",
+	ErrCodeDialFailed:   "The target is unreachable from the last hop, check the host and port.",
+	ErrCodeTimeout:      "The target or a hop is too slow, retry later or raise the timeouts.",
+	ErrCodeUpstream:     "The target or a hop closed the stream unexpectedly, retry later.",
+	ErrCodeInternal:     "Report it to the admin with the info below.",
+}
+
+// errCodeOf maps status code to the default ErrCode.
+func errCodeOf(code int) ErrCode {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized, http.StatusProxyAuthRequired:
+		return ErrCodeAuthRequired
+	case http.StatusForbidden:
+		return ErrCodeDenied
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusBadGateway:
+		return ErrCodeUpstream
+	case http.StatusGatewayTimeout:
+		return ErrCodeTimeout
+	default:
+		return ErrCodeInternal
+	}
+}
+
 type HttpErr struct {
-	Code       int    `json:"-"`
-	ClientType string `json:",omitempty"`
-	ClientName string `json:",omitempty"`
-	TargetHost string `json:",omitempty"`
-	Info       string `json:",omitempty"`
+	Code       int     `json:"-"`
+	ErrCode    ErrCode `json:",omitempty"`
+	ClientType string  `json:",omitempty"`
+	ClientName string  `json:",omitempty"`
+	TargetHost string  `json:",omitempty"`
+	Info       string  `json:",omitempty"`
+	Hint       string  `json:",omitempty"`
+
+	// Hops is the hybrid route of TargetHost, Hop is the node that replies
+	// the error, empty means the client node.
+	Hops []string `json:",omitempty"`
+	Hop  string   `json:",omitempty"`
+
+	// Header is added to response.
+	Header http.Header `json:"-"`
+
+	// HTML renders Template instead of json.
+	HTML     bool               `json:"-"`
+	Template *template.Template `json:"-"`
+}
+
+// DefaultErrorTemplate renders HttpErr as html when no template is set.
+var DefaultErrorTemplate = template.Must(template.New("error").Parse(defaultErrorHTML))
+
+const defaultErrorHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Code}} {{.Status}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 3em auto; color: #333; }
+code { background: #eee; padding: 0 .2em; }
+.current { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Code}} {{.Status}}</h1>
+<p><code>{{.ErrCode}}</code>{{if .ClientType}} from {{.ClientType}}{{if .ClientName}} <code>{{.ClientName}}</code>{{end}}{{end}}</p>
+{{if .TargetHost}}<p>Target: <code>{{.TargetHost}}</code></p>{{end}}
+{{if .Hops}}<p>Hops: client{{range .Hops}} &rarr; <span{{if eq . $.Hop}} class="current"{{end}}>{{.}}</span>{{end}}</p>{{end}}
+{{if .Info}}<pre>{{.Info}}</pre>{{end}}
+{{if .Hint}}<p>{{.Hint}}</p>{{end}}
+</body>
+</html>
+`
+
+// fill sets the default ErrCode and Hint.
+func (he *HttpErr) fill() {
+	if he.ErrCode == "" {
+		he.ErrCode = errCodeOf(he.Code)
+	}
+	if he.Hint == "" {
+		he.Hint = errCodeHints[he.ErrCode]
+	}
+}
+
+// Status is the status text of Code.
+func (he *HttpErr) Status() string {
+	return http.StatusText(he.Code)
 }
 
 func (he *HttpErr) Write(w io.Writer) error {
@@ -30,41 +130,56 @@ func (he *HttpErr) Write(w io.Writer) error {
 }
 
 func (he *HttpErr) WriteResponse(w http.ResponseWriter) error {
-	w.WriteHeader(he.Code)
-
 	res, err := he.Response()
 	if err != nil {
+		w.WriteHeader(he.Code)
 		return err
 	}
 	defer res.Body.Close()
 
 	for k, v := range res.Header {
-		w.Header().Set(k, v[0])
+		w.Header()[k] = v
 	}
 	w.Header().Set("Content-Length", strconv.FormatInt(res.ContentLength, 10))
+	w.WriteHeader(he.Code)
 	_, err = res.Body.(*bufferBody).WriteTo(w)
 	return err
 }
 
 func (he *HttpErr) Response() (*http.Response, error) {
+	he.fill()
 	body := newBufferBody(bufpool.Default1K)
-	err := json.NewEncoder(body).Encode(he)
+	ctype := "application/json"
+	var err error
+	if he.HTML {
+		ctype = "text/html; charset=utf-8"
+		tmpl := he.Template
+		if tmpl == nil {
+			tmpl = DefaultErrorTemplate
+		}
+		err = tmpl.Execute(body, he)
+	} else {
+		err = json.NewEncoder(body).Encode(he)
+	}
 	if err != nil {
 		body.Close()
 		return nil, err
 	}
 
 	resp := &http.Response{
-		Status:     "Hybrid Error",
+		Status:     strconv.Itoa(he.Code) + " " + he.Status(),
 		StatusCode: he.Code,
 		ProtoMajor: 1,
 		ProtoMinor: 1,
 		Header: http.Header{
-			"Content-Type": []string{"application/json"},
+			"Content-Type": []string{ctype},
 		},
 		Body:          body,
 		ContentLength: int64(body.Len()),
 	}
+	for k, v := range he.Header {
+		resp.Header[k] = v
+	}
 	return resp, nil
 }
 
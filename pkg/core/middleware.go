@@ -0,0 +1,25 @@
+package core
+
+// HandlerFunc handles Context, the inner most one routes and proxies.
+type HandlerFunc func(c *Context)
+
+// Middleware intercepts Context before routing.
+type Middleware interface {
+	// Wrap returns handler which should call next to continue.
+	Wrap(next HandlerFunc) HandlerFunc
+}
+
+type MiddlewareFunc func(next HandlerFunc) HandlerFunc
+
+func (f MiddlewareFunc) Wrap(next HandlerFunc) HandlerFunc { return f(next) }
+
+// Use appends middlewares, the first one runs first.
+// It must be called before serving.
+func (core *Core) Use(ms ...Middleware) {
+	core.Middlewares = append(core.Middlewares, ms...)
+	h := core.serve
+	for i := len(core.Middlewares) - 1; i >= 0; i-- {
+		h = core.Middlewares[i].Wrap(h)
+	}
+	core.handler = h
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// tunnelConn reads from the CONNECT tunnel reader which may buffer conn.
+type tunnelConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c tunnelConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// intercept terminates TLS of the CONNECT tunnel if MITM matched, then every
+// decrypted request is proxied as a new Context.
+func (core *Core) intercept(c *Context) bool {
+	if core.MITM == nil || !c.Connect || c.isUpgrade() || !core.MITM.Match(c.HostNoPort) {
+		return false
+	}
+	conn, ok := c.Writer.(net.Conn)
+	if !ok {
+		// peers, intercepted by the first node
+		return false
+	}
+
+	c.Access.Proxy = "MITM"
+	c.writeConncectOK()
+	tconn := tls.Server(tunnelConn{conn, c.bodyReader()}, core.MITM.TLSConfig(c.HostNoPort))
+	br := bufio.NewReader(tconn)
+	for {
+		if c.IdleTimeout != 0 {
+			conn.SetReadDeadline(time.Now().Add(c.IdleTimeout))
+		}
+		if _, err := br.Peek(1); err != nil {
+			// handshake failed, closed or idle
+			return true
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		ic, err := c.newIntercepted(tconn, br)
+		if err != nil {
+			he := HttpErr{
+				Code:       http.StatusBadRequest,
+				ClientType: "Hybrid",
+				ClientName: "MITM",
+				TargetHost: c.HostPort,
+				Info:       err.Error(),
+			}
+			he.Write(tconn)
+			return true
+		}
+		core.Proxy(ic)
+		if !ic.KeepAlive {
+			return true
+		}
+	}
+}
+
+func (c *Context) newIntercepted(tconn *tls.Conn, br *bufio.Reader) (*Context, error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = c.Request.RemoteAddr
+	if req.Host == "" {
+		req.Host = c.HostPort
+	}
+	req.URL.Scheme = "https"
+	req.URL.Host = req.Host
+
+	ic, err := newContext(&c.ContextConfig, req, nil, tconn)
+	if err != nil {
+		return nil, err
+	}
+	ic.UnsafeReader = bufferedConn{br, tconn}
+	ic.Local = c.Local
+	ic.Intercepted = true
+	ic.KeepAlive = !ic.Connect && !req.Close
+	return ic, nil
+}
+
+// tlsDial dials TLS to HostNoPort with dial. TLSClientConfig of Transport
+// is used if it is a *http.Transport.
+func (c *Context) tlsDial(dial DialFunc) DialFunc {
+	config := new(tls.Config)
+	if tr, ok := c.Transport.(*http.Transport); ok && tr.TLSClientConfig != nil {
+		config = tr.TLSClientConfig.Clone()
+	}
+	config.ServerName = c.HostNoPort
+	config.NextProtos = []string{"http/1.1"}
+
+	return func(network, address string) (net.Conn, error) {
+		conn, err := dial(network, address)
+		if err != nil {
+			return nil, err
+		}
+		tconn := tls.Client(conn, config)
+		if err = tconn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tconn, nil
+	}
+}
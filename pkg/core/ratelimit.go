@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const maxIdleClientLimiters = 1024
+
+// RateLimits holds token buckets of bytes, the global one, one per proxy
+// name and one per client IP. Zero limit means unlimited.
+type RateLimits struct {
+	global *rate.Limiter
+
+	mu        sync.Mutex
+	proxies   map[string]*rate.Limiter
+	clientBps int
+	clients   map[string]*clientLimiter
+}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func NewRateLimits() *RateLimits {
+	return &RateLimits{
+		global:  newLimiter(0),
+		proxies: make(map[string]*rate.Limiter),
+		clients: make(map[string]*clientLimiter),
+	}
+}
+
+// newLimiter starts with burst 1, so that writers never see a finite limit
+// with zero burst while setLimit.
+func newLimiter(bps int) *rate.Limiter {
+	l := rate.NewLimiter(rate.Inf, 1)
+	setLimit(l, bps)
+	return l
+}
+
+func setLimit(l *rate.Limiter, bps int) {
+	if bps <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetLimit(rate.Limit(bps))
+	l.SetBurst(bps)
+}
+
+func (rl *RateLimits) SetGlobal(bps int) { setLimit(rl.global, bps) }
+
+func (rl *RateLimits) SetProxy(name string, bps int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if l, ok := rl.proxies[name]; ok {
+		setLimit(l, bps)
+	} else {
+		rl.proxies[name] = newLimiter(bps)
+	}
+}
+
+// SetClient sets limit of every client IP.
+func (rl *RateLimits) SetClient(bps int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.clientBps = bps
+	for _, l := range rl.clients {
+		setLimit(l.Limiter, bps)
+	}
+}
+
+func (rl *RateLimits) limiters(proxy, ip string) []*rate.Limiter {
+	ls := []*rate.Limiter{rl.global}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if l, ok := rl.proxies[proxy]; ok {
+		ls = append(ls, l)
+	}
+	if ip != "" && rl.clientBps > 0 {
+		now := time.Now()
+		l, ok := rl.clients[ip]
+		if !ok {
+			if len(rl.clients) >= maxIdleClientLimiters {
+				rl.evictClients(now)
+			}
+			l = &clientLimiter{Limiter: newLimiter(rl.clientBps)}
+			rl.clients[ip] = l
+		}
+		l.lastSeen = now
+		ls = append(ls, l.Limiter)
+	}
+	return ls
+}
+
+func (rl *RateLimits) evictClients(now time.Time) {
+	for ip, l := range rl.clients {
+		if now.Sub(l.lastSeen) > time.Minute {
+			delete(rl.clients, ip)
+		}
+	}
+}
+
+type rateWriter struct {
+	io.Writer
+	ctx      context.Context
+	limiters []*rate.Limiter
+}
+
+func (w rateWriter) Write(b []byte) (written int, err error) {
+	for len(b) > 0 {
+		chunk := b
+		for _, l := range w.limiters {
+			if l.Limit() == rate.Inf {
+				continue
+			}
+			if burst := l.Burst(); len(chunk) > burst {
+				chunk = chunk[:burst]
+			}
+		}
+		for _, l := range w.limiters {
+			err = l.WaitN(w.ctx, len(chunk))
+			if err != nil {
+				return
+			}
+		}
+		var n int
+		n, err = w.Writer.Write(chunk)
+		written += n
+		if err != nil {
+			return
+		}
+		b = b[n:]
+	}
+	return
+}
+
+type rateFlushWriter struct {
+	rateWriter
+	http.Flusher
+}
+
+// rateLimitWriter limits dst with RateLimits of proxy and client IP.
+func (c *Context) rateLimitWriter(dst io.Writer) io.Writer {
+	if c.RateLimits == nil {
+		return dst
+	}
+	ip, _, _ := net.SplitHostPort(c.Request.RemoteAddr)
+	w := rateWriter{
+		Writer:   dst,
+		ctx:      c.Request.Context(),
+		limiters: c.RateLimits.limiters(c.Access.Proxy, ip),
+	}
+	if flusher, ok := dst.(http.Flusher); ok {
+		return rateFlushWriter{w, flusher}
+	}
+	return w
+}
+
+type rateResponseWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (w rateResponseWriter) Write(b []byte) (int, error) { return w.w.Write(b) }
+
+func (w rateResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// rateLimitResponseWriter limits body of rw like rateLimitWriter.
+func (c *Context) rateLimitResponseWriter(rw http.ResponseWriter) http.ResponseWriter {
+	if c.RateLimits == nil {
+		return rw
+	}
+	return rateResponseWriter{rw, c.rateLimitWriter(rw)}
+}
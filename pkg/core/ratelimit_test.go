@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+)
+
+func TestRateLimitReverse(t *testing.T) {
+	const bps = 20000
+	// buckets start empty, so the body takes 0.5s
+	body := bytes.Repeat([]byte("x"), bps/2)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer target.Close()
+
+	reverses := map[string]func(cc *ContextConfig) ([]byte, error){
+		"ResponseWriter": func(cc *ContextConfig) ([]byte, error) {
+			req, _ := http.NewRequest("GET", target.URL, nil)
+			w := httptest.NewRecorder()
+			c, err := NewContextFromHandler(cc, w, req)
+			if err != nil {
+				return nil, err
+			}
+			err = c.PipeTransport(http.DefaultTransport)
+			return w.Body.Bytes(), err
+		},
+		"Writer": func(cc *ContextConfig) ([]byte, error) {
+			req, _ := http.NewRequest("GET", target.URL, nil)
+			client, proxySide := net.Pipe()
+			defer client.Close()
+			c, err := newContext(cc, req, nil, proxySide)
+			if err != nil {
+				return nil, err
+			}
+			got := make(chan []byte, 1)
+			go func() {
+				b, _ := ioutil.ReadAll(client)
+				got <- b
+			}()
+			err = c.PipeTransport(http.DefaultTransport)
+			proxySide.Close()
+			return <-got, err
+		},
+	}
+	for name, reverse := range reverses {
+		rl := NewRateLimits()
+		rl.SetGlobal(bps)
+		cc := &ContextConfig{BufferPool: bufpool.Default, RateLimits: rl}
+
+		start := time.Now()
+		b, err := reverse(cc)
+		d := time.Since(start)
+		if err != nil {
+			t.Fatalf("%s reverse should get no err, but got: %v", name, err)
+		}
+		if !bytes.Contains(b, body) {
+			t.Errorf("%s reverse should get the body, but got %d bytes", name, len(b))
+		}
+		if d < 400*time.Millisecond {
+			t.Errorf("%s reverse should be limited to %d B/s, but got %d bytes in %v", name, bps, len(b), d)
+		}
+	}
+}
+
+func TestRateWriterWhileSetLimit(t *testing.T) {
+	l := newLimiter(0)
+	// setLimit is between SetLimit and SetBurst
+	l.SetLimit(rate.Limit(1000))
+
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := rateWriter{&buf, context.Background(), []*rate.Limiter{l}}.Write([]byte("abc"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || buf.String() != "abc" {
+			t.Errorf("Write should get all written, but got: %q %v", buf.String(), err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Write should not spin on a limiter without burst")
+	}
+}
@@ -1,9 +1,15 @@
 package core
 
 import (
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
 )
 
 type Router interface {
@@ -14,11 +20,24 @@ type Router interface {
 	Route(c *Context) Proxy
 }
 
+// LateRouter is optionally implemented by Router. RouteLate of routers are
+// called in order only if no router routes, so that expensive matching like
+// DNS resolving is delayed.
+type LateRouter interface {
+	RouteLate(c *Context) Proxy
+}
+
 type Proxy interface {
 	Do(c *Context) error
 	HttpErr(c *Context, code int, info string)
 }
 
+// Prober is optionally implemented by Proxy, which checks the upstream is
+// reachable within timeout.
+type Prober interface {
+	Probe(timeout time.Duration) error
+}
+
 type directProxy struct{}
 
 func (directProxy) HttpErr(c *Context, code int, info string) {
@@ -34,21 +53,110 @@ func (directProxy) HttpErr(c *Context, code int, info string) {
 
 type ExistProxy struct {
 	name      string
-	host      string
+	url       *url.URL
+	dial      DialFunc
+	tlsConfig *tls.Config // of https
+	auth      string      // Proxy-Authorization of http and https
 	transport http.RoundTripper
 	keepAlive bool
 }
 
-// TODO add https socks5 support
-func NewExistProxy(name, host string, keepAlive bool) (*ExistProxy, error) {
-	proxyURL, err := url.Parse("http://" + host)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy address %q: %v", host, err)
+// NewExistProxy creates proxy to upstream u which accepts schemes:
+// http, https, socks5 and socks5h, with optional user:password.
+// socks5 resolves target locally, socks5h resolves by upstream. Port can be
+// omitted, which is 80, 443 or 1080 by scheme.
+func NewExistProxy(name string, u *url.URL, keepAlive bool) (*ExistProxy, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q: empty host", u)
+	}
+	if u.Port() == "" {
+		port, ok := defaultProxyPorts[u.Scheme]
+		if !ok {
+			return nil, fmt.Errorf("invalid proxy url %q: unsupported scheme", u)
+		}
+		withPort := *u
+		withPort.Host = net.JoinHostPort(u.Hostname(), port)
+		u = &withPort
+	}
+
+	tp := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	p := &ExistProxy{
+		name:      name,
+		url:       u,
+		transport: tp,
+		keepAlive: keepAlive,
+	}
+
+	switch u.Scheme {
+	case "http":
+		p.dial = net.Dial
+	case "https":
+		p.tlsConfig = &tls.Config{ServerName: u.Hostname()}
+		p.dial = func(network, address string) (net.Conn, error) {
+			return tls.Dial(network, address, p.tlsConfig)
+		}
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", u.Host, auth, proxy.Direct)
+		if err != nil {
+			return nil, err
+		}
+		p.dial = dialer.Dial
+		if u.Scheme == "socks5" {
+			p.dial = resolveThenDial(p.dial)
+		}
+		tp.DialContext = nil
+		tp.Dial = p.dial
+		return p, nil
+	default:
+		return nil, fmt.Errorf("invalid proxy url %q: unsupported scheme", u)
+	}
+
+	// http and https
+	tp.Proxy = http.ProxyURL(u)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		p.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password))
 	}
+	return p, nil
+}
 
-	tp := *(http.DefaultTransport.(*http.Transport))
-	tp.Proxy = http.ProxyURL(proxyURL)
-	return &ExistProxy{name, host, &tp, keepAlive}, nil
+var defaultProxyPorts = map[string]string{
+	"http":    "80",
+	"https":   "443",
+	"socks5":  "1080",
+	"socks5h": "1080",
+}
+
+// resolveThenDial resolves host of address before dial.
+func resolveThenDial(dial DialFunc) DialFunc {
+	return func(network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			ips, err := net.LookupIP(host)
+			if err != nil {
+				return nil, err
+			}
+			address = net.JoinHostPort(ips[0].String(), port)
+		}
+		return dial(network, address)
+	}
 }
 
 func (p *ExistProxy) HttpErr(c *Context, code int, info string) {
@@ -62,8 +170,33 @@ func (p *ExistProxy) HttpErr(c *Context, code int, info string) {
 	c.HttpErr(he)
 }
 
+func (directProxy) Name() string   { return "DIRECT" }
+func (p *ExistProxy) Name() string { return p.name }
+
 func (p directProxy) Do(c *Context) error { return c.Direct() }
-func (p *ExistProxy) Do(c *Context) error { return c.ProxyUp(p.host, p.transport, p.keepAlive) }
+func (p *ExistProxy) Do(c *Context) error {
+	switch p.url.Scheme {
+	case "socks5", "socks5h":
+		return c.DirectDial(p.transport, p.dial)
+	}
+	return c.ProxyUp(p.dial, p.url.Host, p.auth, p.transport, p.keepAlive)
+}
+
+// Probe dials the upstream proxy, TLS is handshaked for https.
+func (p *ExistProxy) Probe(timeout time.Duration) error {
+	var conn net.Conn
+	var err error
+	if p.url.Scheme == "https" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", p.url.Host, p.tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", p.url.Host, timeout)
+	}
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
 
 var DirectProxy Proxy = directProxy{}
 var _ Proxy = new(ExistProxy)
+var _ Prober = new(ExistProxy)
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bufio"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/sniff"
+)
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// RouteHost returns SNI if sniffed, or HostNoPort. Routers matching domain
+// should use it. The first call sniffs SNI if enabled, so that tunnels routed
+// without it are not replied before dial.
+func (c *Context) RouteHost() string {
+	if !c.sniffed {
+		c.sniffed = true
+		c.sniffSNI()
+	}
+	if c.SNI != "" {
+		return c.SNI
+	}
+	return c.HostNoPort
+}
+
+// sniffSNI replies CONNECT before dial, then peeks the ClientHello in the
+// tunnel within SNITimeout. The peeked bytes are replayed by bodyReader.
+func (c *Context) sniffSNI() {
+	if c.SNITimeout == 0 || !c.Connect || c.isUpgrade() || c.replied {
+		return
+	}
+	conn, ok := c.Writer.(readDeadliner)
+	if !ok {
+		// peers, sniffed by the first node
+		return
+	}
+
+	c.writeConncectOK()
+	// status is set by the proxy
+	c.Access.Status = 0
+	c.replied = true
+
+	src := c.bodyReader()
+	br := bufio.NewReaderSize(src, sniff.BufferSize)
+	conn.SetReadDeadline(time.Now().Add(c.SNITimeout))
+	c.SNI, _ = sniff.ServerName(br)
+	conn.SetReadDeadline(time.Time{})
+
+	if src == c.UnsafeReader {
+		c.UnsafeReader = bufferedConn{br, src}
+	} else {
+		c.Request.Body = bufferedConn{br, src}
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type failProxy struct{}
+
+func (failProxy) Do(c *Context) error                       { return errors.New("upstream down") }
+func (failProxy) HttpErr(c *Context, code int, info string) { DirectProxy.HttpErr(c, code, info) }
+
+func TestSNISniffedByRouteHost(t *testing.T) {
+	target := echoServer(t)
+	defer target.Close()
+
+	routeHosts := make(chan string, 1)
+	co := newTestCore(&routeFunc{func(c *Context) Proxy {
+		routeHosts <- c.RouteHost()
+		return DirectProxy
+	}})
+	co.ContextConfig.SNITimeout = time.Second
+	ln := serveCore(t, co)
+	defer ln.Close()
+
+	conn, _ := connectThrough(t, ln.Addr().String(), target.Addr().String())
+	defer conn.Close()
+	// the echoed ClientHello fails the handshake
+	go tls.Client(conn, &tls.Config{ServerName: "www.example.com"}).Handshake()
+
+	select {
+	case host := <-routeHosts:
+		if host != "www.example.com" {
+			t.Errorf("RouteHost should get SNI www.example.com, but got: %s", host)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("tunnel should be routed")
+	}
+}
+
+func TestSNINotSniffedWithoutRouteHost(t *testing.T) {
+	co := newTestCore(&testRouter{p: failProxy{}})
+	co.ContextConfig.SNITimeout = time.Second
+	ln := serveCore(t, co)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "CONNECT 127.0.0.1:443 HTTP/1.1\r\nHost: 127.0.0.1:443\r\n\r\n")
+	res, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err != nil {
+		t.Fatalf("CONNECT should get response, but got: %v", err)
+	}
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("CONNECT not sniffed should get 502 of failed upstream, but got: %d", res.StatusCode)
+	}
+}
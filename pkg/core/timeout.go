@@ -0,0 +1,229 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type TimeoutError struct {
+	// Reason is one of dial, idle, lifetime and ping.
+	Reason string
+	After  time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout after %v", e.Reason, e.After)
+}
+
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+// dial dials with DialTimeout, any DialFunc is supported.
+func (c *Context) dial(dial DialFunc, address string) (net.Conn, error) {
+	if c.DialTimeout == 0 {
+		return dial("tcp", address)
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := dial("tcp", address)
+		done <- result{conn, err}
+	}()
+
+	timer := time.NewTimer(c.DialTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-timer.C:
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, &TimeoutError{Reason: "dial", After: c.DialTimeout}
+	}
+}
+
+// guard closes all copying ends when no bytes copied in IdleTimeout, or
+// MaxLifetime reached. All Copy of one Context share a guard, so that one
+// way streaming is not idle.
+type guard struct {
+	mu      sync.Mutex
+	closers []io.Closer
+	idle    *time.Timer
+	life    *time.Timer
+	timeout time.Duration
+	err     *TimeoutError
+}
+
+func (c *Context) startGuard() *guard {
+	if c.IdleTimeout == 0 && c.MaxLifetime == 0 {
+		return nil
+	}
+
+	c.guardOnce.Do(func() {
+		g := &guard{timeout: c.IdleTimeout}
+		if c.IdleTimeout != 0 {
+			g.idle = time.AfterFunc(c.IdleTimeout, func() {
+				g.abort(&TimeoutError{Reason: "idle", After: c.IdleTimeout})
+			})
+		}
+		if c.MaxLifetime != 0 {
+			g.life = time.AfterFunc(c.MaxLifetime-time.Since(c.Access.Start), func() {
+				g.abort(&TimeoutError{Reason: "lifetime", After: c.MaxLifetime})
+			})
+		}
+		c.guard = g
+	})
+	return c.guard
+}
+
+// Finish stops timers of c. Contexts not served by Core.Proxy must call it
+// when done.
+func (c *Context) Finish() { c.stopGuard() }
+
+// stopGuard must be called when Context finished.
+func (c *Context) stopGuard() {
+	if g := c.guard; g != nil {
+		g.mu.Lock()
+		if g.idle != nil {
+			g.idle.Stop()
+		}
+		if g.life != nil {
+			g.life.Stop()
+		}
+		g.mu.Unlock()
+	}
+}
+
+func (g *guard) watch(end interface{}) {
+	closer, ok := end.(io.Closer)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		closer.Close()
+	}
+	g.closers = append(g.closers, closer)
+}
+
+func (g *guard) touch() {
+	if g.idle != nil {
+		g.idle.Reset(g.timeout)
+	}
+}
+
+func (g *guard) abort(err *TimeoutError) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return
+	}
+	g.err = err
+	for _, closer := range g.closers {
+		closer.Close()
+	}
+}
+
+// guardErr replaces err with TimeoutError if g aborted.
+func guardErr(g *guard, err error) error {
+	if g == nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return g.err
+	}
+	return err
+}
+
+type guardReader struct {
+	io.Reader
+	g *guard
+}
+
+func (r guardReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 {
+		r.g.touch()
+	}
+	return n, err
+}
+
+type guardWriter struct {
+	io.Writer
+	g *guard
+}
+
+func (w guardWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	if n > 0 {
+		w.g.touch()
+	}
+	return n, err
+}
+
+// guardFlushWriter keeps dst of CopyBufferFlush flushable.
+type guardFlushWriter struct {
+	guardWriter
+	http.Flusher
+}
+
+func (c *Context) guardEnds(dst io.Writer, src io.Reader) (io.Writer, io.Reader, *guard) {
+	g := c.startGuard()
+	if g == nil {
+		return dst, src, nil
+	}
+	// Client ends are kept open for HttpErr, they will be closed after
+	// remote closed. Reading client is stopped, or copying to remote is
+	// blocked until the client sends.
+	for _, end := range []interface{}{dst, src} {
+		if end != io.Writer(c.Writer) && !c.isClientReader(end) {
+			g.watch(end)
+		}
+	}
+	if c.isClientReader(src) {
+		g.watch(c.clientReadStopper())
+	}
+
+	w := guardWriter{dst, g}
+	if flusher, ok := dst.(http.Flusher); ok {
+		return guardFlushWriter{w, flusher}, guardReader{src, g}, g
+	}
+	return w, guardReader{src, g}, g
+}
+
+func (c *Context) isClientReader(end interface{}) bool {
+	return end == io.Reader(c.UnsafeReader) || end == io.Reader(c.nopCloser) ||
+		c.Request != nil && end == io.Reader(c.Request.Body)
+}
+
+// readStopper fails pending and later reads of conn, writes still work.
+type readStopper struct {
+	conn readDeadliner
+}
+
+func (s readStopper) Close() error { return s.conn.SetReadDeadline(time.Now()) }
+
+// clientReadStopper returns the closer to stop reading client, or nil.
+func (c *Context) clientReadStopper() io.Closer {
+	if conn, ok := c.Writer.(readDeadliner); ok {
+		return readStopper{conn}
+	}
+	if body := c.Request.Body; body != nil && body != http.NoBody {
+		return body
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+)
+
+func TestDialTimeout(t *testing.T) {
+	late := make(chan net.Conn, 1)
+	dial := func(network, address string) (net.Conn, error) {
+		time.Sleep(200 * time.Millisecond)
+		conn, _ := net.Pipe()
+		late <- conn
+		return conn, nil
+	}
+
+	c := &Context{ContextConfig: ContextConfig{DialTimeout: 20 * time.Millisecond}}
+	start := time.Now()
+	_, err := c.dial(dial, "example.com:80")
+	if te, ok := err.(*TimeoutError); !ok || te.Reason != "dial" {
+		t.Fatalf("dial should get dial TimeoutError, but got: %v", err)
+	}
+	if d := time.Since(start); d > 150*time.Millisecond {
+		t.Errorf("dial should time out after 20ms, but got: %v", d)
+	}
+
+	conn := <-late
+	time.Sleep(10 * time.Millisecond)
+	if _, err := conn.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Errorf("late conn should be closed, but got: %v", err)
+	}
+}
+
+func TestIdleTimeoutStopsClientReader(t *testing.T) {
+	client, proxySide := net.Pipe()
+	defer client.Close()
+	remote, remoteSide := net.Pipe()
+	defer remoteSide.Close()
+
+	c := &Context{
+		ContextConfig: ContextConfig{
+			BufferPool:  bufpool.Default,
+			IdleTimeout: 50 * time.Millisecond,
+		},
+		Writer:       proxySide,
+		UnsafeReader: proxySide,
+		Request:      &http.Request{Method: "CONNECT", Body: http.NoBody},
+		Connect:      true,
+		Access:       Access{Start: time.Now()},
+	}
+	defer c.stopGuard()
+
+	done := make(chan struct{})
+	go func() {
+		// the client sends nothing
+		c.copyToRemote(remote)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("copyToRemote should stop reading the idle client")
+	}
+
+	if _, err := remoteSide.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("remote should be closed, but got: %v", err)
+	}
+	go io.Copy(ioutil.Discard, client)
+	if _, err := proxySide.Write([]byte("x")); err != nil {
+		t.Errorf("client should be kept writable for HttpErr, but got: %v", err)
+	}
+}
+
+func TestTunnelTimeouts(t *testing.T) {
+	target := echoServer(t)
+	defer target.Close()
+
+	for _, tc := range []struct {
+		name     string
+		idle     time.Duration
+		lifetime time.Duration
+		interval time.Duration // client writes, zero keeps idle
+		min      time.Duration
+	}{
+		{name: "idle", idle: 100 * time.Millisecond, min: 100 * time.Millisecond},
+		{name: "active", idle: 100 * time.Millisecond, lifetime: 400 * time.Millisecond,
+			interval: 20 * time.Millisecond, min: 400 * time.Millisecond},
+	} {
+		co := newTestCore(&testRouter{p: DirectProxy})
+		co.ContextConfig.IdleTimeout = tc.idle
+		co.ContextConfig.MaxLifetime = tc.lifetime
+		finished := make(chan struct{})
+		co.OnAccess = func(c *Context) { close(finished) }
+		ln := serveCore(t, co)
+
+		start := time.Now()
+		conn, br := connectThrough(t, ln.Addr().String(), target.Addr().String())
+		closed := make(chan time.Duration, 1)
+		go func() {
+			io.Copy(ioutil.Discard, br)
+			closed <- time.Since(start)
+		}()
+		if tc.interval != 0 {
+			go func() {
+				for {
+					time.Sleep(tc.interval)
+					if _, err := conn.Write([]byte("x")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+
+		select {
+		case d := <-closed:
+			if d < tc.min {
+				t.Errorf("%s tunnel should live at least %v, but got: %v", tc.name, tc.min, d)
+			}
+		case <-time.After(3 * time.Second):
+			t.Errorf("%s tunnel should be closed", tc.name)
+		}
+		select {
+		case <-finished:
+		case <-time.After(time.Second):
+			t.Errorf("%s Proxy should return", tc.name)
+		}
+		conn.Close()
+		ln.Close()
+	}
+}
+
+func TestFinishStopsGuard(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	c, err := NewContextFromHandler(&ContextConfig{
+		IdleTimeout: time.Hour,
+		MaxLifetime: time.Hour,
+	}, discardResponseWriter{}, req)
+	if err != nil {
+		t.Fatalf("NewContextFromHandler should get no err, but got: %v", err)
+	}
+	g := c.startGuard()
+	c.Finish()
+	if g.idle.Stop() || g.life.Stop() {
+		t.Errorf("Finish should stop timers of the guard")
+	}
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TraceHeader is appended by every node a request passes, and by every node
+// a response or HttpErr returns through. Each value is one hop:
+//
+//	Hybrid-Trace: QmA; router=adp; proxy=b; t=3ms
+//	Hybrid-Trace: QmB; proxy=DIRECT; t=20ms
+//
+// t is the time from request read to forwarded, or to response written.
+const TraceHeader = "Hybrid-Trace"
+
+// traceRequest adds the trace of this node to the request, which is forwarded
+// to the next node. Traces are stripped if final unless KeepTrace, so that
+// target servers never see them.
+func (c *Context) traceRequest(final bool) {
+	h := c.Request.Header
+	if h == nil {
+		return
+	}
+	if final && !c.KeepTrace {
+		h.Del(TraceHeader)
+		return
+	}
+	if c.TraceName != "" {
+		h.Add(TraceHeader, c.traceValue())
+	}
+}
+
+// traceResponse adds the trace of this node to h of response or HttpErr.
+func (c *Context) traceResponse(h http.Header) {
+	if c.TraceName != "" {
+		h.Add(TraceHeader, c.traceValue())
+	}
+}
+
+func (c *Context) traceValue() string {
+	var b strings.Builder
+	b.WriteString(c.TraceName)
+	if c.Access.Router != "" {
+		b.WriteString("; router=")
+		b.WriteString(c.Access.Router)
+	}
+	if c.Access.Proxy != "" {
+		b.WriteString("; proxy=")
+		b.WriteString(c.Access.Proxy)
+	}
+	b.WriteString("; t=")
+	b.WriteString(strconv.FormatInt(int64(c.Access.Latency()/time.Millisecond), 10))
+	b.WriteString("ms")
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type namedProxy struct {
+	Proxy
+	name string
+}
+
+func (p namedProxy) Name() string { return p.name }
+
+// TestTraceHops requests through node A to node B, which dials the target.
+func TestTraceHops(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		traces := make(chan []string, 1)
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traces <- r.Header[TraceHeader]
+		}))
+		_, port, _ := net.SplitHostPort(strings.TrimPrefix(target.URL, "http://"))
+
+		coreB := newTestCore(&testRouter{name: "r", p: DirectProxy})
+		coreB.ContextConfig.TraceName = "B"
+		coreB.ContextConfig.KeepTrace = keep
+		lnB := serveCore(t, coreB)
+
+		toB := &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: lnB.Addr().String()})}
+		coreA := newTestCore()
+		coreA.ContextConfig.TraceName = "A"
+		coreA.Proxies = map[string]Proxy{"b": namedProxy{pipeProxy{toB}, "b"}}
+		lnA := serveCore(t, coreA)
+
+		conn, err := net.Dial("tcp", lnA.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial should get no err, but got: %v", err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(conn, "GET http://127.0.0.1.over.b.hybrid:%s/ HTTP/1.1\r\nHost: 127.0.0.1.over.b.hybrid:%s\r\n\r\n", port, port)
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("request should get response, but got: %v", err)
+		}
+		res.Body.Close()
+		conn.Close()
+
+		got := <-traces
+		if !keep && len(got) != 0 {
+			t.Errorf("traces should be stripped at the final node, but got: %q", got)
+		}
+		if keep && (len(got) != 2 || !strings.HasPrefix(got[0], "A; proxy=b; t=") ||
+			!strings.HasPrefix(got[1], "B; router=r; proxy=DIRECT; t=")) {
+			t.Errorf("traces of A and B should be kept to target, but got: %q", got)
+		}
+
+		got = res.Header[TraceHeader]
+		if len(got) != 2 || !strings.HasPrefix(got[0], "B; router=r; proxy=DIRECT; t=") ||
+			!strings.HasPrefix(got[1], "A; proxy=b; t=") {
+			t.Errorf("response should be traced by B then A, but got: %q", got)
+		}
+
+		lnA.Close()
+		lnB.Close()
+		target.Close()
+	}
+}
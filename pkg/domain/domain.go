@@ -180,3 +180,56 @@ func NewDomain(hostname string) (*Domain, error) {
 	}
 	return &d, nil
 }
+
+// Hops returns hybrid names of the route in hostname, and the index of the
+// current node which is marked by '-', -1 if hostname is not passed to any
+// node yet. It returns nil if hostname is not a hybrid domain.
+func Hops(hostname string) (hops []string, current int) {
+	current = -1
+	if !strings.HasSuffix(hostname, HybridSuffix) {
+		return nil, current
+	}
+	routeStart := strings.LastIndex(hostname, "."+KeywordWith+".")
+	if routeStart == -1 {
+		routeStart = strings.LastIndex(hostname, "."+KeywordOver+".")
+		if routeStart == -1 {
+			return nil, current
+		}
+	}
+	// both keywords are 4 bytes
+	routeStart += len(KeywordOver) + 2
+	routeEnd := len(hostname) - len(HybridSuffix)
+	if routeStart > routeEnd {
+		return []string{}, current
+	}
+
+	hops = strings.Split(hostname[routeStart:routeEnd], ".")
+	for i, hop := range hops {
+		if strings.HasPrefix(hop, "-") {
+			hops[i] = hop[1:]
+			current = i
+		}
+	}
+	return hops, current
+}
+
+// MatchHosts reports whether host is one of hosts, case insensitive and
+// trailing dots ignored. Host starts with dot matches itself and all
+// subdomains, i.e. ".example.com" matches "a.example.com" and "example.com".
+func MatchHosts(hosts []string, host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	for _, h := range hosts {
+		h = strings.TrimSuffix(h, ".")
+		if strings.EqualFold(h, host) {
+			return true
+		}
+		if h != "" && h[0] == '.' && (hasSuffixFold(host, h) || strings.EqualFold(host, h[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -206,3 +207,44 @@ func TestNewDomainFail(t *testing.T) {
 		}
 	}
 }
+
+func TestHops(t *testing.T) {
+	tests := []struct {
+		hostname string
+		hops     []string
+		current  int
+	}{
+		{"192.168.22.22.over.a.b.c.hybrid", []string{"a", "b", "c"}, -1},
+		{"192.168.22.22.with.a.-b.c.hybrid", []string{"a", "b", "c"}, 1},
+		{"192.168.22.22.over.hybrid", []string{}, -1},
+		{"example.com", nil, -1},
+	}
+	for _, tt := range tests {
+		hops, current := Hops(tt.hostname)
+		if strings.Join(hops, ".") != strings.Join(tt.hops, ".") || (hops == nil) != (tt.hops == nil) || current != tt.current {
+			t.Errorf("Hops(%q) = %v, %d, want %v, %d", tt.hostname, hops, current, tt.hops, tt.current)
+		}
+	}
+}
+
+func TestMatchHosts(t *testing.T) {
+	hosts := []string{"a.com", ".b.com", "Ads.Example.COM.", ".Corp.Example"}
+	for host, want := range map[string]bool{
+		"a.com":             true,
+		"ads.example.com":   true,
+		"x.corp.example":    true,
+		"CORP.example.":     true,
+		"x.ads.example.com": false,
+		"x.a.com":           false,
+		"b.com":             true,
+		"x.b.com":           true,
+		"X.B.com.":          true,
+		"xb.com":            false,
+		"c.com":             false,
+		"192.168.1.1":       false,
+	} {
+		if got := MatchHosts(hosts, host); got != want {
+			t.Errorf("MatchHosts(%q) should get %v, but got: %v", host, want, got)
+		}
+	}
+}
@@ -103,6 +103,11 @@ func (hi *Ipfs) Proccess() goprocess.Process {
 	return hi.ipfsNode.Process()
 }
 
+// PeerID is the pretty ID of this node.
+func (hi *Ipfs) PeerID() string {
+	return hi.ipfsNode.Identity.Pretty()
+}
+
 func (hi *Ipfs) IsOnline() bool {
 	hi.mu.Lock()
 	defer hi.mu.Unlock()
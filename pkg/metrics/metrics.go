@@ -0,0 +1,271 @@
+// Package metrics implements counters and histograms with labels, exposed in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300}
+
+type collector interface {
+	writeTo(w *bufio.Writer)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry { return new(Registry) }
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		desc:   desc{name, help, labels},
+		values: make(map[string]*counter),
+	}
+	r.register(v)
+	return v
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	v := &HistogramVec{
+		desc:    desc{name, help, labels},
+		buckets: buckets,
+		values:  make(map[string]*histogram),
+	}
+	r.register(v)
+	return v
+}
+
+// NewFuncVec calls collect on every Write, which emits current values. typ is
+// counter or gauge.
+func (r *Registry) NewFuncVec(name, help, typ string, collect func(emit func(value float64, lvs ...string)), labels ...string) {
+	r.register(&funcVec{
+		desc:    desc{name, help, labels},
+		typ:     typ,
+		collect: collect,
+	})
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+func (r *Registry) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	for _, c := range r.collectors {
+		c.writeTo(bw)
+	}
+	r.mu.Unlock()
+	return bw.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, typ)
+}
+
+// key joins label values, it is also the sort key of output.
+func (d *desc) key(lvs []string) string {
+	if len(lvs) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, but got %d", d.name, len(d.labels), len(lvs)))
+	}
+	return strings.Join(lvs, "\xff")
+}
+
+// pairs formats labels, extra is appended as the last one if not empty.
+func (d *desc) pairs(lvs []string, extra ...string) string {
+	if len(lvs) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, l := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(lvs[i]))
+		b.WriteByte('"')
+	}
+	if len(extra) == 2 {
+		if len(lvs) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[0])
+		b.WriteString(`="`)
+		b.WriteString(extra[1])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+type counter struct {
+	lvs   []string
+	value float64
+}
+
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counter
+}
+
+func (v *CounterVec) Add(delta float64, lvs ...string) {
+	key := v.key(lvs)
+	v.mu.Lock()
+	c, ok := v.values[key]
+	if !ok {
+		c = &counter{lvs: append([]string(nil), lvs...)}
+		v.values[key] = c
+	}
+	c.value += delta
+	v.mu.Unlock()
+}
+
+func (v *CounterVec) Inc(lvs ...string) { v.Add(1, lvs...) }
+
+func (v *CounterVec) writeTo(w *bufio.Writer) {
+	v.writeHeader(w, "counter")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, key := range sortedKeys(v.values) {
+		c := v.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.pairs(c.lvs), formatFloat(c.value))
+	}
+}
+
+type histogram struct {
+	lvs    []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+func (v *HistogramVec) Observe(value float64, lvs ...string) {
+	key := v.key(lvs)
+	v.mu.Lock()
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{
+			lvs:    append([]string(nil), lvs...),
+			counts: make([]uint64, len(v.buckets)),
+		}
+		v.values[key] = h
+	}
+	i := sort.SearchFloat64s(v.buckets, value)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+	v.mu.Unlock()
+}
+
+func (v *HistogramVec) writeTo(w *bufio.Writer) {
+	v.writeHeader(w, "histogram")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, key := range sortedKeys(v.values) {
+		h := v.values[key]
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, v.pairs(h.lvs, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, v.pairs(h.lvs, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, v.pairs(h.lvs), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, v.pairs(h.lvs), h.count)
+	}
+}
+
+type funcVec struct {
+	desc
+	typ     string
+	collect func(emit func(value float64, lvs ...string))
+}
+
+func (v *funcVec) writeTo(w *bufio.Writer) {
+	values := make(map[string]*counter)
+	v.collect(func(value float64, lvs ...string) {
+		values[v.key(lvs)] = &counter{lvs: append([]string(nil), lvs...), value: value}
+	})
+
+	v.writeHeader(w, v.typ)
+	for _, key := range sortedKeys(values) {
+		c := values[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.pairs(c.lvs), formatFloat(c.value))
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*counter:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpReplacer.Replace(s) }
+func escapeLabel(s string) string { return labelReplacer.Replace(s) }
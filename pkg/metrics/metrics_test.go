@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Requests.", "proxy")
+	h := r.NewHistogramVec("duration_seconds", "Duration.", []float64{1, 5}, "proxy")
+	r.NewFuncVec("active", "Active.", "gauge", func(emit func(float64, ...string)) {
+		emit(2, "b")
+		emit(1, "a")
+	}, "proxy")
+
+	c.Inc("b")
+	c.Add(2, `a"`)
+	h.Observe(0.5, "a")
+	h.Observe(3, "a")
+	h.Observe(10, "a")
+
+	var buf bytes.Buffer
+	err := r.Write(&buf)
+	if err != nil {
+		t.Fatalf("Write should get no err, but got: %v", err)
+	}
+
+	expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{proxy="a\""} 2
+requests_total{proxy="b"} 1
+# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{proxy="a",le="1"} 1
+duration_seconds_bucket{proxy="a",le="5"} 2
+duration_seconds_bucket{proxy="a",le="+Inf"} 3
+duration_seconds_sum{proxy="a"} 13.5
+duration_seconds_count{proxy="a"} 3
+# HELP active Active.
+# TYPE active gauge
+active{proxy="a"} 1
+active{proxy="b"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("Write should get:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
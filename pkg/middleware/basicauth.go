@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// BasicAuth checks Proxy-Authorization of requests from local binds.
+// Socks5 requests are skipped, they are authenticated by socks5 itself.
+// Transparent requests are skipped, they cannot carry credentials, so are
+// requests intercepted from an authenticated tunnel.
+type BasicAuth struct {
+	Realm string
+	Users map[string]string
+}
+
+func (m *BasicAuth) Wrap(next core.HandlerFunc) core.HandlerFunc {
+	return func(c *core.Context) {
+		if !c.Local || c.Socks5 || c.Transparent || c.Intercepted {
+			next(c)
+			return
+		}
+
+		req := c.Request
+		username, password, ok := ProxyBasicAuth(req)
+		req.Header.Del("Proxy-Authorization")
+		if !ok || !m.verify(username, password) {
+			c.HttpErr(&core.HttpErr{
+				Code:       http.StatusProxyAuthRequired,
+				ClientType: "Middleware",
+				ClientName: "BasicAuth",
+				TargetHost: c.HostPort,
+				Header: http.Header{
+					"Proxy-Authenticate": []string{`Basic realm="` + m.Realm + `"`},
+				},
+			})
+			return
+		}
+		next(c)
+	}
+}
+
+func (m *BasicAuth) verify(username, password string) bool {
+	expected, ok := m.Users[username]
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+// ProxyBasicAuth is http.Request.BasicAuth with Proxy-Authorization.
+func ProxyBasicAuth(req *http.Request) (username, password string, ok bool) {
+	auth := req.Header.Get("Proxy-Authorization")
+	if auth == "" {
+		return
+	}
+	r := http.Request{Header: http.Header{"Authorization": []string{auth}}}
+	return r.BasicAuth()
+}
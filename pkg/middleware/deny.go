@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/empirefox/hybrid/pkg/core"
+	"github.com/empirefox/hybrid/pkg/domain"
+)
+
+// Deny rejects requests by the final dial host. Host starts with dot matches
+// itself and all subdomains, i.e. ".example.com" matches "a.example.com" and
+// "example.com".
+type Deny struct {
+	Hosts []string
+}
+
+func (m *Deny) Wrap(next core.HandlerFunc) core.HandlerFunc {
+	return func(c *core.Context) {
+		if m.denied(c.Domain.DialHostname) {
+			c.HttpErr(&core.HttpErr{
+				Code:       http.StatusForbidden,
+				ClientType: "Middleware",
+				ClientName: "Deny",
+				TargetHost: c.HostPort,
+			})
+			return
+		}
+		next(c)
+	}
+}
+
+func (m *Deny) denied(host string) bool { return domain.MatchHosts(m.Hosts, host) }
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"testing"
+)
+
+func TestDenyDenied(t *testing.T) {
+	// matching is tested by domain.MatchHosts
+	m := &Deny{Hosts: []string{".b.com"}}
+	if !m.denied("x.b.com") {
+		t.Errorf("x.b.com should be denied")
+	}
+	if m.denied("c.com") {
+		t.Errorf("c.com should not be denied")
+	}
+}
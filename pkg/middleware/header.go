@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// Header sets and removes request headers before routing.
+type Header struct {
+	Set    map[string]string
+	Remove []string
+}
+
+func (m *Header) Wrap(next core.HandlerFunc) core.HandlerFunc {
+	return func(c *core.Context) {
+		h := c.Request.Header
+		for _, k := range m.Remove {
+			h.Del(k)
+		}
+		for k, v := range m.Set {
+			h.Set(k, v)
+		}
+		next(c)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// ProxyAuth checks Proxy-Authorization with Basic or Bearer scheme of both
+// local binds and peers. Socks5 requests are skipped since verified by the
+// socks5 server, transparent ones carry no credentials, and intercepted ones
+// were verified by their CONNECT.
+type ProxyAuth struct {
+	Realm string
+
+	// Verify receives the raw Proxy-Authorization.
+	Verify func(authorization string) bool
+}
+
+func (m *ProxyAuth) Wrap(next core.HandlerFunc) core.HandlerFunc {
+	return func(c *core.Context) {
+		if c.Socks5 || c.Transparent || c.Intercepted {
+			next(c)
+			return
+		}
+
+		req := c.Request
+		authorization := req.Header.Get("Proxy-Authorization")
+		if authorization == "" || !m.Verify(authorization) {
+			c.HttpErr(&core.HttpErr{
+				Code:       http.StatusProxyAuthRequired,
+				ClientType: "Middleware",
+				ClientName: "ProxyAuth",
+				TargetHost: c.HostPort,
+				Header: http.Header{
+					"Proxy-Authenticate": []string{
+						`Basic realm="` + m.Realm + `"`,
+						`Bearer realm="` + m.Realm + `"`,
+					},
+				},
+			})
+			return
+		}
+
+		// next peer may verify it too
+		if !c.Domain.IsHybrid || c.Domain.IsEnd {
+			req.Header.Del("Proxy-Authorization")
+		}
+		next(c)
+	}
+}
@@ -0,0 +1,32 @@
+package mitm
+
+import (
+	"crypto/tls"
+
+	"github.com/empirefox/hybrid/pkg/domain"
+)
+
+// Interceptor terminates TLS of tunnels to Hosts. Host starts with dot
+// matches itself and all subdomains, i.e. ".example.com" matches
+// "a.example.com" and "example.com".
+type Interceptor struct {
+	CA    *CA
+	Hosts []string
+}
+
+// Match reports whether tunnels to host should be intercepted.
+func (m *Interceptor) Match(host string) bool { return domain.MatchHosts(m.Hosts, host) }
+
+// TLSConfig serves leaf of SNI, or host if client sends no SNI.
+func (m *Interceptor) TLSConfig(host string) *tls.Config {
+	return &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := hello.ServerName
+			if name == "" {
+				name = host
+			}
+			return m.CA.Leaf(name)
+		},
+		NextProtos: []string{"http/1.1"},
+	}
+}
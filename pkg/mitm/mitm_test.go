@@ -0,0 +1,45 @@
+package mitm
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mitm")
+	if err != nil {
+		t.Fatalf("TempDir should get no err, but got: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA should create CA, but got: %v", err)
+	}
+	loaded, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA should load CA, but got: %v", err)
+	}
+	if !bytes.Equal(ca.CertPEM(), loaded.CertPEM()) {
+		t.Errorf("LoadOrCreateCA should load the created CA")
+	}
+
+	leaf, err := loaded.Leaf("www.example.com")
+	if err != nil {
+		t.Fatalf("Leaf should get no err, but got: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	_, err = leaf.Leaf.Verify(x509.VerifyOptions{DNSName: "www.example.com", Roots: roots})
+	if err != nil {
+		t.Errorf("Leaf should be signed by CA, but got: %v", err)
+	}
+
+	cached, _ := loaded.Leaf("www.example.com")
+	if cached != leaf {
+		t.Errorf("Leaf should be cached")
+	}
+}
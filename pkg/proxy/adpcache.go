@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultAdpCacheSize = 10000
+	DefaultAdpCacheTTL  = 10 * time.Minute
+)
+
+// AdpCacheStats are counters of the decision cache of AdpRouter.
+type AdpCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Size   int // cached decisions
+}
+
+// adpCache is a LRU of blocked decisions by host, entries expire after ttl.
+type adpCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type adpCacheEntry struct {
+	key     string
+	blocked bool
+	expires time.Time
+}
+
+func newAdpCache(size int, ttl time.Duration) *adpCache {
+	return &adpCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *adpCache) get(key string) (blocked, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return false, false
+	}
+	e := el.Value.(*adpCacheEntry)
+	if time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return false, false
+	}
+	c.ll.MoveToFront(el)
+	return e.blocked, true
+}
+
+func (c *adpCache) put(key string, blocked bool) {
+	expires := time.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*adpCacheEntry)
+		e.blocked, e.expires = blocked, expires
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&adpCacheEntry{key, blocked, expires})
+	if c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*adpCacheEntry).key)
+	}
+}
+
+func (c *adpCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdpCache(t *testing.T) {
+	c := newAdpCache(2, time.Hour)
+	c.put("a", true)
+	c.put("b", false)
+	if blocked, ok := c.get("a"); !ok || !blocked {
+		t.Errorf("a should get blocked, but got: %v %v", blocked, ok)
+	}
+
+	// b is the least recently used
+	c.put("c", false)
+	if _, ok := c.get("b"); ok {
+		t.Errorf("b should be evicted")
+	}
+	if blocked, ok := c.get("c"); !ok || blocked {
+		t.Errorf("c should get unblocked, but got: %v %v", blocked, ok)
+	}
+	if n := c.len(); n != 2 {
+		t.Errorf("cache should be bounded to 2, but got: %d", n)
+	}
+
+	c.ttl = -time.Second
+	c.put("a", true)
+	if _, ok := c.get("a"); ok {
+		t.Errorf("a should be expired")
+	}
+	if n := c.len(); n != 1 {
+		t.Errorf("expired a should be removed, but got len: %d", n)
+	}
+}
@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pmezard/adblock/adblock"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// NewAdpRequest builds the adblock request of c. CONNECT tunnels are matched
+// by host only, since the path is encrypted, with https scheme if they are
+// TLS likely. Otherwise the full url is
+// matched, with the origin domain from Referer or Origin, and the content type
+// guessed from the extension or Accept.
+func NewAdpRequest(c *core.Context) *adblock.Request {
+	host := c.RouteHost()
+	rq := &adblock.Request{
+		URL:     adpHostURL(c),
+		Domain:  host,
+		Timeout: 5 * time.Second,
+	}
+	if c.Connect || c.Request == nil {
+		return rq
+	}
+
+	req := c.Request
+	u := *req.URL
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	rq.URL = u.String()
+	rq.OriginDomain = originDomain(req.Header.Get("Referer"))
+	if rq.OriginDomain == "" {
+		rq.OriginDomain = originDomain(req.Header.Get("Origin"))
+	}
+	rq.ContentType = guessContentType(u.Path, req.Header.Get("Accept"))
+	return rq
+}
+
+// adpHostURL is the url of c without path. CONNECT tunnels to 443 or with
+// SNI sniffed are https.
+func adpHostURL(c *core.Context) string {
+	host := c.RouteHost()
+	if c.Connect && (c.SNI != "" || c.Port == "443") {
+		return "https://" + host
+	}
+	return "http://" + host
+}
+
+func originDomain(raw string) string {
+	if raw == "" || raw == "null" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// guessContentType returns the media type of the extension of p, or the
+// first specific type in accept.
+func guessContentType(p, accept string) string {
+	if ext := path.Ext(p); ext != "" {
+		if t := mime.TypeByExtension(ext); t != "" {
+			if mt, _, err := mime.ParseMediaType(t); err == nil {
+				return mt
+			}
+		}
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && !strings.Contains(mt, "*") {
+			return mt
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func TestNewAdpRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://cdn.example.com/js/ads.js?v=1", nil)
+	req.Header.Set("Referer", "https://www.Example.org/page")
+	rq := NewAdpRequest(&core.Context{Request: req, HostNoPort: "cdn.example.com"})
+	if rq.URL != "http://cdn.example.com/js/ads.js?v=1" {
+		t.Errorf("URL should be full, but got: %s", rq.URL)
+	}
+	if rq.Domain != "cdn.example.com" {
+		t.Errorf("Domain should be cdn.example.com, but got: %s", rq.Domain)
+	}
+	if rq.OriginDomain != "www.example.org" {
+		t.Errorf("OriginDomain should be from Referer, but got: %s", rq.OriginDomain)
+	}
+	if rq.ContentType != "application/javascript" && rq.ContentType != "text/javascript" {
+		t.Errorf("ContentType should be guessed from .js, but got: %s", rq.ContentType)
+	}
+
+	req, _ = http.NewRequest("GET", "http://img.example.com/pixel", nil)
+	req.Header.Set("Origin", "http://app.example.net")
+	req.Header.Set("Accept", "image/webp,*/*;q=0.8")
+	rq = NewAdpRequest(&core.Context{Request: req, HostNoPort: "img.example.com"})
+	if rq.OriginDomain != "app.example.net" {
+		t.Errorf("OriginDomain should be from Origin, but got: %s", rq.OriginDomain)
+	}
+	if rq.ContentType != "image/webp" {
+		t.Errorf("ContentType should be guessed from Accept, but got: %s", rq.ContentType)
+	}
+
+	req, _ = http.NewRequest("CONNECT", "", nil)
+	req.Header.Set("Referer", "https://www.example.org/")
+	rq = NewAdpRequest(&core.Context{Request: req, Connect: true, HostNoPort: "ads.example.com", SNI: "tracker.example.com"})
+	if rq.URL != "https://tracker.example.com" || rq.OriginDomain != "" || rq.ContentType != "" {
+		t.Errorf("CONNECT should match the host only, but got: %+v", rq)
+	}
+
+	for _, tt := range []struct {
+		port string
+		want string
+	}{
+		{"443", "https://example.com"},
+		{"80", "http://example.com"},
+		{"8080", "http://example.com"},
+	} {
+		rq = NewAdpRequest(&core.Context{Request: req, Connect: true, HostNoPort: "example.com", Port: tt.port})
+		if rq.URL != tt.want {
+			t.Errorf("CONNECT to %s should match %s, but got: %s", tt.port, tt.want, rq.URL)
+		}
+	}
+}
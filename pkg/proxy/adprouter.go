@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -22,35 +27,110 @@ var (
 
 type AdpRouterConfig struct {
 	Log       *zap.Logger
+	Name      string
 	Disabled  bool
 	Blocked   core.Proxy
 	Unblocked core.Proxy
 	B64Rules  [][]byte
 	TxtRules  [][]byte
 
+	// B64RuleDir and TxtRuleDir are read on every reload, after B64Rules and
+	// TxtRules. Files start with digit are loaded by name.
+	B64RuleDir string
+	TxtRuleDir string
+
+	// WatchInterval polls rule dirs and reloads if files changed, 0 disables.
+	WatchInterval time.Duration
+
+	// Subscriptions are fetched into rule dirs, which are created if not
+	// exist. Rules can be empty before the first fetch.
+	Subscriptions []RuleSubscription
+
+	// CacheSize bounds the LRU of decisions by host, negative disables it.
+	// Only decisions by host are cached: of CONNECT, or of all requests if
+	// all rules are like ||example.com^. Zero values mean defaults.
+	CacheSize int
+	CacheTTL  time.Duration
+
 	EtcHostsIPAsBlocked bool
 	Dev                 bool
 }
 
+// AdpReloadResult reports a reload of AdpRouter.
+type AdpReloadResult struct {
+	Rules  int
+	Errors []error // parse errors of rule files
+}
+
 type AdpRouter struct {
-	log          *zap.Logger
-	config       AdpRouterConfig
-	adpMatcher   *adblock.RuleMatcher
-	blockedIps   map[string]bool
-	blockedIpsMu sync.RWMutex
+	// 64-bit aligned for atomic
+	hits   uint64
+	misses uint64
+
+	log    *zap.Logger
+	config AdpRouterConfig
+
+	// matcher is *adpMatcher, swapped on reload
+	matcher  atomic.Value
+	reloadMu sync.Mutex
+	stamp    string // of rule dirs when loaded
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// adpMatcher is replaced with its caches.
+type adpMatcher struct {
+	log     *zap.Logger
+	matcher *adblock.RuleMatcher
+	cache   *adpCache // nil if disabled
+
+	// hostOnly is set if all rules match by host only, then decisions of
+	// any request can be cached by host.
+	hostOnly bool
+
+	// blockedIps are from /etc/hosts, read only after built.
+	blockedIps map[string]bool
+
+	// rules are sources of rules, indexed by rule id - 1.
+	rules []core.MatchedRule
 }
 
 func NewAdpRouter(config AdpRouterConfig) (*AdpRouter, error) {
+	if config.CacheSize == 0 {
+		config.CacheSize = DefaultAdpCacheSize
+	}
+	if config.CacheTTL <= 0 {
+		config.CacheTTL = DefaultAdpCacheTTL
+	}
 	r := &AdpRouter{
-		log:        config.Log,
-		config:     config,
-		adpMatcher: adblock.NewMatcher(),
-		blockedIps: make(map[string]bool),
+		log:    config.Log,
+		config: config,
+		closed: make(chan struct{}),
+	}
+	for i := range config.Subscriptions {
+		sub := &config.Subscriptions[i]
+		err := sub.validate(&config)
+		if err != nil {
+			return nil, fmt.Errorf("AdpRouter(%s) subscription %s: %v", config.Name, sub.URL, err)
+		}
+		err = os.MkdirAll(sub.dir(&config), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := r.Reload()
+	if err == ErrEmptyAdpRules && len(config.Subscriptions) != 0 {
+		r.matcher.Store(r.newMatcher())
+		err = nil
 	}
-	err := r.init()
 	if err != nil {
 		return nil, err
 	}
+	if config.Dev {
+		r.log.Info("AdpList rules loaded", zap.Int("total", result.Rules))
+	}
 	return r, nil
 }
 
@@ -62,47 +142,128 @@ func (r *AdpRouter) Route(c *core.Context) core.Proxy {
 }
 
 func (r *AdpRouter) blocked(c *core.Context) bool {
-	r.blockedIpsMu.RLock()
-	if r.blockedIps[c.HostNoPort] {
-		r.blockedIpsMu.RUnlock()
+	m := r.matcher.Load().(*adpMatcher)
+	host := c.RouteHost()
+	if m.blockedIps[host] {
 		return true
 	}
-	r.blockedIpsMu.RUnlock()
+	if m.cache == nil || !(m.hostOnly || c.Connect || c.Request == nil) {
+		return m.match(NewAdpRequest(c))
+	}
 
-	blocked := r.AdpMatch("http://" + c.HostNoPort)
-	if blocked {
-		r.blockedIpsMu.Lock()
-		r.blockedIps[c.HostNoPort] = true
-		r.blockedIpsMu.Unlock()
+	// scheme rules may decide CONNECT
+	key := host
+	if !m.hostOnly {
+		key = adpHostURL(c)
+	}
+	blocked, ok := m.cache.get(key)
+	if ok {
+		atomic.AddUint64(&r.hits, 1)
+		return blocked
 	}
+	atomic.AddUint64(&r.misses, 1)
+	blocked = m.match(NewAdpRequest(c))
+	m.cache.put(key, blocked)
 	return blocked
 }
 
-func (r *AdpRouter) init() error {
-	var added int
-	for _, b := range r.config.TxtRules {
-		n, err := LoadAbpRules(r.adpMatcher, b, false)
+// Explain implements core.Explainer, the decision cache is not used.
+func (r *AdpRouter) Explain(c *core.Context, late bool) (core.Proxy, core.MatchedRule) {
+	m := r.matcher.Load().(*adpMatcher)
+	host := c.RouteHost()
+	if m.blockedIps[host] {
+		return r.config.Blocked, core.MatchedRule{Text: host, File: "hosts"}
+	}
+
+	var rule core.MatchedRule
+	blocked, id := m.matchID(NewAdpRequest(c))
+	if id > 0 && id <= len(m.rules) {
+		rule = m.rules[id-1]
+		if !blocked && !strings.HasPrefix(rule.Text, "@@") {
+			rule.Text = "excepted: " + rule.Text
+		}
+	}
+	if blocked {
+		return r.config.Blocked, rule
+	}
+	return r.config.Unblocked, rule
+}
+
+// CacheStats returns counters of the decision cache.
+func (r *AdpRouter) CacheStats() AdpCacheStats {
+	stats := AdpCacheStats{
+		Hits:   atomic.LoadUint64(&r.hits),
+		Misses: atomic.LoadUint64(&r.misses),
+	}
+	if m, ok := r.matcher.Load().(*adpMatcher); ok && m.cache != nil {
+		stats.Size = m.cache.len()
+	}
+	return stats
+}
+
+func (r *AdpRouter) newMatcher() *adpMatcher {
+	m := &adpMatcher{
+		log:        r.log,
+		matcher:    adblock.NewMatcher(),
+		blockedIps: make(map[string]bool),
+		hostOnly:   true,
+	}
+	if r.config.CacheSize > 0 {
+		m.cache = newAdpCache(r.config.CacheSize, r.config.CacheTTL)
+	}
+	return m
+}
+
+// Reload builds a new matcher from rules, then swaps it in. The old one is
+// kept if no rule loaded.
+func (r *AdpRouter) Reload() (*AdpReloadResult, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	stamp, err := r.dirsStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	m := r.newMatcher()
+	result := new(AdpReloadResult)
+	load := func(name string, b []byte, b64 bool) {
+		n, err := m.load(name, b, b64)
 		if err != nil {
-			r.log.Error("LoadAbpRules", zap.Error(err))
+			r.log.Error("LoadAbpRules", zap.String("file", name), zap.Error(err))
+			result.Errors = append(result.Errors, fmt.Errorf("%s: %v", name, err))
 		}
-		added += n
+		result.Rules += n
+	}
+	for i, b := range r.config.TxtRules {
+		load(fmt.Sprintf("TxtRules[%d]", i), b, false)
 	}
-	for _, b := range r.config.B64Rules {
-		n, err := LoadAbpRules(r.adpMatcher, b, true)
+	for i, b := range r.config.B64Rules {
+		load(fmt.Sprintf("B64Rules[%d]", i), b, true)
+	}
+	for _, dir := range []struct {
+		path string
+		b64  bool
+	}{{r.config.TxtRuleDir, false}, {r.config.B64RuleDir, true}} {
+		if dir.path == "" {
+			continue
+		}
+		files, _, err := ReadRuleFiles(dir.path)
 		if err != nil {
-			r.log.Error("LoadAbpRules", zap.Error(err))
+			return nil, err
+		}
+		for _, f := range files {
+			load(filepath.Join(dir.path, f.Name), f.Content, dir.b64)
 		}
-		added += n
 	}
 
-	if added == 0 {
-		return ErrEmptyAdpRules
-	}
-	if r.config.Dev {
-		r.log.Info("AdpList rules loaded", zap.Int("total", added))
+	if result.Rules == 0 {
+		// not retried by watching until files change
+		r.stamp = stamp
+		return result, ErrEmptyAdpRules
 	}
 
-	// init blockedIps only after adpMatcher
+	// init blockedIps only after matcher
 	if r.config.EtcHostsIPAsBlocked {
 		hf, errs := hostess.LoadHostfile()
 		if errs != nil {
@@ -115,50 +276,156 @@ func (r *AdpRouter) init() error {
 		}
 
 		for _, hostname := range hf.Hosts {
-			if hostname.Enabled && hostname.IsValid() && r.AdpMatch("http://"+hostname.Domain) {
-				r.blockedIps[hostname.IP.String()] = true
+			if hostname.Enabled && hostname.IsValid() && m.matchURL("http://"+hostname.Domain) {
+				m.blockedIps[hostname.IP.String()] = true
 			}
 		}
 	}
 
+	r.matcher.Store(m)
+	r.stamp = stamp
+	return result, nil
+}
+
+func (r *AdpRouter) dirsStamp() (string, error) {
+	var stamp string
+	for _, dir := range []string{r.config.TxtRuleDir, r.config.B64RuleDir} {
+		if dir == "" {
+			continue
+		}
+		s, err := ruleDirStamp(dir)
+		if err != nil {
+			return "", err
+		}
+		stamp += dir + "\n" + s + "\n"
+	}
+	return stamp, nil
+}
+
+// Start fetches subscriptions with contexts of cc, and watches rule dirs if
+// WatchInterval is set, until Close.
+func (r *AdpRouter) Start(cc *core.ContextConfig) {
+	for _, sub := range r.config.Subscriptions {
+		go r.subscribe(cc, sub)
+	}
+	if r.config.WatchInterval <= 0 || (r.config.TxtRuleDir == "" && r.config.B64RuleDir == "") {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(r.config.WatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-r.closed:
+				return
+			}
+
+			stamp, err := r.dirsStamp()
+			if err != nil {
+				r.log.Error("AdpRouter watch", zap.String("router", r.config.Name), zap.Error(err))
+				continue
+			}
+			r.reloadMu.Lock()
+			changed := stamp != r.stamp
+			r.reloadMu.Unlock()
+			if !changed {
+				continue
+			}
+
+			result, err := r.Reload()
+			if err != nil {
+				r.log.Error("AdpRouter reload", zap.String("router", r.config.Name), zap.Error(err))
+				continue
+			}
+			r.log.Info("AdpRouter reloaded", zap.String("router", r.config.Name),
+				zap.Int("rules", result.Rules), zap.Int("errors", len(result.Errors)))
+		}
+	}()
+}
+
+func (r *AdpRouter) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
 	return nil
 }
 
 func (r *AdpRouter) Disabled() bool { return r.config.Disabled }
+func (r *AdpRouter) Name() string   { return r.config.Name }
 
+// AdpMatch matches url u only.
 func (r *AdpRouter) AdpMatch(u string) bool {
-	rq := &adblock.Request{
-		URL:          u,
-		Domain:       "",
-		OriginDomain: "",
-		ContentType:  "",
-		Timeout:      5 * time.Second,
-	}
-	matched, _, err := r.adpMatcher.Match(rq)
-	if err != nil {
-		r.log.Error("AdpMatch", zap.Error(err))
-		return false // No Block here
-	}
+	return r.matcher.Load().(*adpMatcher).matchURL(u)
+}
+
+func (m *adpMatcher) matchURL(u string) bool {
+	return m.match(&adblock.Request{
+		URL:     u,
+		Timeout: 5 * time.Second,
+	})
+}
 
+func (m *adpMatcher) match(rq *adblock.Request) bool {
+	matched, _ := m.matchID(rq)
 	return matched
 }
 
-// just copy
-func LoadAbpRules(m *adblock.RuleMatcher, b []byte, b64 bool) (int, error) {
-	var r io.Reader = bytes.NewReader(b)
-	if b64 {
-		r = base64.NewDecoder(base64.StdEncoding, r)
+// matchID returns the id of the matched rule, or the exception rule.
+func (m *adpMatcher) matchID(rq *adblock.Request) (bool, int) {
+	matched, id, err := m.matcher.Match(rq)
+	if err != nil {
+		m.log.Error("AdpMatch", zap.Error(err))
+		return false, 0 // No Block here
 	}
-	parsed, err := adblock.ParseRules(r)
+
+	return matched, id
+}
+
+// load adds rules from file with ids, added is the number of rules added.
+func (m *adpMatcher) load(file string, b []byte, b64 bool) (added int, err error) {
+	parsed, err := parseAbpRules(b, b64)
 	if err != nil {
 		return 0, err
 	}
-	added := 0
 	for _, rule := range parsed {
-		err := m.AddRule(rule, 0)
+		err := m.matcher.AddRule(rule, len(m.rules)+1)
 		if err == nil {
+			m.rules = append(m.rules, core.MatchedRule{Text: rule.Raw, File: file})
+			m.hostOnly = m.hostOnly && hostOnlyRule(rule.Raw)
 			added += 1
 		}
 	}
 	return added, nil
 }
+
+// hostOnlyRule reports whether rule matches by host only, like ||example.com^
+// without options.
+func hostOnlyRule(rule string) bool {
+	rule = strings.TrimPrefix(rule, "@@")
+	if !strings.HasPrefix(rule, "||") {
+		return false
+	}
+	rule = strings.TrimSuffix(rule[2:], "^")
+	if rule == "" {
+		return false
+	}
+	for i := 0; i < len(rule); i++ {
+		ch := rule[i]
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '.' || ch == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func parseAbpRules(b []byte, b64 bool) ([]*adblock.Rule, error) {
+	var r io.Reader = bytes.NewReader(b)
+	if b64 {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return adblock.ParseRules(r)
+}
+
+var (
+	_ core.Router    = new(AdpRouter)
+	_ core.Explainer = new(AdpRouter)
+)
@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func TestAdpRouterReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "adp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(content string) {
+		err := ioutil.WriteFile(filepath.Join(dir, "01.txt"), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("||ads.example.com^\n")
+
+	blocked, unblocked := testRouteProxy("blocked"), testRouteProxy("unblocked")
+	r, err := NewAdpRouter(AdpRouterConfig{
+		Log:           zap.NewNop(),
+		Blocked:       blocked,
+		Unblocked:     unblocked,
+		TxtRuleDir:    dir,
+		WatchInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewAdpRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	route := func(host string) core.Proxy {
+		req, _ := http.NewRequest("GET", "http://"+host+"/", nil)
+		return r.Route(&core.Context{Request: req, HostNoPort: host})
+	}
+	for i := 0; i < 2; i++ {
+		if got := route("ads.example.com"); got != blocked {
+			t.Errorf("ads.example.com should be blocked, but got: %v", got)
+		}
+	}
+	if stats := r.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("decision should be cached, but got: %+v", stats)
+	}
+
+	write("! comment\n||tracker.example.com^\n")
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload should be ok, but got: %v", err)
+	}
+	if result.Rules != 1 {
+		t.Errorf("Reload should get 1 rule, but got: %d", result.Rules)
+	}
+	if got := route("ads.example.com"); got != unblocked {
+		t.Errorf("blocked cache should be cleared after reload, but got: %v", got)
+	}
+	if got := route("tracker.example.com"); got != blocked {
+		t.Errorf("tracker.example.com should be blocked, but got: %v", got)
+	}
+
+	write("")
+	_, err = r.Reload()
+	if err != ErrEmptyAdpRules {
+		t.Errorf("Reload empty rules should get ErrEmptyAdpRules, but got: %v", err)
+	}
+	if got := route("tracker.example.com"); got != blocked {
+		t.Errorf("old rules should be kept if reload failed, but got: %v", got)
+	}
+	if stamp, _ := r.dirsStamp(); stamp != r.stamp {
+		t.Errorf("stamp of empty rules should be recorded, so that they are not reloaded until changed")
+	}
+
+	r.Start(new(core.ContextConfig))
+	// the mtime may not change within the resolution of file system
+	later := time.Now().Add(time.Second)
+	write("||watched.example.com^\n")
+	os.Chtimes(filepath.Join(dir, "01.txt"), later, later)
+	deadline := time.Now().Add(2 * time.Second)
+	for route("watched.example.com") != blocked {
+		if time.Now().After(deadline) {
+			t.Fatal("watched rules should be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAdpRouterCache(t *testing.T) {
+	blocked, unblocked := testRouteProxy("blocked"), testRouteProxy("unblocked")
+	newRouter := func(rules string) *AdpRouter {
+		r, err := NewAdpRouter(AdpRouterConfig{
+			Log:       zap.NewNop(),
+			Blocked:   blocked,
+			Unblocked: unblocked,
+			TxtRules:  [][]byte{[]byte(rules)},
+		})
+		if err != nil {
+			t.Fatalf("NewAdpRouter should be ok, but got: %v", err)
+		}
+		return r
+	}
+	route := func(r *AdpRouter, method, rawurl string) core.Proxy {
+		req, _ := http.NewRequest(method, rawurl, nil)
+		return r.Route(&core.Context{Request: req, HostNoPort: req.URL.Hostname(), Connect: method == "CONNECT"})
+	}
+
+	// decisions of host only rules are cached by host, whatever the query
+	r := newRouter("||ads.example.com^\n@@||ok.ads.example.com^\n")
+	for i := 0; i < 3; i++ {
+		if got := route(r, "GET", fmt.Sprintf("http://ads.example.com/p%d?q=%d", i, i)); got != blocked {
+			t.Errorf("ads.example.com should be blocked, but got: %v", got)
+		}
+	}
+	if stats := r.CacheStats(); stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("decisions should be cached by host, but got: %+v", stats)
+	}
+
+	// decisions of requests with path rules are not cached, but of CONNECT
+	r = newRouter("||example.com/ads/\n")
+	if got := route(r, "GET", "http://example.com/ads/x?q=1"); got != blocked {
+		t.Errorf("path should be blocked, but got: %v", got)
+	}
+	if got := route(r, "GET", "http://example.com/page?q=1"); got != unblocked {
+		t.Errorf("path should be unblocked, but got: %v", got)
+	}
+	if stats := r.CacheStats(); stats != (AdpCacheStats{}) {
+		t.Errorf("decisions by path should not be cached, but got: %+v", stats)
+	}
+	for i := 0; i < 2; i++ {
+		if got := route(r, "CONNECT", "http://example.com:443"); got != unblocked {
+			t.Errorf("CONNECT should be unblocked, but got: %v", got)
+		}
+	}
+	if stats := r.CacheStats(); stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("decisions of CONNECT should be cached, but got: %+v", stats)
+	}
+}
+
+func TestHostOnlyRule(t *testing.T) {
+	for _, tt := range []struct {
+		rule string
+		want bool
+	}{
+		{"||ads.example.com^", true},
+		{"||ads.example.com", true},
+		{"@@||Ads-1.example.com^", true},
+		{"||ads.example.com^$third-party", false},
+		{"||example.com/ads/", false},
+		{"||ads.*.com^", false},
+		{"|http://ads.example.com^", false},
+		{"ads", false},
+		{"||^", false},
+	} {
+		if got := hostOnlyRule(tt.rule); got != tt.want {
+			t.Errorf("hostOnlyRule(%q) should be %v, but got: %v", tt.rule, tt.want, got)
+		}
+	}
+}
+
+// BenchmarkAdpRouter routes urls with paths and queries of 100 hosts by a
+// large rule list, 1 of 10 hosts is blocked. Decisions are cached by host
+// with host only rules, but not with path rules.
+func BenchmarkAdpRouter(b *testing.B) {
+	var hostRules bytes.Buffer
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&hostRules, "||ad%d.example.com^\n", i)
+	}
+	pathRules := bytes.NewBuffer(append([]byte(nil), hostRules.Bytes()...))
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(pathRules, "||www%d.example.org/ads/\n", i)
+	}
+
+	contexts := make([]*core.Context, 1000)
+	for i := range contexts {
+		host := fmt.Sprintf("www%d.example.org", i%100)
+		if i%100 < 10 {
+			host = fmt.Sprintf("ad%d.example.com", i%100*7)
+		}
+		rawurl := fmt.Sprintf("http://%s/path/%d/page.html?utm_source=feed&id=%d", host, i%7, i)
+		req, _ := http.NewRequest("GET", rawurl, nil)
+		req.Header.Set("Referer", "http://www.example.net/")
+		contexts[i] = &core.Context{Request: req, HostNoPort: host}
+	}
+
+	for _, bc := range []struct {
+		name      string
+		rules     []byte
+		cacheSize int
+	}{
+		{"hosts/uncached", hostRules.Bytes(), -1},
+		{"hosts/cached", hostRules.Bytes(), DefaultAdpCacheSize},
+		{"paths/uncached", pathRules.Bytes(), -1},
+		{"paths/cached", pathRules.Bytes(), DefaultAdpCacheSize},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r, err := NewAdpRouter(AdpRouterConfig{
+				Log:       zap.NewNop(),
+				TxtRules:  [][]byte{bc.rules},
+				CacheSize: bc.cacheSize,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Route(contexts[i%len(contexts)])
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+const (
+	DomainRuleKeyword = "keyword:"
+	DomainRuleRegexp  = "regexp:"
+)
+
+// DomainRules routes hosts matched by Rules to Proxy. Rule syntax:
+//
+//	example.com          exact
+//	*.corp.example       subdomains of corp.example
+//	.corp.example        corp.example and its subdomains
+//	keyword:google       hosts contain google
+//	regexp:^ad[0-9]+\.   hosts matched by the regexp
+type DomainRules struct {
+	Proxy core.Proxy
+	File  string // where Rules from, for explaining
+	Rules []string
+}
+
+type DomainRouterConfig struct {
+	Name     string
+	Disabled bool
+
+	// Rules are matched by priority: exact, longest suffix, keyword, then
+	// regexp. The former one wins if rules are the same.
+	Rules []DomainRules
+
+	// Unmatched can be nil, then the next router routes.
+	Unmatched core.Proxy
+}
+
+type DomainRouter struct {
+	config   DomainRouterConfig
+	trie     *domainTrie
+	keywords []domainKeyword
+	regexps  []domainRegexp
+}
+
+// domainRule is a parsed rule with its source.
+type domainRule struct {
+	proxy core.Proxy
+	rule  core.MatchedRule
+}
+
+type domainKeyword struct {
+	keyword string
+	*domainRule
+}
+
+type domainRegexp struct {
+	re *regexp.Regexp
+	*domainRule
+}
+
+// domainTrie is keyed by labels from the top level.
+type domainTrie struct {
+	children map[string]*domainTrie
+	exact    *domainRule
+	suffix   *domainRule // matches subdomains
+}
+
+// ParseDomainRules reads rules line by line, empty lines and lines start with
+// # are skipped.
+func ParseDomainRules(content []byte) []string {
+	var rules []string
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		rules = append(rules, line)
+	}
+	return rules
+}
+
+func NewDomainRouter(config DomainRouterConfig) (*DomainRouter, error) {
+	r := &DomainRouter{
+		config: config,
+		trie:   new(domainTrie),
+	}
+	for _, set := range config.Rules {
+		for _, rule := range set.Rules {
+			err := r.add(rule, &domainRule{set.Proxy, core.MatchedRule{Text: rule, File: set.File}})
+			if err != nil {
+				return nil, fmt.Errorf("DomainRouter(%s) rule %q: %v", config.Name, rule, err)
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *DomainRouter) add(rule string, p *domainRule) error {
+	switch {
+	case strings.HasPrefix(rule, DomainRuleKeyword):
+		keyword := strings.ToLower(rule[len(DomainRuleKeyword):])
+		if keyword == "" {
+			return fmt.Errorf("empty keyword")
+		}
+		r.keywords = append(r.keywords, domainKeyword{keyword, p})
+	case strings.HasPrefix(rule, DomainRuleRegexp):
+		re, err := regexp.Compile(rule[len(DomainRuleRegexp):])
+		if err != nil {
+			return err
+		}
+		r.regexps = append(r.regexps, domainRegexp{re, p})
+	case strings.HasPrefix(rule, "*."):
+		r.trie.insert(rule[2:], p, false, true)
+	case strings.HasPrefix(rule, "."):
+		r.trie.insert(rule[1:], p, true, true)
+	default:
+		r.trie.insert(rule, p, true, false)
+	}
+	return nil
+}
+
+func (t *domainTrie) insert(domain string, p *domainRule, exact, suffix bool) {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(domain, ".")), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := t.children[labels[i]]
+		if !ok {
+			if t.children == nil {
+				t.children = make(map[string]*domainTrie)
+			}
+			child = new(domainTrie)
+			t.children[labels[i]] = child
+		}
+		t = child
+	}
+	if exact && t.exact == nil {
+		t.exact = p
+	}
+	if suffix && t.suffix == nil {
+		t.suffix = p
+	}
+}
+
+// match returns the exact, or the longest suffix matched rule.
+func (t *domainTrie) match(host string) *domainRule {
+	var matched *domainRule
+	end := len(host)
+	for end > 0 {
+		start := strings.LastIndexByte(host[:end], '.') + 1
+		child, ok := t.children[host[start:end]]
+		if !ok {
+			return matched
+		}
+		t = child
+		if start == 0 {
+			if t.exact != nil {
+				return t.exact
+			}
+			return matched
+		}
+		if t.suffix != nil {
+			matched = t.suffix
+		}
+		end = start - 1
+	}
+	return matched
+}
+
+func (r *DomainRouter) Disabled() bool { return r.config.Disabled }
+func (r *DomainRouter) Name() string   { return r.config.Name }
+
+func (r *DomainRouter) Route(c *core.Context) core.Proxy {
+	if rule := r.match(c); rule != nil {
+		return rule.proxy
+	}
+	return r.config.Unmatched
+}
+
+// Explain implements core.Explainer.
+func (r *DomainRouter) Explain(c *core.Context, late bool) (core.Proxy, core.MatchedRule) {
+	if rule := r.match(c); rule != nil {
+		return rule.proxy, rule.rule
+	}
+	return r.config.Unmatched, core.MatchedRule{}
+}
+
+func (r *DomainRouter) match(c *core.Context) *domainRule {
+	host := strings.ToLower(strings.TrimSuffix(c.RouteHost(), "."))
+	if rule := r.trie.match(host); rule != nil {
+		return rule
+	}
+	for _, k := range r.keywords {
+		if strings.Contains(host, k.keyword) {
+			return k.domainRule
+		}
+	}
+	for _, re := range r.regexps {
+		if re.re.MatchString(host) {
+			return re.domainRule
+		}
+	}
+	return nil
+}
+
+var (
+	_ core.Router    = new(DomainRouter)
+	_ core.Explainer = new(DomainRouter)
+)
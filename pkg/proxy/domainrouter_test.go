@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func domainContext(host string) *core.Context {
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	return &core.Context{Request: req, HostNoPort: host}
+}
+
+func TestDomainRouter(t *testing.T) {
+	exact, sub, dot := testRouteProxy("exact"), testRouteProxy("sub"), testRouteProxy("dot")
+	longest, keyword, re := testRouteProxy("longest"), testRouteProxy("keyword"), testRouteProxy("regexp")
+	unmatched := testRouteProxy("unmatched")
+
+	r, err := NewDomainRouter(DomainRouterConfig{
+		Name: "domain",
+		Rules: []DomainRules{
+			{Proxy: exact, File: "exact.txt", Rules: []string{"Example.COM", "a.dot.example", "exact.example."}},
+			{Proxy: sub, Rules: []string{"*.sub.example", "*.corp.example"}},
+			{Proxy: dot, Rules: []string{".dot.example", "*.dot.example", ".corp.example"}},
+			{Proxy: longest, Rules: []string{".in.corp.example", "ads.example.com"}},
+			{Proxy: keyword, Rules: []string{"keyword:Track", "keyword:sub"}},
+			{Proxy: re, Rules: []string{`regexp:^ad[0-9]+\.`, `regexp:track`}},
+		},
+		Unmatched: unmatched,
+	})
+	if err != nil {
+		t.Fatalf("NewDomainRouter should get no err, but got: %v", err)
+	}
+
+	for _, tt := range []struct {
+		host string
+		want core.Proxy
+	}{
+		{"example.com", exact},
+		{"EXAMPLE.com.", exact},
+		{"exact.example", exact},
+		{"www.example.com", unmatched},
+		{"ads.example.com", longest},
+		{"x.ads.example.com", unmatched},
+
+		// *. matches subdomains only
+		{"sub.example", keyword},
+		{"a.sub.example", sub},
+		{"a.b.sub.example", sub},
+
+		// . matches the domain and subdomains, the former rule wins
+		{"dot.example", dot},
+		{"b.dot.example", dot},
+		{"a.dot.example", exact},
+
+		// the longest suffix wins
+		{"corp.example", dot},
+		{"x.corp.example", sub},
+		{"in.corp.example", longest},
+		{"x.in.corp.example", longest},
+
+		// keywords before regexps
+		{"tracker.example", keyword},
+		{"ad1.example", re},
+		{"ad1.track.example", keyword},
+		{"adx.example", unmatched},
+	} {
+		if got := r.Route(domainContext(tt.host)); got != tt.want {
+			t.Errorf("%s should be routed to %v, but got: %v", tt.host, tt.want, got)
+		}
+	}
+
+	p, rule := r.Explain(domainContext("example.com"), false)
+	if p != exact || rule.Text != "Example.COM" || rule.File != "exact.txt" {
+		t.Errorf("Explain should get the matched rule, but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(domainContext("www.example.org"), false)
+	if p != unmatched || rule != (core.MatchedRule{}) {
+		t.Errorf("Explain of unmatched should get no rule, but got: %v %+v", p, rule)
+	}
+
+	r.config.Unmatched = nil
+	if got := r.Route(domainContext("www.example.org")); got != nil {
+		t.Errorf("unmatched should be routed by the next router, but got: %v", got)
+	}
+}
+
+func TestDomainRouterBadRule(t *testing.T) {
+	for _, rule := range []string{"keyword:", "regexp:("} {
+		_, err := NewDomainRouter(DomainRouterConfig{
+			Rules: []DomainRules{{Proxy: testRouteProxy("p"), Rules: []string{rule}}},
+		})
+		if err == nil {
+			t.Errorf("rule %q should be rejected", rule)
+		}
+	}
+}
+
+func TestParseDomainRules(t *testing.T) {
+	rules := ParseDomainRules([]byte("# comment\n\n  example.com  \n*.corp.example\r\n"))
+	if len(rules) != 2 || rules[0] != "example.com" || rules[1] != "*.corp.example" {
+		t.Errorf("ParseDomainRules should skip comments and empty lines, but got: %q", rules)
+	}
+}
+
+func BenchmarkDomainRouter(b *testing.B) {
+	rules := make([]string, 0, 10000)
+	for i := 0; len(rules) < 9990; i++ {
+		rules = append(rules,
+			fmt.Sprintf("site%d.example.com", i),
+			fmt.Sprintf("*.cdn%d.example.net", i),
+			fmt.Sprintf(".corp%d.example.org", i),
+		)
+	}
+	for i := 0; i < 5; i++ {
+		rules = append(rules, fmt.Sprintf("keyword:track%d", i), fmt.Sprintf(`regexp:^ad%d[0-9]+\.`, i))
+	}
+	r, err := NewDomainRouter(DomainRouterConfig{
+		Rules:     []DomainRules{{Proxy: testRouteProxy("matched"), Rules: rules}},
+		Unmatched: testRouteProxy("unmatched"),
+	})
+	if err != nil {
+		b.Fatalf("NewDomainRouter should get no err, but got: %v", err)
+	}
+
+	contexts := make([]*core.Context, 1000)
+	for i := range contexts {
+		var host string
+		switch i % 4 {
+		case 0:
+			host = fmt.Sprintf("site%d.example.com", i)
+		case 1:
+			host = fmt.Sprintf("img.cdn%d.example.net", i)
+		case 2:
+			host = fmt.Sprintf("a.b.corp%d.example.org", i)
+		default:
+			host = fmt.Sprintf("www%d.unmatched.example", i)
+		}
+		contexts[i] = domainContext(host)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Route(contexts[i%len(contexts)])
+	}
+}
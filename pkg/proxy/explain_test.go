@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func newExplainContext(host string) *core.Context {
+	req, _ := http.NewRequest("GET", "http://"+host+"/", nil)
+	return &core.Context{Request: req, HostNoPort: host}
+}
+
+// newResolvedResolver resolves hosts from the cache only, values are IPs or
+// errors.
+func newResolvedResolver(hosts map[string]interface{}) *HostResolver {
+	cache := make(map[string]*resolveEntry)
+	for host, v := range hosts {
+		e := &resolveEntry{done: make(chan struct{}), expires: time.Now().Add(time.Hour)}
+		close(e.done)
+		switch v := v.(type) {
+		case string:
+			e.ips = []net.IP{net.ParseIP(v)}
+		case error:
+			e.err = v
+		}
+		cache[host] = e
+	}
+	return &HostResolver{cache: cache}
+}
+
+func TestDomainRouterExplain(t *testing.T) {
+	corp, unmatched := testRouteProxy("corp"), testRouteProxy("unmatched")
+	r, err := NewDomainRouter(DomainRouterConfig{
+		Rules: []DomainRules{
+			{Proxy: corp, File: "domain/corp.txt", Rules: []string{".corp.example"}},
+			{Proxy: corp, Rules: []string{"keyword:intranet"}},
+		},
+		Unmatched: unmatched,
+	})
+	if err != nil {
+		t.Fatalf("NewDomainRouter should be ok, but got: %v", err)
+	}
+
+	p, rule := r.Explain(newExplainContext("git.corp.example"), false)
+	if p != corp || rule.Text != ".corp.example" || rule.File != "domain/corp.txt" {
+		t.Errorf("git.corp.example should get corp by .corp.example, but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(newExplainContext("my-intranet.example.com"), false)
+	if p != corp || rule.Text != "keyword:intranet" || rule.File != "" {
+		t.Errorf("my-intranet.example.com should get corp by keyword, but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(newExplainContext("example.com"), false)
+	if p != unmatched || rule.Text != "" {
+		t.Errorf("example.com should get unmatched without rule, but got: %v %+v", p, rule)
+	}
+}
+
+func TestAdpRouterExplain(t *testing.T) {
+	blocked, unblocked := testRouteProxy("blocked"), testRouteProxy("unblocked")
+	r, err := NewAdpRouter(AdpRouterConfig{
+		Log:       zap.NewNop(),
+		Blocked:   blocked,
+		Unblocked: unblocked,
+		TxtRules: [][]byte{
+			[]byte("||ads.example.com^\n"),
+			[]byte("@@||good.ads.example.com^\n"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAdpRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	p, rule := r.Explain(newExplainContext("ads.example.com"), false)
+	if p != blocked || rule.Text != "||ads.example.com^" || rule.File != "TxtRules[0]" {
+		t.Errorf("ads.example.com should be blocked by TxtRules[0], but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(newExplainContext("good.ads.example.com"), false)
+	if p != unblocked || rule.Text != "@@||good.ads.example.com^" || rule.File != "TxtRules[1]" {
+		t.Errorf("good.ads.example.com should be excepted by TxtRules[1], but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(newExplainContext("example.com"), false)
+	if p != unblocked || rule.Text != "" {
+		t.Errorf("example.com should get unblocked without rule, but got: %v %+v", p, rule)
+	}
+	if stats := r.CacheStats(); stats.Size != 0 {
+		t.Errorf("Explain should not fill the cache, but got: %+v", stats)
+	}
+}
+
+func TestIPNetRouterExplain(t *testing.T) {
+	matched, unmatched := testRouteProxy("lan"), testRouteProxy("wan")
+	_, lan, _ := net.ParseCIDR("192.168.0.0/16")
+	r := &IPNetRouter{
+		IPs:  []net.IP{net.ParseIP("10.0.0.1")},
+		Nets: []*net.IPNet{lan},
+		Resolver: newResolvedResolver(map[string]interface{}{
+			"nas.local":  "192.168.1.10",
+			"bad.local":  errors.New("no such host"),
+			"wan.domain": "8.8.8.8",
+		}),
+		Matched:   matched,
+		Unmatched: unmatched,
+	}
+
+	for _, tt := range []struct {
+		host string
+		late bool
+		want core.Proxy
+		rule string
+	}{
+		{"10.0.0.1", false, matched, "10.0.0.1"},
+		{"192.168.2.2", false, matched, "192.168.0.0/16"},
+		{"10.0.0.2", false, unmatched, ""},
+		{"10.0.0.1", true, nil, ""},
+		{"nas.local", false, matched, "192.168.0.0/16 (nas.local resolved to 192.168.1.10)"},
+		{"nas.local", true, nil, ""},
+		{"wan.domain", false, unmatched, ""},
+		{"bad.local", false, unmatched, "resolve: no such host"},
+	} {
+		c := newExplainContext(tt.host)
+		c.IP = net.ParseIP(tt.host)
+		p, rule := r.Explain(c, tt.late)
+		if p != tt.want || rule.Text != tt.rule {
+			t.Errorf("%s late:%v should get %v by %q, but got: %v %+v", tt.host, tt.late, tt.want, tt.rule, p, rule)
+		}
+	}
+
+	r.ResolveLast = true
+	p, rule := r.Explain(newExplainContext("nas.local"), false)
+	if p != nil || rule.Text != "" {
+		t.Errorf("nas.local should not be explained before late, but got: %v %+v", p, rule)
+	}
+	p, rule = r.Explain(newExplainContext("nas.local"), true)
+	if p != matched || rule.Text != "192.168.0.0/16 (nas.local resolved to 192.168.1.10)" {
+		t.Errorf("nas.local should be explained late, but got: %v %+v", p, rule)
+	}
+}
+
+func TestGeoIPRouterExplain(t *testing.T) {
+	cn, google, unmatched := testRouteProxy("cn"), testRouteProxy("google"), testRouteProxy("unmatched")
+	r, err := NewGeoIPRouter(GeoIPRouterConfig{
+		DBs: newTestGeoDBs(t),
+		Rules: []GeoIPRule{
+			{Proxy: cn, Countries: []string{"CN"}},
+			{Proxy: google, ASNs: []uint{15169}},
+		},
+		Resolver: newResolvedResolver(map[string]interface{}{
+			"example.cn":  "1.1.1.1",
+			"bad.example": errors.New("no such host"),
+		}),
+		Unmatched: unmatched,
+	})
+	if err != nil {
+		t.Fatalf("NewGeoIPRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	for _, tt := range []struct {
+		host string
+		want core.Proxy
+		rule string
+	}{
+		{"1.2.3.4", cn, "country:CN (1.2.3.4)"},
+		{"8.8.8.8", google, "asn:15169 (8.8.8.8)"},
+		{"9.9.9.9", unmatched, "no rule for 9.9.9.9 country:CH asn:0"},
+		{"example.cn", cn, "country:CN (1.1.1.1)"},
+		{"bad.example", unmatched, "resolve: no such host"},
+	} {
+		c := newExplainContext(tt.host)
+		c.IP = net.ParseIP(tt.host)
+		p, rule := r.Explain(c, false)
+		if p != tt.want || rule.Text != tt.rule {
+			t.Errorf("%s should get %v by %q, but got: %v %+v", tt.host, tt.want, tt.rule, p, rule)
+		}
+	}
+}
@@ -16,6 +16,7 @@ var (
 
 type FileClientConfig struct {
 	Log      *zap.Logger
+	Name     string
 	Dev      bool
 	Disabled bool
 	RootZip  string
@@ -71,6 +72,9 @@ func (r *FileProxyRouterClient) Route(c *core.Context) core.Proxy {
 // Disabled implements Router
 func (r *FileProxyRouterClient) Disabled() bool { return r == nil || r.config.Disabled }
 
+// Name implements core.Namer
+func (r *FileProxyRouterClient) Name() string { return r.config.Name }
+
 // Do implements Proxy
 func (r *FileProxyRouterClient) Do(c *core.Context) error {
 	req := c.Request
@@ -83,7 +87,7 @@ func (r *FileProxyRouterClient) Do(c *core.Context) error {
 			if q := req.URL.RawQuery; q != "" {
 				newPath += "?" + q
 			}
-			newPath += "\r\n\r\n"
+			newPath += "\r\nContent-Length: 0\r\n\r\n"
 			c.Writer.Write(append(Standard301Prefix, []byte(newPath)...))
 			return nil
 		}
@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// GeoIPRule routes IPs located in Countries or announced by ASNs to Proxy.
+// Countries are ISO 3166-1 alpha-2 codes.
+type GeoIPRule struct {
+	Proxy     core.Proxy
+	Countries []string
+	ASNs      []uint
+}
+
+type GeoIPRouterConfig struct {
+	Name     string
+	Disabled bool
+
+	// DBs are MaxMind or DB-IP mmdb databases, the country and ASN records of
+	// all DBs are merged. DBs are closed by Close.
+	DBs []*maxminddb.Reader
+
+	// Rules match ASN before country. The former one wins if a country or ASN
+	// is in several rules.
+	Rules []GeoIPRule
+
+	// Resolver looks up hostnames, the first IP is located. Nil routes only
+	// IP hosts.
+	Resolver *HostResolver
+
+	// Unmatched can be nil, then the next router routes.
+	Unmatched core.Proxy
+}
+
+// GeoRecord is the located result of an IP.
+type GeoRecord struct {
+	Country string
+	ASN     uint
+}
+
+// geoRecord holds fields shared by GeoLite2/GeoIP2 and DB-IP country, city
+// and ASN databases.
+type geoRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+	ASN uint `maxminddb:"autonomous_system_number"`
+}
+
+type GeoIPRouter struct {
+	config    GeoIPRouterConfig
+	countries map[string]core.Proxy
+	asns      map[uint]core.Proxy
+}
+
+func NewGeoIPRouter(config GeoIPRouterConfig) (*GeoIPRouter, error) {
+	if len(config.DBs) == 0 {
+		return nil, fmt.Errorf("GeoIPRouter(%s): no db", config.Name)
+	}
+	r := &GeoIPRouter{
+		config:    config,
+		countries: make(map[string]core.Proxy),
+		asns:      make(map[uint]core.Proxy),
+	}
+	for _, rule := range config.Rules {
+		for _, country := range rule.Countries {
+			country = strings.ToUpper(country)
+			if len(country) != 2 {
+				return nil, fmt.Errorf("GeoIPRouter(%s): bad country code %q", config.Name, country)
+			}
+			if _, ok := r.countries[country]; !ok {
+				r.countries[country] = rule.Proxy
+			}
+		}
+		for _, asn := range rule.ASNs {
+			if _, ok := r.asns[asn]; !ok {
+				r.asns[asn] = rule.Proxy
+			}
+		}
+	}
+	return r, nil
+}
+
+func (r *GeoIPRouter) Disabled() bool { return r.config.Disabled }
+func (r *GeoIPRouter) Name() string   { return r.config.Name }
+
+// Lookup locates ip in all DBs.
+func (r *GeoIPRouter) Lookup(ip net.IP) (GeoRecord, error) {
+	var gr GeoRecord
+	for _, db := range r.config.DBs {
+		var record geoRecord
+		err := db.Lookup(ip, &record)
+		if err != nil {
+			return gr, err
+		}
+		if gr.Country == "" {
+			gr.Country = record.Country.IsoCode
+		}
+		if gr.Country == "" {
+			gr.Country = record.RegisteredCountry.IsoCode
+		}
+		if gr.ASN == 0 {
+			gr.ASN = record.ASN
+		}
+	}
+	return gr, nil
+}
+
+func (r *GeoIPRouter) Route(c *core.Context) core.Proxy {
+	return r.route(c, nil)
+}
+
+// Explain implements core.Explainer.
+func (r *GeoIPRouter) Explain(c *core.Context, late bool) (core.Proxy, core.MatchedRule) {
+	var rule core.MatchedRule
+	p := r.route(c, &rule)
+	return p, rule
+}
+
+// route fills rule if not nil.
+func (r *GeoIPRouter) route(c *core.Context, rule *core.MatchedRule) core.Proxy {
+	ip := c.IP
+	if ip == nil {
+		if r.config.Resolver == nil {
+			return r.config.Unmatched
+		}
+		ips, err := r.config.Resolver.LookupIP(c.Request.Context(), c.RouteHost())
+		if err != nil || len(ips) == 0 {
+			if rule != nil && err != nil {
+				rule.Text = "resolve: " + err.Error()
+			}
+			return r.config.Unmatched
+		}
+		ip = ips[0]
+	}
+
+	gr, err := r.Lookup(ip)
+	if err != nil {
+		if rule != nil {
+			rule.Text = "lookup: " + err.Error()
+		}
+		return r.config.Unmatched
+	}
+	if rule != nil {
+		rule.Text = fmt.Sprintf("no rule for %s country:%s asn:%d", ip, gr.Country, gr.ASN)
+	}
+	if p, ok := r.asns[gr.ASN]; ok && gr.ASN != 0 {
+		if rule != nil {
+			rule.Text = fmt.Sprintf("asn:%d (%s)", gr.ASN, ip)
+		}
+		return p
+	}
+	if p, ok := r.countries[gr.Country]; ok && gr.Country != "" {
+		if rule != nil {
+			rule.Text = fmt.Sprintf("country:%s (%s)", gr.Country, ip)
+		}
+		return p
+	}
+	return r.config.Unmatched
+}
+
+func (r *GeoIPRouter) Close() error {
+	var err error
+	for _, db := range r.config.DBs {
+		if e := db.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+var (
+	_ core.Router    = new(GeoIPRouter)
+	_ core.Explainer = new(GeoIPRouter)
+)
@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// mmdbWriter writes a tiny IPv4 mmdb with 24 bit records.
+type mmdbWriter struct {
+	nodes [][2]int // 0 is empty, negative is -(data index+1)
+	data  [][]byte
+}
+
+func (w *mmdbWriter) insert(cidr string, record []byte) {
+	_, n, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	if w.nodes == nil {
+		w.nodes = make([][2]int, 1)
+	}
+	w.data = append(w.data, record)
+	ip := n.IP.To4()
+	ones, _ := n.Mask.Size()
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+		if i == ones-1 {
+			w.nodes[node][bit] = -len(w.data)
+			break
+		}
+		if w.nodes[node][bit] <= 0 {
+			w.nodes = append(w.nodes, [2]int{})
+			w.nodes[node][bit] = len(w.nodes) - 1
+		}
+		node = w.nodes[node][bit]
+	}
+}
+
+func (w *mmdbWriter) bytes() []byte {
+	var data bytes.Buffer
+	offsets := make([]int, len(w.data))
+	for i, d := range w.data {
+		offsets[i] = data.Len()
+		data.Write(d)
+	}
+
+	var b bytes.Buffer
+	count := len(w.nodes)
+	for _, node := range w.nodes {
+		for _, v := range node {
+			switch {
+			case v == 0:
+				v = count
+			case v < 0:
+				v = count + 16 + offsets[-v-1]
+			}
+			b.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	b.Write(make([]byte, 16))
+	b.Write(data.Bytes())
+	b.WriteString("\xAB\xCD\xEFMaxMind.com")
+	b.Write(mmdbMap(
+		"binary_format_major_version", mmdbUint(5, 2),
+		"binary_format_minor_version", mmdbUint(5, 0),
+		"database_type", mmdbString("Test"),
+		"ip_version", mmdbUint(5, 4),
+		"node_count", mmdbUint(6, uint64(count)),
+		"record_size", mmdbUint(5, 24),
+	))
+	return b.Bytes()
+}
+
+func mmdbString(s string) []byte {
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+// mmdbUint encodes v of type typ, 5 is uint16 and 6 is uint32.
+func mmdbUint(typ byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	b := bytes.TrimLeft(buf[:], "\x00")
+	return append([]byte{typ<<5 | byte(len(b))}, b...)
+}
+
+func mmdbMap(kvs ...interface{}) []byte {
+	b := []byte{7<<5 | byte(len(kvs)/2)}
+	for i := 0; i < len(kvs); i += 2 {
+		b = append(b, mmdbString(kvs[i].(string))...)
+		b = append(b, kvs[i+1].([]byte)...)
+	}
+	return b
+}
+
+func mmdbCountry(code string) []byte {
+	return mmdbMap("country", mmdbMap("iso_code", mmdbString(code)))
+}
+
+func newTestGeoDBs(t *testing.T) []*maxminddb.Reader {
+	var country, asn mmdbWriter
+	country.insert("1.0.0.0/8", mmdbCountry("CN"))
+	country.insert("8.8.8.0/24", mmdbCountry("US"))
+	country.insert("9.9.9.0/24", mmdbMap("registered_country", mmdbMap("iso_code", mmdbString("CH"))))
+	asn.insert("8.8.8.0/24", mmdbMap("autonomous_system_number", mmdbUint(6, 15169)))
+
+	var dbs []*maxminddb.Reader
+	for _, w := range []*mmdbWriter{&country, &asn} {
+		db, err := maxminddb.FromBytes(w.bytes())
+		if err != nil {
+			t.Fatalf("mmdb fixture should be valid, but got: %v", err)
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+type testRouteProxy string
+
+func (p testRouteProxy) Do(c *core.Context) error                       { return nil }
+func (p testRouteProxy) HttpErr(c *core.Context, code int, info string) {}
+
+func TestGeoIPRouter(t *testing.T) {
+	cn, us, google, unmatched := testRouteProxy("cn"), testRouteProxy("us"), testRouteProxy("google"), testRouteProxy("unmatched")
+	r, err := NewGeoIPRouter(GeoIPRouterConfig{
+		Name: "geo",
+		DBs:  newTestGeoDBs(t),
+		Rules: []GeoIPRule{
+			{Proxy: cn, Countries: []string{"cn", "ch"}},
+			{Proxy: us, Countries: []string{"US"}},
+			{Proxy: google, ASNs: []uint{15169}},
+		},
+		Unmatched: unmatched,
+	})
+	if err != nil {
+		t.Fatalf("NewGeoIPRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	gr, err := r.Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup should be ok, but got: %v", err)
+	}
+	if gr.Country != "US" || gr.ASN != 15169 {
+		t.Errorf("8.8.8.8 should get US and 15169, but got: %+v", gr)
+	}
+
+	tests := []struct {
+		ip   string
+		want core.Proxy
+	}{
+		{"1.2.3.4", cn},
+		{"8.8.8.8", google},
+		{"9.9.9.9", cn},
+		{"8.8.4.4", unmatched},
+		{"::1", unmatched},
+	}
+	for _, tt := range tests {
+		c := &core.Context{IP: net.ParseIP(tt.ip)}
+		if got := r.Route(c); got != tt.want {
+			t.Errorf("%s should route to %v, but got: %v", tt.ip, tt.want, got)
+		}
+	}
+}
+
+func TestGeoIPRouterResolve(t *testing.T) {
+	cn, unmatched := testRouteProxy("cn"), testRouteProxy("unmatched")
+	resolved := &resolveEntry{
+		done:    make(chan struct{}),
+		ips:     []net.IP{net.ParseIP("1.1.1.1")},
+		expires: time.Now().Add(time.Hour),
+	}
+	close(resolved.done)
+	resolver := &HostResolver{cache: map[string]*resolveEntry{"example.cn": resolved}}
+
+	r, err := NewGeoIPRouter(GeoIPRouterConfig{
+		DBs:       newTestGeoDBs(t),
+		Rules:     []GeoIPRule{{Proxy: cn, Countries: []string{"CN"}}},
+		Resolver:  resolver,
+		Unmatched: unmatched,
+	})
+	if err != nil {
+		t.Fatalf("NewGeoIPRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	req, _ := http.NewRequest("GET", "http://example.cn/", nil)
+	c := &core.Context{Request: req, HostNoPort: "example.cn"}
+	if got := r.Route(c); got != cn {
+		t.Errorf("example.cn should route to cn, but got: %v", got)
+	}
+
+	ips, err := resolver.LookupIP(context.Background(), "1.2.3.4")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("1.2.3.4")) {
+		t.Errorf("IP host should be returned directly, but got: %v %v", ips, err)
+	}
+}
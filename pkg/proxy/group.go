@@ -0,0 +1,366 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// Strategies of Group.
+const (
+	StrategyFailover   = "failover"
+	StrategyRoundRobin = "round-robin"
+	StrategyLeastConn  = "least-conn"
+	StrategyLatency    = "latency"
+
+	DefaultProbeInterval = 30 * time.Second
+)
+
+var ErrNoMember = errors.New("group has no member")
+
+type GroupMember struct {
+	Name  string
+	Proxy core.Proxy
+}
+
+type GroupConfig struct {
+	Log      *zap.Logger
+	Name     string
+	Members  []GroupMember
+	Strategy string
+
+	// ProbeURL is requested through every member each ProbeInterval, the
+	// member is healthy if it responses status below 500. Empty disables
+	// probing. Zero ProbeInterval means DefaultProbeInterval.
+	ProbeURL      string
+	ProbeInterval time.Duration
+	ProbeTimeout  time.Duration
+
+	// MaxFails consecutive failures eject the member for EjectDuration.
+	// Ejected members are tried only if all members are ejected.
+	MaxFails      int
+	EjectDuration time.Duration
+}
+
+// Group proxies with one of its members picked by Strategy. The next member
+// is tried if a member fails before anything is sent or replied.
+type Group struct {
+	config  GroupConfig
+	members []*member
+	next    uint32
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+type member struct {
+	GroupMember
+	active int64
+
+	mu           sync.Mutex
+	fails        int
+	ejectedUntil time.Time
+	latency      time.Duration // moving average of probes, 0 if unknown
+}
+
+func NewGroup(config GroupConfig) (*Group, error) {
+	if len(config.Members) == 0 {
+		return nil, ErrNoMember
+	}
+	switch config.Strategy {
+	case "":
+		config.Strategy = StrategyFailover
+	case StrategyFailover, StrategyRoundRobin, StrategyLeastConn:
+	case StrategyLatency:
+		if config.ProbeURL == "" {
+			return nil, fmt.Errorf("group %s: latency strategy requires ProbeURL", config.Name)
+		}
+	default:
+		return nil, fmt.Errorf("group %s: unknown strategy %q", config.Name, config.Strategy)
+	}
+
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = DefaultProbeInterval
+	}
+
+	g := &Group{
+		config:  config,
+		members: make([]*member, len(config.Members)),
+		closed:  make(chan struct{}),
+	}
+	for i, m := range config.Members {
+		g.members[i] = &member{GroupMember: m}
+	}
+	return g, nil
+}
+
+func (g *Group) Name() string { return g.config.Name }
+
+// HttpErr implements Proxy
+func (g *Group) HttpErr(c *core.Context, code int, info string) {
+	he := &core.HttpErr{
+		Code:       code,
+		ClientType: "Group",
+		ClientName: g.config.Name,
+		TargetHost: c.HostPort,
+		Info:       info,
+	}
+	c.HttpErr(he)
+}
+
+// Do implements Proxy. Access.Proxy is set to the member name, so that rate
+// limits and metrics of the member are used.
+func (g *Group) Do(c *core.Context) (err error) {
+	state := saveRequest(c.Request)
+	for i, m := range g.pick() {
+		if i != 0 {
+			// Host, URL and headers are changed by the failed member
+			state.restore(c.Request)
+		}
+		c.Access.Proxy = m.Name
+		atomic.AddInt64(&m.active, 1)
+		err = m.Proxy.Do(c)
+		atomic.AddInt64(&m.active, -1)
+		if err == nil {
+			m.succeed()
+			return nil
+		}
+		if !retryable(c) {
+			// failed while streaming, maybe not the fault of member
+			return err
+		}
+		g.fail(m, err)
+	}
+	return err
+}
+
+// retryable reports whether nothing is sent or replied by c.
+func retryable(c *core.Context) bool {
+	if c.Access.Status != 0 || c.Access.BytesUp() != 0 || c.Access.BytesDown() != 0 {
+		return false
+	}
+	body := c.Request.Body
+	return c.Connect || body == nil || body == http.NoBody
+}
+
+// requestState is the part of Request changed by proxies.
+type requestState struct {
+	host   string
+	url    url.URL
+	header http.Header
+	body   io.ReadCloser
+	close  bool
+}
+
+func saveRequest(req *http.Request) *requestState {
+	return &requestState{
+		host:   req.Host,
+		url:    *req.URL,
+		header: cloneHeader(req.Header),
+		body:   req.Body,
+		close:  req.Close,
+	}
+}
+
+func (s *requestState) restore(req *http.Request) {
+	u := s.url
+	req.Host = s.host
+	req.URL = &u
+	req.Header = cloneHeader(s.header)
+	req.Body = s.body
+	req.Close = s.close
+}
+
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	h2 := make(http.Header, len(h))
+	for k, vv := range h {
+		h2[k] = append([]string(nil), vv...)
+	}
+	return h2
+}
+
+// pick orders members by Strategy, ejected members are moved to the end.
+func (g *Group) pick() []*member {
+	ms := make([]*member, len(g.members))
+	switch g.config.Strategy {
+	case StrategyRoundRobin:
+		start := int(atomic.AddUint32(&g.next, 1)-1) % len(g.members)
+		for i := range ms {
+			ms[i] = g.members[(start+i)%len(g.members)]
+		}
+	case StrategyLeastConn:
+		copy(ms, g.members)
+		sort.SliceStable(ms, func(i, j int) bool {
+			return atomic.LoadInt64(&ms[i].active) < atomic.LoadInt64(&ms[j].active)
+		})
+	case StrategyLatency:
+		copy(ms, g.members)
+		latency := make(map[*member]time.Duration, len(ms))
+		for _, m := range ms {
+			latency[m] = m.getLatency()
+		}
+		sort.SliceStable(ms, func(i, j int) bool {
+			li, lj := latency[ms[i]], latency[ms[j]]
+			// unknown last
+			return li != 0 && (lj == 0 || li < lj)
+		})
+	default:
+		copy(ms, g.members)
+	}
+
+	now := time.Now()
+	sort.SliceStable(ms, func(i, j int) bool {
+		return !ms[i].ejected(now) && ms[j].ejected(now)
+	})
+	return ms
+}
+
+func (g *Group) fail(m *member, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fails++
+	if g.config.MaxFails > 0 && m.fails >= g.config.MaxFails {
+		m.fails = 0
+		m.ejectedUntil = time.Now().Add(g.config.EjectDuration)
+		if g.config.Log != nil {
+			g.config.Log.Warn("group member ejected", zap.String("group", g.config.Name),
+				zap.String("member", m.Name), zap.Error(err))
+		}
+	}
+}
+
+func (m *member) succeed() {
+	m.mu.Lock()
+	m.fails = 0
+	m.ejectedUntil = time.Time{}
+	m.mu.Unlock()
+}
+
+func (m *member) ejected(now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return now.Before(m.ejectedUntil)
+}
+
+func (m *member) getLatency() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latency
+}
+
+func (m *member) setLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.latency == 0 {
+		m.latency = d
+	} else {
+		m.latency = (m.latency*3 + d) / 4
+	}
+}
+
+// Start probes members with contexts of cc until Close, if ProbeURL is set.
+func (g *Group) Start(cc *core.ContextConfig) {
+	if g.config.ProbeURL == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(g.config.ProbeInterval)
+		defer ticker.Stop()
+		for {
+			g.probeAll(cc)
+			select {
+			case <-ticker.C:
+			case <-g.closed:
+				return
+			}
+		}
+	}()
+}
+
+func (g *Group) Close() error {
+	g.closeOnce.Do(func() { close(g.closed) })
+	return nil
+}
+
+func (g *Group) probeAll(cc *core.ContextConfig) {
+	var wg sync.WaitGroup
+	for _, m := range g.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			start := time.Now()
+			err := g.probe(cc, m)
+			if err != nil {
+				g.fail(m, err)
+				return
+			}
+			m.setLatency(time.Since(start))
+			m.succeed()
+		}(m)
+	}
+	wg.Wait()
+}
+
+// probe requests ProbeURL through m, which responses to a probeWriter.
+func (g *Group) probe(cc *core.ContextConfig, m *member) error {
+	req, err := http.NewRequest("GET", g.config.ProbeURL, nil)
+	if err != nil {
+		return err
+	}
+	if g.config.ProbeTimeout != 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), g.config.ProbeTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	w := &probeWriter{header: make(http.Header)}
+	c, err := core.NewContextFromHandler(cc, w, req)
+	if err != nil {
+		return err
+	}
+	defer c.Finish()
+	err = m.Proxy.Do(c)
+	if err != nil {
+		return err
+	}
+	if w.code == 0 || w.code >= 500 {
+		return fmt.Errorf("probe status %d", w.code)
+	}
+	return nil
+}
+
+// probeWriter discards the probe response.
+type probeWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *probeWriter) Header() http.Header { return w.header }
+
+func (w *probeWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *probeWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+var _ core.Proxy = new(Group)
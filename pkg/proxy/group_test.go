@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// testMemberProxy records requests it gets, and changes them like H2Client
+// and ExistProxy before err returned.
+type testMemberProxy struct {
+	name  string
+	err   error
+	calls *[]string
+	seen  *http.Request
+}
+
+func (p *testMemberProxy) Do(c *core.Context) error {
+	*p.calls = append(*p.calls, p.name)
+	req := c.Request
+	seen := *req
+	seen.URL = new(url.URL)
+	*seen.URL = *req.URL
+	seen.Header = cloneHeader(req.Header)
+	p.seen = &seen
+
+	req.Host = "H" + req.Host
+	req.URL.Scheme = "http"
+	req.URL.Host = p.name
+	req.Header.Set("Proxy-Authorization", "Basic "+p.name)
+	return p.err
+}
+
+func (p *testMemberProxy) HttpErr(c *core.Context, code int, info string) {}
+
+func newTestGroup(t *testing.T, config GroupConfig, errs ...error) (*Group, []*testMemberProxy, *[]string) {
+	calls := new([]string)
+	proxies := make([]*testMemberProxy, len(errs))
+	for i, err := range errs {
+		name := string(rune('a' + i))
+		proxies[i] = &testMemberProxy{name: name, err: err, calls: calls}
+		config.Members = append(config.Members, GroupMember{Name: name, Proxy: proxies[i]})
+	}
+	g, err := NewGroup(config)
+	if err != nil {
+		t.Fatalf("NewGroup should be ok, but got: %v", err)
+	}
+	return g, proxies, calls
+}
+
+func doGroup(t *testing.T, g *Group) (*core.Context, error) {
+	req := httptest.NewRequest("GET", "https://example.com/a?b=1", nil)
+	req.Header.Set("X-Test", "1")
+	c, err := core.NewContextFromHandler(&core.ContextConfig{BufferPool: bufpool.Default}, httptest.NewRecorder(), req)
+	if err != nil {
+		t.Fatalf("NewContextFromHandler should be ok, but got: %v", err)
+	}
+	return c, g.Do(c)
+}
+
+func TestGroupFailover(t *testing.T) {
+	errDown := errors.New("down")
+	g, proxies, calls := newTestGroup(t, GroupConfig{}, errDown, nil)
+
+	c, err := doGroup(t, g)
+	if err != nil {
+		t.Fatalf("Do should fail over to b, but got: %v", err)
+	}
+	if strings.Join(*calls, ",") != "a,b" || c.Access.Proxy != "b" {
+		t.Errorf("a then b should be tried, but got: %v %s", *calls, c.Access.Proxy)
+	}
+	seen := proxies[1].seen
+	if seen.Host != "example.com" || seen.URL.String() != "https://example.com/a?b=1" {
+		t.Errorf("b should get the original Host and URL, but got: %s %s", seen.Host, seen.URL)
+	}
+	if seen.Header.Get("Proxy-Authorization") != "" || seen.Header.Get("X-Test") != "1" {
+		t.Errorf("b should get the original headers, but got: %v", seen.Header)
+	}
+
+	g, _, calls = newTestGroup(t, GroupConfig{}, errDown, errDown)
+	if _, err = doGroup(t, g); err != errDown {
+		t.Errorf("Do should get the last err, but got: %v", err)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("all members should be tried, but got: %v", *calls)
+	}
+}
+
+// reverseMember reverses requests of ResponseWriter contexts with tp, like
+// DIRECT and ExistProxy do.
+type reverseMember struct {
+	tp http.RoundTripper
+}
+
+func (p *reverseMember) Do(c *core.Context) error {
+	return c.DirectDial(p.tp, net.Dial)
+}
+
+func (p *reverseMember) HttpErr(c *core.Context, code int, info string) {}
+
+func TestGroupFailoverResponseWriter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	dead := &http.Transport{Dial: func(network, addr string) (net.Conn, error) {
+		return nil, errors.New("refused")
+	}}
+	alive := &http.Transport{Dial: func(network, addr string) (net.Conn, error) {
+		return net.Dial("tcp", ts.Listener.Addr().String())
+	}}
+	defer alive.CloseIdleConnections()
+	g, err := NewGroup(GroupConfig{Members: []GroupMember{
+		{Name: "a", Proxy: &reverseMember{dead}},
+		{Name: "b", Proxy: &reverseMember{alive}},
+	}})
+	if err != nil {
+		t.Fatalf("NewGroup should be ok, but got: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	rec := httptest.NewRecorder()
+	c, err := core.NewContextFromHandler(&core.ContextConfig{BufferPool: bufpool.Default}, rec, req)
+	if err != nil {
+		t.Fatalf("NewContextFromHandler should be ok, but got: %v", err)
+	}
+	if err := g.Do(c); err != nil {
+		t.Fatalf("Do should fail over to b, but got: %v", err)
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" || c.Access.Proxy != "b" {
+		t.Errorf("b should reply, but got: %d %q %s", rec.Code, rec.Body.String(), c.Access.Proxy)
+	}
+	if g.members[0].fails != 1 {
+		t.Errorf("a should be failed, but got fails: %d", g.members[0].fails)
+	}
+}
+
+func TestGroupRoundRobin(t *testing.T) {
+	g, _, calls := newTestGroup(t, GroupConfig{Strategy: StrategyRoundRobin}, nil, nil, nil)
+	for i := 0; i < 4; i++ {
+		doGroup(t, g)
+	}
+	if got := strings.Join(*calls, ","); got != "a,b,c,a" {
+		t.Errorf("members should be picked in turn, but got: %s", got)
+	}
+}
+
+func TestGroupLeastConn(t *testing.T) {
+	g, _, calls := newTestGroup(t, GroupConfig{Strategy: StrategyLeastConn}, nil, nil, nil)
+	g.members[0].active = 2
+	g.members[1].active = 1
+	doGroup(t, g)
+	if got := strings.Join(*calls, ","); got != "c" {
+		t.Errorf("member with least conns should be picked, but got: %s", got)
+	}
+}
+
+func TestGroupEjection(t *testing.T) {
+	errDown := errors.New("down")
+	g, proxies, calls := newTestGroup(t, GroupConfig{MaxFails: 2, EjectDuration: time.Hour}, errDown, nil)
+
+	doGroup(t, g)
+	doGroup(t, g)
+	if got := strings.Join(*calls, ","); got != "a,b,a,b" {
+		t.Errorf("a should be tried until MaxFails, but got: %s", got)
+	}
+
+	*calls = nil
+	doGroup(t, g)
+	if got := strings.Join(*calls, ","); got != "b" {
+		t.Errorf("ejected a should be tried last, but got: %s", got)
+	}
+
+	// all ejected, a is tried again and recovers
+	proxies[0].err = nil
+	g.members[1].ejectedUntil = time.Now().Add(time.Hour)
+	*calls = nil
+	doGroup(t, g)
+	if got := strings.Join(*calls, ","); got != "a" || g.members[0].ejected(time.Now()) {
+		t.Errorf("a should be tried and recovered if all ejected, but got: %s", got)
+	}
+}
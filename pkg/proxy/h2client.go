@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"golang.org/x/net/http2"
@@ -19,32 +21,73 @@ type H2Dialer struct {
 
 type H2ClientConfig struct {
 	Log *zap.Logger
+
+	// MaxConcurrentStreams limits streams of every dialer, requests wait for
+	// free streams. 0 means no limit.
+	MaxConcurrentStreams int
+
+	// PingInterval pings conns, which are closed if no pong in PingTimeout.
+	// 0 disables ping.
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+
+	// Failed dials are retried after MinBackoff, which doubles up to
+	// MaxBackoff. Zero means DefaultH2MinBackoff and DefaultH2MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// PreDial dials all dialers on Start, and redials when conns are dead.
+	PreDial bool
 }
 
 type H2Client struct {
 	log     *zap.Logger
 	config  H2ClientConfig
 	dialers map[string]*H2Dialer
+	conns   map[string]*h2Conn
 	tr      *http2.Transport
+
+	closeOnce sync.Once
+	closed    chan struct{}
 }
 
 func NewH2Client(config H2ClientConfig) *H2Client {
+	if config.Log == nil {
+		config.Log = zap.NewNop()
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultH2MinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultH2MaxBackoff
+	}
+	if config.PingTimeout <= 0 {
+		config.PingTimeout = config.PingInterval
+	}
+
 	h2 := H2Client{
 		log:     config.Log,
 		config:  config,
 		dialers: make(map[string]*H2Dialer),
+		conns:   make(map[string]*h2Conn),
 		tr:      &http2.Transport{AllowHTTP: true},
+		closed:  make(chan struct{}),
 	}
 
 	h2.tr.DialTLS = h2.DialAndAuth
+	h2.tr.ConnPool = (*h2ConnPool)(&h2)
 
 	return &h2
 }
 
+// AddDialer must be called before Start.
 func (h2 *H2Client) AddDialer(dialer *H2Dialer) (*H2Proxy, error) {
+	hc := newH2Conn(h2, dialer)
 	h2.dialers[dialer.Name] = dialer
+	h2.conns[dialer.Name] = hc
 	return &H2Proxy{
 		client: h2,
+		conn:   hc,
 		idx:    dialer.Name,
 	}, nil
 }
@@ -63,8 +106,47 @@ func (h2 *H2Client) DialAndAuth(network, addr string, cfg *tls.Config) (net.Conn
 	return dialer.Dial()
 }
 
+// Start dials all dialers in background if PreDial.
+func (h2 *H2Client) Start() {
+	if !h2.config.PreDial {
+		return
+	}
+	for _, hc := range h2.conns {
+		go hc.keep()
+	}
+}
+
+// Close closes all conns, and stops ping and redial.
+func (h2 *H2Client) Close() error {
+	h2.closeOnce.Do(func() {
+		close(h2.closed)
+		for _, hc := range h2.conns {
+			hc.close()
+		}
+	})
+	return nil
+}
+
+// Stats returns counters keyed by dialer name.
+func (h2 *H2Client) Stats() map[string]H2Stats {
+	stats := make(map[string]H2Stats, len(h2.conns))
+	for name, hc := range h2.conns {
+		stats[name] = hc.stats()
+	}
+	return stats
+}
+
 func (h2 *H2Client) Proxy(c *core.Context, idx string) error {
 	req := c.Request
+	hc, ok := h2.conns[idx]
+	if !ok {
+		return fmt.Errorf("Dialer not found: %s", idx)
+	}
+	if err := hc.acquire(req.Context()); err != nil {
+		return err
+	}
+	defer hc.release()
+
 	// keep underline real conn
 	req.Close = false
 	// fix for http2.checkConnHeaders
@@ -0,0 +1,362 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+const (
+	DefaultH2MinBackoff = time.Second
+	DefaultH2MaxBackoff = 2 * time.Minute
+)
+
+// H2Stats are counters of the conn to one H2Dialer.
+type H2Stats struct {
+	Connected     bool
+	Dials         uint64
+	DialFailures  uint64
+	PingFailures  uint64
+	Streams       uint64 // total
+	ActiveStreams int64
+}
+
+// BackoffError is returned when requests come before the next redial.
+type BackoffError struct {
+	Name  string
+	Until time.Time
+	Err   error // the last dial error
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("redial %s after %v: %v", e.Name, time.Until(e.Until).Round(time.Millisecond), e.Err)
+}
+
+// h2Conn manages the only ClientConn to a H2Dialer.
+type h2Conn struct {
+	client *H2Client
+	dialer *H2Dialer
+
+	// streams is nil if MaxConcurrentStreams is not set.
+	streams chan struct{}
+
+	mu       sync.Mutex
+	cc       *http2.ClientConn
+	dead     chan struct{} // closed when cc is dead
+	dialing  chan struct{} // closed when dial done
+	dialErr  error
+	fails    int
+	nextDial time.Time
+
+	dials         uint64
+	dialFailures  uint64
+	pingFailures  uint64
+	totalStreams  uint64
+	activeStreams int64
+}
+
+func newH2Conn(client *H2Client, dialer *H2Dialer) *h2Conn {
+	hc := &h2Conn{client: client, dialer: dialer}
+	if n := client.config.MaxConcurrentStreams; n > 0 {
+		hc.streams = make(chan struct{}, n)
+	}
+	return hc
+}
+
+// acquire waits for a free stream, release must be called if no error.
+func (hc *h2Conn) acquire(ctx context.Context) error {
+	if hc.streams != nil {
+		select {
+		case hc.streams <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	atomic.AddUint64(&hc.totalStreams, 1)
+	atomic.AddInt64(&hc.activeStreams, 1)
+	return nil
+}
+
+func (hc *h2Conn) release() {
+	atomic.AddInt64(&hc.activeStreams, -1)
+	if hc.streams != nil {
+		<-hc.streams
+	}
+}
+
+// get returns the live ClientConn, or dials a new one. Requests fail fast
+// with BackoffError before the next redial.
+func (hc *h2Conn) get() (*http2.ClientConn, error) {
+	hc.mu.Lock()
+	for {
+		if hc.cc != nil && hc.cc.CanTakeNewRequest() {
+			cc := hc.cc
+			hc.mu.Unlock()
+			return cc, nil
+		}
+		if hc.dialing != nil {
+			dialing := hc.dialing
+			hc.mu.Unlock()
+			<-dialing
+			hc.mu.Lock()
+			if hc.dialErr != nil {
+				err := hc.dialErr
+				hc.mu.Unlock()
+				return nil, err
+			}
+			continue
+		}
+		if hc.fails > 0 && time.Now().Before(hc.nextDial) {
+			err := &BackoffError{Name: hc.dialer.Name, Until: hc.nextDial, Err: hc.dialErr}
+			hc.mu.Unlock()
+			return nil, err
+		}
+		break
+	}
+
+	dialing := make(chan struct{})
+	hc.dialing = dialing
+	hc.mu.Unlock()
+
+	cc, err := hc.dial()
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.dialing = nil
+	hc.dialErr = err
+	close(dialing)
+	if err != nil {
+		atomic.AddUint64(&hc.dialFailures, 1)
+		hc.fails++
+		hc.nextDial = time.Now().Add(hc.backoff())
+		hc.client.log.Warn("h2 dial", zap.String("dialer", hc.dialer.Name), zap.Int("fails", hc.fails),
+			zap.Time("next", hc.nextDial), zap.Error(err))
+		return nil, err
+	}
+
+	atomic.AddUint64(&hc.dials, 1)
+	hc.fails = 0
+	hc.setLocked(cc)
+	return cc, nil
+}
+
+func (hc *h2Conn) dial() (*http2.ClientConn, error) {
+	conn, err := hc.dialer.Dial()
+	if err != nil {
+		return nil, err
+	}
+	cc, err := hc.client.tr.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return cc, nil
+}
+
+// backoff doubles from MinBackoff on every failed dial, up to MaxBackoff.
+func (hc *h2Conn) backoff() time.Duration {
+	d := hc.client.config.MinBackoff
+	for i := 1; i < hc.fails && d < hc.client.config.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > hc.client.config.MaxBackoff {
+		d = hc.client.config.MaxBackoff
+	}
+	return d
+}
+
+func (hc *h2Conn) setLocked(cc *http2.ClientConn) {
+	if hc.dead != nil {
+		close(hc.dead)
+	}
+	if hc.cc != nil && hc.cc != cc {
+		go hc.retire(hc.cc)
+	}
+	hc.cc = cc
+	hc.dead = make(chan struct{})
+	if hc.client.config.PingInterval > 0 {
+		go hc.ping(cc, hc.dead)
+	}
+}
+
+// retire closes the replaced cc after its active streams are done, or when
+// client closed.
+func (hc *h2Conn) retire(cc *http2.ClientConn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-hc.client.closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := cc.Shutdown(ctx); err != nil {
+		cc.Close()
+	}
+}
+
+// markDead forgets cc if it is the current one.
+func (hc *h2Conn) markDead(cc *http2.ClientConn) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.cc == cc {
+		hc.cc = nil
+		close(hc.dead)
+		hc.dead = nil
+	}
+}
+
+// ping closes cc if no pong in PingTimeout, until dead closed.
+func (hc *h2Conn) ping(cc *http2.ClientConn, dead chan struct{}) {
+	config := &hc.client.config
+	ticker := time.NewTicker(config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-dead:
+			return
+		case <-hc.client.closed:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.PingTimeout)
+		err := cc.Ping(ctx)
+		cancel()
+		if err != nil {
+			hc.pingFailed(cc, err)
+			return
+		}
+	}
+}
+
+func (hc *h2Conn) pingFailed(cc *http2.ClientConn, err error) {
+	atomic.AddUint64(&hc.pingFailures, 1)
+	hc.client.log.Warn("h2 ping", zap.String("dialer", hc.dialer.Name), zap.Error(err))
+	hc.markDead(cc)
+	cc.Close()
+}
+
+// probe gets the conn then pings it, both within timeout.
+func (hc *h2Conn) probe(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	type result struct {
+		cc  *http2.ClientConn
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		// the dial goes on after timeout, for the next request
+		cc, err := hc.get()
+		done <- result{cc, err}
+	}()
+
+	var cc *http2.ClientConn
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+		cc = r.cc
+	case <-ctx.Done():
+		return &core.TimeoutError{Reason: "dial", After: timeout}
+	}
+
+	err := cc.Ping(ctx)
+	if err != nil {
+		hc.pingFailed(cc, err)
+		if ctx.Err() != nil {
+			return &core.TimeoutError{Reason: "ping", After: timeout}
+		}
+		return err
+	}
+	return nil
+}
+
+// keep dials cc whenever it is dead, until client closed.
+func (hc *h2Conn) keep() {
+	for {
+		_, err := hc.get()
+		hc.mu.Lock()
+		wait, dead := hc.nextDial, hc.dead
+		hc.mu.Unlock()
+
+		if err != nil {
+			t := time.NewTimer(time.Until(wait))
+			select {
+			case <-t.C:
+			case <-hc.client.closed:
+				t.Stop()
+				return
+			}
+			continue
+		}
+		select {
+		case <-dead:
+		case <-hc.client.closed:
+			return
+		}
+	}
+}
+
+func (hc *h2Conn) stats() H2Stats {
+	hc.mu.Lock()
+	connected := hc.cc != nil
+	hc.mu.Unlock()
+	return H2Stats{
+		Connected:     connected,
+		Dials:         atomic.LoadUint64(&hc.dials),
+		DialFailures:  atomic.LoadUint64(&hc.dialFailures),
+		PingFailures:  atomic.LoadUint64(&hc.pingFailures),
+		Streams:       atomic.LoadUint64(&hc.totalStreams),
+		ActiveStreams: atomic.LoadInt64(&hc.activeStreams),
+	}
+}
+
+func (hc *h2Conn) close() {
+	hc.mu.Lock()
+	cc := hc.cc
+	if cc != nil {
+		hc.cc = nil
+		close(hc.dead)
+		hc.dead = nil
+	}
+	hc.mu.Unlock()
+	if cc != nil {
+		cc.Close()
+	}
+}
+
+// h2ConnPool implements http2.ClientConnPool with h2Conns keyed by dialer
+// name, which is the host of request urls.
+type h2ConnPool H2Client
+
+func (p *h2ConnPool) GetClientConn(req *http.Request, addr string) (*http2.ClientConn, error) {
+	name, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	hc, ok := p.conns[name]
+	if !ok {
+		return nil, fmt.Errorf("Dialer not found: %s", name)
+	}
+	return hc.get()
+}
+
+func (p *h2ConnPool) MarkDead(cc *http2.ClientConn) {
+	for _, hc := range p.conns {
+		hc.markDead(cc)
+	}
+}
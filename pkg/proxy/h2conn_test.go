@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+func waitClosed(cc *http2.ClientConn) bool {
+	for i := 0; i < 100; i++ {
+		if cc.State().Closed {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestH2ConnBackoff(t *testing.T) {
+	peer := newTestH2Peer(t, http.NotFoundHandler())
+	defer peer.Close()
+
+	var mu sync.Mutex
+	var dials int
+	up := false
+	client := NewH2Client(H2ClientConfig{
+		MinBackoff: 50 * time.Millisecond,
+		MaxBackoff: 150 * time.Millisecond,
+	})
+	defer client.Close()
+	client.AddDialer(&H2Dialer{Name: "peer", Dial: func() (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if !up {
+			return nil, errors.New("down")
+		}
+		return net.Dial("tcp", peer.ln.Addr().String())
+	}})
+	hc := client.conns["peer"]
+
+	if _, err := hc.get(); err == nil || err.Error() != "down" {
+		t.Fatalf("get should get the dial err, but got: %v", err)
+	}
+	_, err := hc.get()
+	if be, ok := err.(*BackoffError); !ok || be.Err.Error() != "down" {
+		t.Fatalf("get before the next dial should get BackoffError, but got: %v", err)
+	}
+	mu.Lock()
+	if dials != 1 {
+		t.Errorf("get should not dial in backoff, but got dials: %d", dials)
+	}
+	mu.Unlock()
+
+	for _, tt := range []struct {
+		fails int
+		want  time.Duration
+	}{
+		{1, 50 * time.Millisecond},
+		{2, 100 * time.Millisecond},
+		{3, 150 * time.Millisecond},
+		{10, 150 * time.Millisecond},
+	} {
+		hc.mu.Lock()
+		hc.fails = tt.fails
+		got := hc.backoff()
+		hc.mu.Unlock()
+		if got != tt.want {
+			t.Errorf("backoff after %d fails should be %v, but got: %v", tt.fails, tt.want, got)
+		}
+	}
+
+	hc.mu.Lock()
+	next := hc.nextDial
+	hc.mu.Unlock()
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	time.Sleep(time.Until(next))
+	if _, err := hc.get(); err != nil {
+		t.Fatalf("get after backoff should redial, but got: %v", err)
+	}
+	stats := hc.stats()
+	if !stats.Connected || stats.Dials != 1 || stats.DialFailures != 1 {
+		t.Errorf("stats should count the failed and good dials, but got: %+v", stats)
+	}
+}
+
+func TestH2ConnStreams(t *testing.T) {
+	client := NewH2Client(H2ClientConfig{MaxConcurrentStreams: 1})
+	defer client.Close()
+	client.AddDialer(&H2Dialer{Name: "peer"})
+	hc := client.conns["peer"]
+
+	if err := hc.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire should get a free stream, but got: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hc.acquire(ctx); err != context.DeadlineExceeded {
+		t.Errorf("acquire over MaxConcurrentStreams should wait until ctx done, but got: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- hc.acquire(context.Background()) }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire should wait for release, but got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	hc.release()
+	if err := <-acquired; err != nil {
+		t.Errorf("acquire should get the released stream, but got: %v", err)
+	}
+	hc.release()
+
+	stats := hc.stats()
+	if stats.Streams != 2 || stats.ActiveStreams != 0 {
+		t.Errorf("stats should count streams, but got: %+v", stats)
+	}
+}
+
+func TestH2ConnRedial(t *testing.T) {
+	entered := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	s := &http2.Server{MaxConcurrentStreams: 1}
+	peer := newTestH2PeerServer(t, s, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-unblock
+	}))
+	defer peer.Close()
+
+	client := NewH2Client(H2ClientConfig{})
+	defer client.Close()
+	client.AddDialer(peer.dialer("peer"))
+	hc := client.conns["peer"]
+
+	cc1, err := hc.get()
+	if err != nil {
+		t.Fatalf("get should dial the peer, but got: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest("GET", "http://peer/", nil)
+		res, err := cc1.RoundTrip(req)
+		if err == nil {
+			_, err = ioutil.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		done <- err
+	}()
+	<-entered
+	for i := 0; i < 100 && cc1.CanTakeNewRequest(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// cc1 serves the stream, but takes no new requests over the peer limit
+	cc2, err := hc.get()
+	if err != nil || cc2 == cc1 {
+		t.Fatalf("get should redial when the conn takes no new requests, but got: %v", err)
+	}
+	if n := peer.getDials(); n != 2 {
+		t.Errorf("get should dial once more, but got dials: %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if cc1.State().Closed {
+		t.Errorf("replaced conn should not be closed with active streams")
+	}
+
+	close(unblock)
+	if err := <-done; err != nil {
+		t.Errorf("stream of the replaced conn should finish, but got: %v", err)
+	}
+	if !waitClosed(cc1) {
+		t.Errorf("replaced conn should be closed once idle")
+	}
+
+	hc.markDead(cc2)
+	cc3, err := hc.get()
+	if err != nil || cc3 == cc2 {
+		t.Fatalf("get should redial after the conn marked dead, but got: %v", err)
+	}
+	if n := peer.getDials(); n != 3 {
+		t.Errorf("get should dial once more, but got dials: %d", n)
+	}
+}
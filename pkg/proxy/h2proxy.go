@@ -1,11 +1,14 @@
 package proxy
 
 import (
+	"time"
+
 	"github.com/empirefox/hybrid/pkg/core"
 )
 
 type H2Proxy struct {
 	client *H2Client
+	conn   *h2Conn
 	idx    string
 }
 
@@ -20,6 +23,12 @@ func (p *H2Proxy) HttpErr(c *core.Context, code int, info string) {
 	c.HttpErr(he)
 }
 
+func (p *H2Proxy) Name() string             { return p.idx }
 func (p *H2Proxy) Do(c *core.Context) error { return p.client.Proxy(c, p.idx) }
 
+// Probe pings the peer over the managed conn, which is dialed if not
+// connected. The conn is closed if no pong in timeout.
+func (p *H2Proxy) Probe(timeout time.Duration) error { return p.conn.probe(timeout) }
+
 var _ core.Proxy = new(H2Proxy)
+var _ core.Prober = new(H2Proxy)
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// testH2Peer serves h2c on conns of its listener. Conns accepted when frozen
+// are kept open but never served.
+type testH2Peer struct {
+	ln      net.Listener
+	handler http.Handler
+
+	mu     sync.Mutex
+	frozen bool
+	conns  []net.Conn
+	dials  int
+}
+
+func newTestH2Peer(t *testing.T, handler http.Handler) *testH2Peer {
+	return newTestH2PeerServer(t, new(http2.Server), handler)
+}
+
+func newTestH2PeerServer(t *testing.T, s *http2.Server, handler http.Handler) *testH2Peer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	p := &testH2Peer{ln: ln, handler: handler}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			p.mu.Lock()
+			p.conns = append(p.conns, conn)
+			frozen := p.frozen
+			p.mu.Unlock()
+			if !frozen {
+				go s.ServeConn(conn, &http2.ServeConnOpts{Handler: p.handler})
+			}
+		}
+	}()
+	return p
+}
+
+func (p *testH2Peer) dialer(name string) *H2Dialer {
+	return &H2Dialer{Name: name, Dial: func() (net.Conn, error) {
+		p.mu.Lock()
+		p.dials++
+		p.mu.Unlock()
+		return net.Dial("tcp", p.ln.Addr().String())
+	}}
+}
+
+// freeze closes all conns, later conns are not served.
+func (p *testH2Peer) freeze() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frozen = true
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *testH2Peer) getDials() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.dials
+}
+
+func (p *testH2Peer) Close() {
+	p.ln.Close()
+	p.freeze()
+}
+
+func TestH2ProxyProbe(t *testing.T) {
+	peer := newTestH2Peer(t, http.NotFoundHandler())
+	defer peer.Close()
+
+	client := NewH2Client(H2ClientConfig{})
+	defer client.Close()
+	p, _ := client.AddDialer(peer.dialer("peer"))
+
+	for i := 0; i < 2; i++ {
+		if err := p.Probe(time.Second); err != nil {
+			t.Fatalf("Probe should ping the peer, but got: %v", err)
+		}
+	}
+	if n := peer.getDials(); n != 1 {
+		t.Errorf("Probe should use the managed conn, but got dials: %d", n)
+	}
+
+	// the peer accepts conns, but never pongs
+	peer.freeze()
+	if err := p.Probe(200 * time.Millisecond); err == nil {
+		t.Errorf("Probe of the closed conn should fail")
+	}
+	err := p.Probe(200 * time.Millisecond)
+	if te, ok := err.(*core.TimeoutError); !ok || te.Reason != "ping" {
+		t.Errorf("Probe of a redialed conn not answering should get ping TimeoutError, but got: %v", err)
+	}
+	if n := peer.getDials(); n != 2 {
+		t.Errorf("Probe should redial once, but got dials: %d", n)
+	}
+	if stats := client.Stats()["peer"]; stats.Connected {
+		t.Errorf("conn not answering ping should be closed, but got: %+v", stats)
+	}
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+const (
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthTimeout  = 5 * time.Second
+)
+
+type HealthConfig struct {
+	Log *zap.Logger
+
+	// Proxies implementing core.Prober are checked, key is the proxy name.
+	Proxies map[string]core.Proxy
+
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// MaxFails consecutive failed probes make the proxy unhealthy, 0 means 1.
+	MaxFails int
+}
+
+// ProxyStatus is the last probe result of a proxy.
+type ProxyStatus struct {
+	Name      string
+	Healthy   bool
+	CheckedAt time.Time // zero if not checked yet
+	Latency   time.Duration
+	Fails     int // consecutive failed probes
+	Err       error
+}
+
+// Health probes proxies periodically. Proxies are healthy until probed
+// MaxFails times in a row.
+type Health struct {
+	config  HealthConfig
+	probers map[core.Proxy]string
+
+	mu     sync.RWMutex
+	status map[string]*ProxyStatus
+	subs   map[chan ProxyStatus]struct{}
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewHealth(config HealthConfig) *Health {
+	if config.Interval <= 0 {
+		config.Interval = DefaultHealthInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHealthTimeout
+	}
+	if config.MaxFails <= 0 {
+		config.MaxFails = 1
+	}
+
+	h := &Health{
+		config:  config,
+		probers: make(map[core.Proxy]string),
+		status:  make(map[string]*ProxyStatus),
+		subs:    make(map[chan ProxyStatus]struct{}),
+		closed:  make(chan struct{}),
+	}
+	for name, p := range config.Proxies {
+		if _, ok := p.(core.Prober); ok {
+			h.probers[p] = name
+			h.status[name] = &ProxyStatus{Name: name, Healthy: true}
+		}
+	}
+	return h
+}
+
+// Start probes all proxies every Interval until Close.
+func (h *Health) Start() {
+	go func() {
+		ticker := time.NewTicker(h.config.Interval)
+		defer ticker.Stop()
+		for {
+			h.probeAll()
+			select {
+			case <-ticker.C:
+			case <-h.closed:
+				return
+			}
+		}
+	}()
+}
+
+func (h *Health) Close() error {
+	h.closeOnce.Do(func() { close(h.closed) })
+	return nil
+}
+
+// Unhealthy reports whether p is checked and unhealthy, it can be used as
+// core.Core.Unhealthy.
+func (h *Health) Unhealthy(p core.Proxy) bool {
+	name, ok := h.probers[p]
+	if !ok {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return !h.status[name].Healthy
+}
+
+// Status returns status of all checked proxies sorted by name.
+func (h *Health) Status() []ProxyStatus {
+	h.mu.RLock()
+	list := make([]ProxyStatus, 0, len(h.status))
+	for _, s := range h.status {
+		list = append(list, *s)
+	}
+	h.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Subscribe receives every probe result until cancel is called. Results are
+// dropped if the receiver is slow.
+func (h *Health) Subscribe() (results <-chan ProxyStatus, cancel func()) {
+	ch := make(chan ProxyStatus, 16)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Done is closed when h is closed.
+func (h *Health) Done() <-chan struct{} { return h.closed }
+
+func (h *Health) probeAll() {
+	var wg sync.WaitGroup
+	for p, name := range h.probers {
+		wg.Add(1)
+		go func(p core.Prober, name string) {
+			defer wg.Done()
+			start := time.Now()
+			err := p.Probe(h.config.Timeout)
+			h.update(name, time.Since(start), err)
+		}(p.(core.Prober), name)
+	}
+	wg.Wait()
+}
+
+func (h *Health) update(name string, latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.status[name]
+	s.CheckedAt = time.Now()
+	s.Err = err
+	if err == nil {
+		s.Latency = latency
+		s.Fails = 0
+		if !s.Healthy && h.config.Log != nil {
+			h.config.Log.Info("proxy healthy", zap.String("proxy", name))
+		}
+		s.Healthy = true
+	} else {
+		s.Fails++
+		if s.Healthy && s.Fails >= h.config.MaxFails {
+			s.Healthy = false
+			if h.config.Log != nil {
+				h.config.Log.Warn("proxy unhealthy", zap.String("proxy", name), zap.Error(err))
+			}
+		}
+	}
+
+	for ch := range h.subs {
+		select {
+		case ch <- *s:
+		default:
+		}
+	}
+}
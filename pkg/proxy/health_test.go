@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+// testProber returns errs in order, then nil.
+type testProber struct {
+	testRouteProxy
+	mu   sync.Mutex
+	errs []error
+}
+
+func (p *testProber) Probe(timeout time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.errs) == 0 {
+		return nil
+	}
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+	return err
+}
+
+func TestHealth(t *testing.T) {
+	errDown := errors.New("down")
+	for _, tc := range []struct {
+		name     string
+		maxFails int
+		errs     []error
+		healthy  []bool // after every probe
+	}{
+		{"ok", 0, nil, []bool{true, true}},
+		{"one fail", 0, []error{errDown}, []bool{false, true}},
+		{"under MaxFails", 3, []error{errDown, errDown, nil, errDown}, []bool{true, true, true, true}},
+		{"MaxFails", 2, []error{errDown, errDown, errDown}, []bool{true, false, false, true}},
+	} {
+		p := &testProber{testRouteProxy: "p", errs: tc.errs}
+		plain := testRouteProxy("plain")
+		h := NewHealth(HealthConfig{
+			Proxies:  map[string]core.Proxy{"p": p, "plain": plain},
+			MaxFails: tc.maxFails,
+		})
+		if h.Unhealthy(p) {
+			t.Errorf("%s: p should be healthy before probed", tc.name)
+		}
+		for i, healthy := range tc.healthy {
+			h.probeAll()
+			if h.Unhealthy(p) == healthy {
+				t.Errorf("%s: p should be healthy=%v after probe %d", tc.name, healthy, i)
+			}
+		}
+		if h.Unhealthy(plain) {
+			t.Errorf("%s: proxy not Prober should never be unhealthy", tc.name)
+		}
+
+		status := h.Status()
+		if len(status) != 1 || status[0].Name != "p" || status[0].CheckedAt.IsZero() {
+			t.Errorf("%s: only p should be checked, but got: %+v", tc.name, status)
+		}
+	}
+}
+
+func TestHealthSubscribe(t *testing.T) {
+	errDown := errors.New("down")
+	p := &testProber{testRouteProxy: "p", errs: []error{errDown}}
+	h := NewHealth(HealthConfig{Proxies: map[string]core.Proxy{"p": p}})
+
+	results, cancel := h.Subscribe()
+	h.probeAll()
+	if s := <-results; s.Name != "p" || s.Healthy || s.Err != errDown || s.Fails != 1 {
+		t.Errorf("subscriber should get the failed probe, but got: %+v", s)
+	}
+
+	// slow subscribers never block probing
+	for i := 0; i < 20; i++ {
+		h.probeAll()
+	}
+	if n := len(results); n != cap(results) {
+		t.Errorf("results should be dropped when full, but got: %d", n)
+	}
+
+	cancel()
+	cancel()
+	for len(results) > 0 {
+		<-results
+	}
+	h.probeAll()
+	if n := len(results); n != 0 {
+		t.Errorf("canceled subscriber should get nothing, but got: %d", n)
+	}
+}
@@ -7,7 +7,8 @@ import (
 )
 
 type IPNetRouter struct {
-	Skip bool
+	RouterName string
+	Skip       bool
 
 	IPs  []net.IP
 	Nets []*net.IPNet
@@ -15,28 +16,101 @@ type IPNetRouter struct {
 	// FileClient test Matched host file, then proxy it if test ok.
 	FileClient *FileProxyRouterClient
 
+	// Resolver resolves hostnames, nil matches only IP hosts. Hostnames are
+	// resolved in RouteLate if ResolveLast, only when no router routes.
+	Resolver    *HostResolver
+	ResolveLast bool
+
 	Matched   core.Proxy
 	Unmatched core.Proxy
 }
 
 func (r *IPNetRouter) Disabled() bool { return r.Skip }
+func (r *IPNetRouter) Name() string   { return r.RouterName }
 
 func (r *IPNetRouter) Route(c *core.Context) core.Proxy {
-	for _, i := range r.IPs {
-		if i.Equal(c.IP) {
-			if p := r.FileClient.Route(c); p != nil {
-				return p
-			}
-			return r.Matched
+	return r.route(c, false, nil)
+}
+
+// RouteLate implements core.LateRouter.
+func (r *IPNetRouter) RouteLate(c *core.Context) core.Proxy {
+	return r.route(c, true, nil)
+}
+
+// Explain implements core.Explainer.
+func (r *IPNetRouter) Explain(c *core.Context, late bool) (core.Proxy, core.MatchedRule) {
+	var rule core.MatchedRule
+	p := r.route(c, late, &rule)
+	return p, rule
+}
+
+// route fills rule if not nil.
+func (r *IPNetRouter) route(c *core.Context, late bool, rule *core.MatchedRule) core.Proxy {
+	if c.IP != nil || r.Resolver == nil {
+		if late {
+			return nil
+		}
+		return r.routeIPs(c, []net.IP{c.IP}, rule)
+	}
+	if late != r.ResolveLast {
+		return nil
+	}
+
+	ips, err := r.Resolver.LookupIP(c.Request.Context(), c.RouteHost())
+	if err != nil {
+		if rule != nil {
+			rule.Text = "resolve: " + err.Error()
 		}
+		return r.Unmatched
 	}
-	for _, n := range r.Nets {
-		if n.Contains(c.IP) {
-			if p := r.FileClient.Route(c); p != nil {
-				return p
+	return r.routeIPs(c, ips, rule)
+}
+
+// routeIPs matches if any of ips matches.
+func (r *IPNetRouter) routeIPs(c *core.Context, ips []net.IP, rule *core.MatchedRule) core.Proxy {
+	for _, ip := range ips {
+		i := r.match(ip)
+		if i == -1 {
+			continue
+		}
+		if rule != nil {
+			if i < len(r.IPs) {
+				rule.Text = r.IPs[i].String()
+			} else {
+				rule.Text = r.Nets[i-len(r.IPs)].String()
+			}
+			if !ip.Equal(c.IP) {
+				rule.Text += " (" + c.RouteHost() + " resolved to " + ip.String() + ")"
 			}
-			return r.Matched
 		}
+		if p := r.FileClient.Route(c); p != nil {
+			if rule != nil {
+				rule.Text += ", FileTest matched"
+			}
+			return p
+		}
+		return r.Matched
 	}
 	return r.Unmatched
 }
+
+// match returns the index of IPs, or len(IPs) + index of Nets, -1 if not
+// matched.
+func (r *IPNetRouter) match(ip net.IP) int {
+	for i, v := range r.IPs {
+		if v.Equal(ip) {
+			return i
+		}
+	}
+	for i, n := range r.Nets {
+		if n.Contains(ip) {
+			return len(r.IPs) + i
+		}
+	}
+	return -1
+}
+
+var (
+	_ core.LateRouter = new(IPNetRouter)
+	_ core.Explainer  = new(IPNetRouter)
+)
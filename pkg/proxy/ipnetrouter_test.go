@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func TestIPNetRouterResolve(t *testing.T) {
+	resolved := &resolveEntry{
+		done:    make(chan struct{}),
+		ips:     []net.IP{net.ParseIP("192.168.1.10")},
+		expires: time.Now().Add(time.Hour),
+	}
+	close(resolved.done)
+	resolver := &HostResolver{cache: map[string]*resolveEntry{"nas.local": resolved}}
+
+	_, lan, _ := net.ParseCIDR("192.168.0.0/16")
+	matched, unmatched := testRouteProxy("lan"), testRouteProxy("wan")
+	r := &IPNetRouter{
+		Nets:      []*net.IPNet{lan},
+		Matched:   matched,
+		Unmatched: unmatched,
+	}
+
+	req, _ := http.NewRequest("GET", "http://nas.local/", nil)
+	c := &core.Context{Request: req, HostNoPort: "nas.local"}
+	if got := r.Route(c); got != unmatched {
+		t.Errorf("hostname should be unmatched without Resolver, but got: %v", got)
+	}
+
+	r.Resolver = resolver
+	if got := r.Route(c); got != matched {
+		t.Errorf("hostname should be matched by resolved IP, but got: %v", got)
+	}
+
+	r.ResolveLast = true
+	if got := r.Route(c); got != nil {
+		t.Errorf("hostname should be routed late, but got: %v", got)
+	}
+	if got := r.RouteLate(c); got != matched {
+		t.Errorf("hostname should be matched late, but got: %v", got)
+	}
+
+	c = &core.Context{Request: req, IP: net.ParseIP("10.0.0.1")}
+	if got := r.Route(c); got != unmatched {
+		t.Errorf("IP should be routed at once, but got: %v", got)
+	}
+	if got := r.RouteLate(c); got != nil {
+		t.Errorf("IP should not be routed late, but got: %v", got)
+	}
+}
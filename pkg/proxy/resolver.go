@@ -0,0 +1,337 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	DefaultResolveTimeout = 5 * time.Second
+	DefaultResolveMinTTL  = 30 * time.Second
+	DefaultResolveMaxTTL  = time.Hour
+
+	DefaultResolveMaxEntries = 4096
+)
+
+var errDNSIDMismatch = errors.New("dns id mismatch")
+
+// HostResolver looks up IPs of hosts with cache.
+type HostResolver struct {
+	// Server is host:port of a DNS server, answers are cached for their TTL
+	// within [MinTTL, MaxTTL]. Empty uses Resolver, answers of which are
+	// cached for MinTTL. Errors are always cached for MinTTL.
+	Server   string
+	Resolver *net.Resolver // nil means net.DefaultResolver
+
+	// MaxEntries bounds cached hosts, expired ones are evicted first when
+	// full. Zero values mean defaults.
+	Timeout    time.Duration
+	MinTTL     time.Duration
+	MaxTTL     time.Duration
+	MaxEntries int
+
+	mu    sync.Mutex
+	cache map[string]*resolveEntry
+}
+
+type resolveEntry struct {
+	done    chan struct{} // closed when resolved
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+// LookupIP resolves host once for concurrent calls. IP hosts are returned
+// directly. The resolving is not canceled with ctx, so that other callers of
+// host still get the result.
+func (r *HostResolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = make(map[string]*resolveEntry)
+	}
+	e, ok := r.cache[host]
+	if ok {
+		select {
+		case <-e.done:
+			if now.After(e.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		r.evictLocked(now)
+		e = &resolveEntry{done: make(chan struct{})}
+		r.cache[host] = e
+		go r.resolve(host, e)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-e.done:
+		return e.ips, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *HostResolver) resolve(host string, e *resolveEntry) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultResolveTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var ttl time.Duration
+	if r.Server != "" {
+		e.ips, ttl, e.err = r.exchangeIP(ctx, host)
+	} else {
+		e.ips, e.err = r.lookupIPAddr(ctx, host)
+	}
+
+	minTTL, maxTTL := r.MinTTL, r.MaxTTL
+	if minTTL <= 0 {
+		minTTL = DefaultResolveMinTTL
+	}
+	if maxTTL <= 0 {
+		maxTTL = DefaultResolveMaxTTL
+	}
+	if ttl < minTTL || e.err != nil {
+		ttl = minTTL
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	e.expires = time.Now().Add(ttl)
+	close(e.done)
+}
+
+func (r *HostResolver) lookupIPAddr(ctx context.Context, host string) ([]net.IP, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, a := range addrs {
+		ips[i] = a.IP
+	}
+	return ips, nil
+}
+
+// exchangeIP queries A and AAAA of host from Server, ttl is the minimum TTL
+// of answers.
+func (r *HostResolver) exchangeIP(ctx context.Context, host string) (ips []net.IP, ttl time.Duration, err error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	type result struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	types := []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+	results := make([]result, len(types))
+	var wg sync.WaitGroup
+	for i, typ := range types {
+		wg.Add(1)
+		go func(i int, typ dnsmessage.Type) {
+			defer wg.Done()
+			res := &results[i]
+			res.ips, res.ttl, res.err = r.exchange(ctx, name, typ)
+		}(i, typ)
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		if res.err != nil {
+			err = res.err
+			continue
+		}
+		if len(res.ips) != 0 && (ttl == 0 || res.ttl < ttl) {
+			ttl = res.ttl
+		}
+		ips = append(ips, res.ips...)
+	}
+	if len(ips) != 0 {
+		return ips, ttl, nil
+	}
+	if err == nil {
+		err = &net.DNSError{Err: "no such host", Name: host, Server: r.Server}
+	}
+	return nil, 0, err
+}
+
+func (r *HostResolver) exchange(ctx context.Context, name dnsmessage.Name, typ dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	// unpredictable ids against spoofed answers
+	var b [2]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return nil, 0, err
+	}
+	id := binary.BigEndian.Uint16(b[:])
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: typ, Class: dnsmessage.ClassINET},
+		},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	answer, err := r.exchangeUDP(ctx, query, id)
+	if err == nil && answer.Truncated {
+		answer, err = r.exchangeTCP(ctx, query, id)
+	}
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: name.String(), Server: r.Server}
+	}
+
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", Name: name.String(), Server: r.Server}
+	default:
+		return nil, 0, &net.DNSError{Err: "server misbehaving: " + answer.RCode.String(), Name: name.String(), Server: r.Server}
+	}
+
+	var ips []net.IP
+	var ttl uint32
+	for _, rr := range answer.Answers {
+		var ip net.IP
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = net.IP(body.A[:])
+		case *dnsmessage.AAAAResource:
+			ip = net.IP(body.AAAA[:])
+		default:
+			continue
+		}
+		if len(ips) == 0 || rr.Header.TTL < ttl {
+			ttl = rr.Header.TTL
+		}
+		ips = append(ips, ip)
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+func (r *HostResolver) exchangeUDP(ctx context.Context, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Write(query)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 512)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var answer dnsmessage.Message
+		if answer.Unpack(buf[:n]) != nil || answer.ID != id {
+			// ignore the stray
+			continue
+		}
+		return &answer, nil
+	}
+}
+
+func (r *HostResolver) exchangeTCP(ctx context.Context, query []byte, id uint16) (*dnsmessage.Message, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	b := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(b, uint16(len(query)))
+	copy(b[2:], query)
+	_, err = conn.Write(b)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.ReadFull(conn, b[:2])
+	if err != nil {
+		return nil, err
+	}
+	b = make([]byte, binary.BigEndian.Uint16(b[:2]))
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return nil, err
+	}
+	var answer dnsmessage.Message
+	err = answer.Unpack(b)
+	if err != nil {
+		return nil, err
+	}
+	if answer.ID != id {
+		return nil, errDNSIDMismatch
+	}
+	return &answer, nil
+}
+
+// evictLocked makes room for a new entry if full. Expired entries are
+// removed, then resolved ones until 3/4 full, so that the cache is not
+// scanned on every miss.
+func (r *HostResolver) evictLocked(now time.Time) {
+	max := r.MaxEntries
+	if max <= 0 {
+		max = DefaultResolveMaxEntries
+	}
+	if len(r.cache) < max {
+		return
+	}
+	for host, e := range r.cache {
+		select {
+		case <-e.done:
+			if now.After(e.expires) {
+				delete(r.cache, host)
+			}
+		default:
+		}
+	}
+	for host, e := range r.cache {
+		if len(r.cache) < max*3/4+1 {
+			return
+		}
+		select {
+		case <-e.done:
+			delete(r.cache, host)
+		default:
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveTestDNS answers A queries of hosts with ttl, until the returned conn is
+// closed. queries counts received queries.
+func serveTestDNS(t *testing.T, hosts map[string]string, ttl uint32, queries *int32) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket should be ok, but got: %v", err)
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(queries, 1)
+
+			var msg dnsmessage.Message
+			if msg.Unpack(buf[:n]) != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			q := msg.Questions[0]
+			msg.Header.Response = true
+			ip, ok := hosts[q.Name.String()]
+			if !ok {
+				msg.Header.RCode = dnsmessage.RCodeNameError
+			} else if q.Type == dnsmessage.TypeA {
+				var a dnsmessage.AResource
+				copy(a.A[:], net.ParseIP(ip).To4())
+				msg.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: ttl},
+					Body:   &a,
+				}}
+			}
+			b, err := msg.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(b, addr)
+		}
+	}()
+	return conn
+}
+
+func TestHostResolver(t *testing.T) {
+	var queries int32
+	conn := serveTestDNS(t, map[string]string{"nas.local.": "192.168.1.10"}, 60, &queries)
+	defer conn.Close()
+
+	r := &HostResolver{Server: conn.LocalAddr().String(), Timeout: time.Second}
+	for i := 0; i < 2; i++ {
+		ips, err := r.LookupIP(context.Background(), "nas.local")
+		if err != nil {
+			t.Fatalf("LookupIP should be ok, but got: %v", err)
+		}
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.168.1.10")) {
+			t.Errorf("nas.local should get 192.168.1.10, but got: %v", ips)
+		}
+	}
+	// A and AAAA
+	if n := atomic.LoadInt32(&queries); n != 2 {
+		t.Errorf("answers should be cached, but got queries: %d", n)
+	}
+
+	e := r.cache["nas.local"]
+	if ttl := time.Until(e.expires); ttl < 50*time.Second || ttl > 60*time.Second {
+		t.Errorf("answer should be cached for its TTL, but got: %v", ttl)
+	}
+
+	_, err := r.LookupIP(context.Background(), "none.local")
+	if dnsErr, ok := err.(*net.DNSError); !ok || dnsErr.Err != "no such host" {
+		t.Errorf("none.local should get no such host, but got: %v", err)
+	}
+	if ttl := time.Until(r.cache["none.local"].expires); ttl > DefaultResolveMinTTL {
+		t.Errorf("error should be cached for MinTTL, but got: %v", ttl)
+	}
+}
+
+func TestHostResolverMinTTL(t *testing.T) {
+	var queries int32
+	conn := serveTestDNS(t, map[string]string{"nas.local.": "192.168.1.10"}, 0, &queries)
+	defer conn.Close()
+
+	r := &HostResolver{Server: conn.LocalAddr().String(), MinTTL: time.Minute}
+	_, err := r.LookupIP(context.Background(), "nas.local")
+	if err != nil {
+		t.Fatalf("LookupIP should be ok, but got: %v", err)
+	}
+	if ttl := time.Until(r.cache["nas.local"].expires); ttl < 50*time.Second {
+		t.Errorf("TTL should be at least MinTTL, but got: %v", ttl)
+	}
+}
+
+func TestHostResolverCallerCanceled(t *testing.T) {
+	var queries int32
+	conn := serveTestDNS(t, map[string]string{"nas.local.": "192.168.1.10"}, 60, &queries)
+	defer conn.Close()
+
+	gate := make(chan struct{})
+	r := &HostResolver{Timeout: time.Second, Resolver: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-gate
+			return net.Dial("udp", conn.LocalAddr().String())
+		},
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := r.LookupIP(ctx, "nas.local")
+		canceled <- err
+	}()
+	for i := 0; i < 100; i++ {
+		r.mu.Lock()
+		_, ok := r.cache["nas.local"]
+		r.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	type result struct {
+		ips []net.IP
+		err error
+	}
+	waited := make(chan result, 1)
+	go func() {
+		ips, err := r.LookupIP(context.Background(), "nas.local")
+		waited <- result{ips, err}
+	}()
+
+	cancel()
+	if err := <-canceled; err != context.Canceled {
+		t.Errorf("canceled caller should get context.Canceled, but got: %v", err)
+	}
+	close(gate)
+	res := <-waited
+	if res.err != nil || len(res.ips) != 1 || !res.ips[0].Equal(net.ParseIP("192.168.1.10")) {
+		t.Errorf("waiter should get the answer after another caller canceled, but got: %v %v", res.ips, res.err)
+	}
+}
+
+func TestHostResolverMaxEntries(t *testing.T) {
+	r := newResolvedResolver(map[string]interface{}{
+		"a.local": "192.168.1.1",
+		"b.local": "192.168.1.2",
+		"c.local": "192.168.1.3",
+		"d.local": "192.168.1.4",
+	})
+	r.MaxEntries = 4
+	r.Resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, errors.New("no dns")
+		},
+	}
+	r.cache["a.local"].expires = time.Now().Add(-time.Second)
+
+	r.LookupIP(context.Background(), "e.local")
+	if _, ok := r.cache["a.local"]; ok || len(r.cache) != 4 {
+		t.Errorf("expired entry should be evicted first, but got: %d entries", len(r.cache))
+	}
+
+	r.LookupIP(context.Background(), "f.local")
+	if _, ok := r.cache["f.local"]; !ok || len(r.cache) > 4 {
+		t.Errorf("cache should be bounded to MaxEntries, but got: %d entries", len(r.cache))
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RuleFile is a rule file in a rules dir.
+type RuleFile struct {
+	Name    string
+	Content []byte
+}
+
+// ReadRuleFiles reads files start with digit in dir, sorted by name. Other
+// names are returned as skipped.
+func ReadRuleFiles(dir string) (files []RuleFile, skipped []string, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if isRuleFile(name) && !info.IsDir() {
+			names = append(names, name)
+		} else {
+			skipped = append(skipped, name)
+		}
+	}
+
+	sort.Strings(names)
+	files = make([]RuleFile, 0, len(names))
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, RuleFile{Name: name, Content: content})
+	}
+	return files, skipped, nil
+}
+
+// ruleDirStamp changes when rule files in dir are changed.
+func ruleDirStamp(dir string) (string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, info := range infos {
+		if isRuleFile(info.Name()) && !info.IsDir() {
+			fmt.Fprintf(&b, "%s:%d:%d;", info.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String(), nil
+}
+
+func isRuleFile(name string) bool {
+	return name != "" && name[0] >= '0' && name[0] <= '9'
+}
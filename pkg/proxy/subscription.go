@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+const (
+	DefaultSubscriptionInterval = 24 * time.Hour
+	DefaultSubscriptionTimeout  = time.Minute
+	DefaultSubscriptionRetry    = 5 * time.Minute
+
+	// MaxSubscriptionSize limits the fetched list.
+	MaxSubscriptionSize = 32 << 20
+)
+
+var (
+	ErrSubscriptionChecksum  = errors.New("subscription checksum mismatch")
+	ErrSubscriptionSignature = errors.New("subscription signature invalid")
+	ErrSubscriptionTooLarge  = errors.New("subscription too large")
+)
+
+// RuleSubscription fetches a rule list from URL through Proxy every Interval,
+// saves it as FileName in the rule dir, then reloads rules.
+type RuleSubscription struct {
+	URL string
+
+	// FileName must start with digit. It is saved in B64RuleDir if Base64,
+	// or TxtRuleDir.
+	FileName string
+	Base64   bool
+
+	// Proxy nil means core.DirectProxy.
+	Proxy core.Proxy
+
+	// Zero values mean defaults.
+	Interval time.Duration
+	Timeout  time.Duration
+
+	// ChecksumURL serves the hex sha256 of the list, as sha256sum outputs.
+	ChecksumURL string
+
+	// SignatureURL serves the ed25519 signature of the list by PublicKey, raw
+	// or in hex.
+	SignatureURL string
+	PublicKey    ed25519.PublicKey
+}
+
+func (s *RuleSubscription) dir(config *AdpRouterConfig) string {
+	if s.Base64 {
+		return config.B64RuleDir
+	}
+	return config.TxtRuleDir
+}
+
+func (s *RuleSubscription) validate(config *AdpRouterConfig) error {
+	if s.URL == "" {
+		return errors.New("empty url")
+	}
+	if !isRuleFile(s.FileName) || filepath.Base(s.FileName) != s.FileName {
+		return fmt.Errorf("FileName %q should start with digit", s.FileName)
+	}
+	if s.dir(config) == "" {
+		return fmt.Errorf("rule dir of %s not set", s.FileName)
+	}
+	if s.SignatureURL != "" && len(s.PublicKey) != ed25519.PublicKeySize {
+		return errors.New("bad ed25519 PublicKey")
+	}
+	return nil
+}
+
+// subscribe fetches s until r is closed. The first fetch waits until
+// Interval after the saved file modified.
+func (r *AdpRouter) subscribe(cc *core.ContextConfig, s RuleSubscription) {
+	if s.Interval <= 0 {
+		s.Interval = DefaultSubscriptionInterval
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = DefaultSubscriptionTimeout
+	}
+	if s.Proxy == nil {
+		s.Proxy = core.DirectProxy
+	}
+	log := r.log.With(zap.String("router", r.config.Name), zap.String("url", s.URL))
+	filename := filepath.Join(s.dir(&r.config), s.FileName)
+
+	var wait time.Duration
+	if info, err := os.Stat(filename); err == nil {
+		wait = time.Until(info.ModTime().Add(s.Interval))
+	}
+	for {
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-r.closed:
+				t.Stop()
+				return
+			}
+		}
+		wait = s.Interval
+
+		changed, err := r.fetchSubscription(cc, &s, filename)
+		if err != nil {
+			log.Error("AdpRouter subscription", zap.Error(err))
+			if wait > DefaultSubscriptionRetry {
+				wait = DefaultSubscriptionRetry
+			}
+			continue
+		}
+		if !changed {
+			continue
+		}
+		result, err := r.Reload()
+		if err != nil {
+			log.Error("AdpRouter reload", zap.Error(err))
+			continue
+		}
+		log.Info("AdpRouter subscription applied", zap.Int("rules", result.Rules),
+			zap.Int("errors", len(result.Errors)))
+	}
+}
+
+// fetchSubscription saves the verified list to filename, changed is false if
+// the list is the same as saved.
+func (r *AdpRouter) fetchSubscription(cc *core.ContextConfig, s *RuleSubscription, filename string) (changed bool, err error) {
+	list, err := fetchThrough(cc, s.Proxy, s.URL, s.Timeout)
+	if err != nil {
+		return false, err
+	}
+
+	if s.ChecksumURL != "" {
+		b, err := fetchThrough(cc, s.Proxy, s.ChecksumURL, s.Timeout)
+		if err != nil {
+			return false, err
+		}
+		fields := bytes.Fields(b)
+		if len(fields) == 0 {
+			return false, ErrSubscriptionChecksum
+		}
+		sum := sha256.Sum256(list)
+		if !bytes.EqualFold(fields[0], []byte(hex.EncodeToString(sum[:]))) {
+			return false, ErrSubscriptionChecksum
+		}
+	}
+
+	if s.SignatureURL != "" {
+		sig, err := fetchThrough(cc, s.Proxy, s.SignatureURL, s.Timeout)
+		if err != nil {
+			return false, err
+		}
+		if len(sig) != ed25519.SignatureSize {
+			sig, err = hex.DecodeString(string(bytes.TrimSpace(sig)))
+			if err != nil {
+				return false, ErrSubscriptionSignature
+			}
+		}
+		if !ed25519.Verify(s.PublicKey, list, sig) {
+			return false, ErrSubscriptionSignature
+		}
+	}
+
+	old, err := ioutil.ReadFile(filename)
+	if err == nil && bytes.Equal(old, list) {
+		// renew modified time for the next start
+		now := time.Now()
+		return false, os.Chtimes(filename, now, now)
+	}
+
+	// rename to not load a partial file
+	tmp := filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	err = ioutil.WriteFile(tmp, list, 0644)
+	if err != nil {
+		return false, err
+	}
+	err = os.Rename(tmp, filename)
+	if err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// fetchThrough requests url by GET through p.
+func fetchThrough(cc *core.ContextConfig, p core.Proxy, url string, timeout time.Duration) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	w := &fetchWriter{header: make(http.Header)}
+	c, err := core.NewContextFromHandler(cc, w, req)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Finish()
+	err = p.Do(c)
+	if err != nil {
+		return nil, err
+	}
+	if w.tooLarge {
+		return nil, ErrSubscriptionTooLarge
+	}
+	if w.code != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: status %d", url, w.code)
+	}
+	return w.body.Bytes(), nil
+}
+
+// fetchWriter keeps the response body up to MaxSubscriptionSize.
+type fetchWriter struct {
+	header   http.Header
+	code     int
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (w *fetchWriter) Header() http.Header { return w.header }
+
+func (w *fetchWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.body.Len()+len(b) > MaxSubscriptionSize {
+		w.tooLarge = true
+		return 0, ErrSubscriptionTooLarge
+	}
+	return w.body.Write(b)
+}
+
+func (w *fetchWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
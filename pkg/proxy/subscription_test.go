@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/empirefox/hybrid/pkg/core"
+)
+
+func TestRuleSubscription(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []byte("||ads.example.com^\n")
+	sum := sha256.Sum256(list)
+	files := map[string][]byte{
+		"/list":     list,
+		"/list.sum": []byte(hex.EncodeToString(sum[:]) + "  list\n"),
+		"/list.sig": []byte(hex.EncodeToString(ed25519.Sign(priv, list))),
+		"/bad.sum":  []byte("00  list\n"),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "adp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rulesDir := filepath.Join(dir, "txt")
+
+	r, err := NewAdpRouter(AdpRouterConfig{
+		Log:        zap.NewNop(),
+		Blocked:    testRouteProxy("blocked"),
+		TxtRuleDir: rulesDir,
+		Subscriptions: []RuleSubscription{{
+			URL:      ts.URL + "/list",
+			FileName: "10-list.txt",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewAdpRouter should be ok before the first fetch, but got: %v", err)
+	}
+	defer r.Close()
+	if r.AdpMatch("http://ads.example.com") {
+		t.Errorf("ads.example.com should not be blocked before fetched")
+	}
+
+	cc := new(core.ContextConfig)
+	filename := filepath.Join(rulesDir, "10-list.txt")
+	sub := &RuleSubscription{
+		URL:          ts.URL + "/list",
+		Proxy:        core.DirectProxy,
+		Timeout:      DefaultSubscriptionTimeout,
+		ChecksumURL:  ts.URL + "/list.sum",
+		SignatureURL: ts.URL + "/list.sig",
+		PublicKey:    pub,
+	}
+	changed, err := r.fetchSubscription(cc, sub, filename)
+	if err != nil || !changed {
+		t.Fatalf("fetchSubscription should be changed, but got: %v %v", changed, err)
+	}
+	saved, _ := ioutil.ReadFile(filename)
+	if string(saved) != string(list) {
+		t.Errorf("list should be saved, but got: %q", saved)
+	}
+	changed, err = r.fetchSubscription(cc, sub, filename)
+	if err != nil || changed {
+		t.Errorf("fetchSubscription of the same list should not be changed, but got: %v %v", changed, err)
+	}
+
+	_, err = r.Reload()
+	if err != nil {
+		t.Fatalf("Reload should be ok, but got: %v", err)
+	}
+	if !r.AdpMatch("http://ads.example.com") {
+		t.Errorf("ads.example.com should be blocked after fetched")
+	}
+
+	bad := *sub
+	bad.ChecksumURL = ts.URL + "/bad.sum"
+	_, err = r.fetchSubscription(cc, &bad, filename)
+	if err != ErrSubscriptionChecksum {
+		t.Errorf("bad checksum should get ErrSubscriptionChecksum, but got: %v", err)
+	}
+
+	bad = *sub
+	bad.PublicKey, _, _ = ed25519.GenerateKey(nil)
+	_, err = r.fetchSubscription(cc, &bad, filename)
+	if err != ErrSubscriptionSignature {
+		t.Errorf("other key should get ErrSubscriptionSignature, but got: %v", err)
+	}
+
+	bad = *sub
+	bad.URL = ts.URL + "/none"
+	_, err = r.fetchSubscription(cc, &bad, filename)
+	if err == nil {
+		t.Errorf("missing list should fail")
+	}
+}
+
+func TestRuleSubscriptionApplied(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("||tracker.example.com^\n"))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "adp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := NewAdpRouter(AdpRouterConfig{
+		Log:        zap.NewNop(),
+		TxtRuleDir: dir,
+		Subscriptions: []RuleSubscription{{
+			URL:      ts.URL,
+			FileName: "10-list.txt",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("NewAdpRouter should be ok, but got: %v", err)
+	}
+	defer r.Close()
+
+	r.Start(new(core.ContextConfig))
+	for i := 0; !r.AdpMatch("http://tracker.example.com"); i++ {
+		if i == 200 {
+			t.Fatal("subscription should be fetched and applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,224 @@
+// Package sniff finds the hostname from the first bytes of TLS or HTTP
+// client streams, without consuming them.
+package sniff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+const (
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+	extensionServerName      = 0x0000
+	serverNameTypeHostName   = 0x00
+
+	// maxHTTPHead limits the request head to sniff.
+	maxHTTPHead = 8 << 10
+
+	// BufferSize buffers the largest TLS record, which is a 16KB fragment
+	// and its header.
+	BufferSize = 5 + 16<<10
+)
+
+var (
+	ErrIncomplete = errors.New("sniff: incomplete")
+	ErrNotMatched = errors.New("sniff: neither TLS nor HTTP")
+	ErrNoHostname = errors.New("sniff: no hostname")
+)
+
+// Hostname peeks br until the hostname of TLS ClientHello or HTTP request is
+// found. All peeked bytes are kept in br. ErrNoHostname is returned if the
+// stream is matched but has no SNI or Host. br should be large enough to
+// buffer a ClientHello, BufferSize is recommended.
+func Hostname(br *bufio.Reader) (string, error) {
+	return peek(br, func(b []byte) (string, error) {
+		if b[0] == recordTypeHandshake {
+			return TLSServerName(b)
+		}
+		return HTTPHost(b)
+	})
+}
+
+// ServerName is Hostname of TLS only.
+func ServerName(br *bufio.Reader) (string, error) {
+	return peek(br, TLSServerName)
+}
+
+// peek peeks more bytes until parse gets no ErrIncomplete.
+func peek(br *bufio.Reader, parse func(b []byte) (string, error)) (string, error) {
+	n := 1
+	for {
+		_, err := br.Peek(n)
+		if err != nil {
+			return "", err
+		}
+		b, _ := br.Peek(br.Buffered())
+
+		host, err := parse(b)
+		if err != ErrIncomplete {
+			return host, err
+		}
+		n = len(b) + 1
+	}
+}
+
+// TLSServerName parses the SNI extension of the ClientHello in the first
+// record of b.
+func TLSServerName(b []byte) (string, error) {
+	// record header: type(1) version(2) length(2)
+	if len(b) > 0 && b[0] != recordTypeHandshake || len(b) > 1 && b[1] != 3 {
+		return "", ErrNotMatched
+	}
+	if len(b) < 5 {
+		return "", ErrIncomplete
+	}
+	length := int(binary.BigEndian.Uint16(b[3:5]))
+	if len(b) < 5+length {
+		return "", ErrIncomplete
+	}
+	b = b[5 : 5+length]
+
+	// handshake header: type(1) length(3)
+	if len(b) < 4 || b[0] != handshakeTypeClientHello {
+		return "", ErrNotMatched
+	}
+	length = int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+	if len(b) < 4+length {
+		// ClientHello spans records, not supported
+		return "", ErrNoHostname
+	}
+	s := reader(b[4 : 4+length])
+
+	// version(2) random(32) session_id<1> cipher_suites<2> compression<1>
+	if !s.skip(2+32) || !s.skipVector(1) || !s.skipVector(2) || !s.skipVector(1) {
+		return "", ErrNotMatched
+	}
+	exts, ok := s.vector(2)
+	if !ok {
+		return "", ErrNoHostname
+	}
+	for len(exts) > 0 {
+		typ, ok1 := exts.uint16()
+		data, ok2 := exts.vector(2)
+		if !ok1 || !ok2 {
+			return "", ErrNotMatched
+		}
+		if typ != extensionServerName {
+			continue
+		}
+		list, ok := data.vector(2)
+		if !ok {
+			return "", ErrNotMatched
+		}
+		for len(list) > 0 {
+			nameType, ok1 := list.uint8()
+			name, ok2 := list.vector(2)
+			if !ok1 || !ok2 {
+				return "", ErrNotMatched
+			}
+			if nameType == serverNameTypeHostName && len(name) > 0 {
+				return string(name), nil
+			}
+		}
+	}
+	return "", ErrNoHostname
+}
+
+// HTTPHost parses the Host header of the request head in b, port is removed.
+func HTTPHost(b []byte) (string, error) {
+	if !isMethod(b) {
+		return "", ErrNotMatched
+	}
+	end := bytes.Index(b, []byte("\r\n\r\n"))
+	if end == -1 {
+		if len(b) >= maxHTTPHead {
+			return "", ErrNotMatched
+		}
+		return "", ErrIncomplete
+	}
+
+	lines := bytes.Split(b[:end], []byte("\r\n"))
+	for _, line := range lines[1:] {
+		i := bytes.IndexByte(line, ':')
+		if i == -1 || !bytes.EqualFold(line[:i], []byte("Host")) {
+			continue
+		}
+		host := string(bytes.TrimSpace(line[i+1:]))
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			break
+		}
+		return host, nil
+	}
+	return "", ErrNoHostname
+}
+
+// isMethod checks b starts with a method token and a space, or may be.
+func isMethod(b []byte) bool {
+	for i, c := range b {
+		if c == ' ' {
+			return i > 0
+		}
+		if c < 'A' || c > 'Z' || i > 16 {
+			return false
+		}
+	}
+	return true
+}
+
+type reader []byte
+
+func (r *reader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+func (r *reader) uint8() (uint8, bool) {
+	if len(*r) < 1 {
+		return 0, false
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v, true
+}
+
+func (r *reader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+// vector reads data prefixed by a lenBytes length.
+func (r *reader) vector(lenBytes int) (reader, bool) {
+	if len(*r) < lenBytes {
+		return nil, false
+	}
+	var n int
+	for _, c := range (*r)[:lenBytes] {
+		n = n<<8 | int(c)
+	}
+	*r = (*r)[lenBytes:]
+	if len(*r) < n {
+		return nil, false
+	}
+	v := (*r)[:n]
+	*r = (*r)[n:]
+	return v, true
+}
+
+func (r *reader) skipVector(lenBytes int) bool {
+	_, ok := r.vector(lenBytes)
+	return ok
+}
@@ -0,0 +1,151 @@
+package sniff
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func clientHello(t *testing.T, serverName string) []byte {
+	client, server := net.Pipe()
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+		client.Close()
+	}()
+	defer server.Close()
+
+	// record header then the record
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(server, head); err != nil {
+		t.Fatalf("ClientHello should be written, but got: %v", err)
+	}
+	body := make([]byte, int(head[3])<<8|int(head[4]))
+	if _, err := io.ReadFull(server, body); err != nil {
+		t.Fatalf("ClientHello should be written, but got: %v", err)
+	}
+	return append(head, body...)
+}
+
+// paddedClientHello pads the ClientHello to the largest record.
+func paddedClientHello(t *testing.T, serverName string) []byte {
+	hello := clientHello(t, serverName)
+	pad := 16<<10 - (len(hello) - 5) - 4
+	hello = append(hello, 0x00, 0x15, byte(pad>>8), byte(pad))
+	hello = append(hello, make([]byte, pad)...)
+
+	add := func(b []byte, n int) {
+		v := int(binary.BigEndian.Uint16(b)) + n
+		binary.BigEndian.PutUint16(b, uint16(v))
+	}
+	add(hello[3:5], 4+pad)
+	// handshake length is 3 bytes, the high byte stays 0
+	add(hello[7:9], 4+pad)
+	i := 5 + 4 + 2 + 32
+	i += 1 + int(hello[i])
+	i += 2 + int(binary.BigEndian.Uint16(hello[i:]))
+	i += 1 + int(hello[i])
+	add(hello[i:i+2], 4+pad)
+	return hello
+}
+
+func TestServerNameLargestRecord(t *testing.T) {
+	hello := paddedClientHello(t, "www.example.com")
+	if len(hello) != BufferSize {
+		t.Fatalf("hello should be the largest record, but got: %d", len(hello))
+	}
+
+	host, err := ServerName(bufio.NewReaderSize(bytes.NewReader(hello), BufferSize))
+	if err != nil || host != "www.example.com" {
+		t.Errorf("ServerName should get www.example.com, but got: %q %v", host, err)
+	}
+
+	_, err = ServerName(bufio.NewReaderSize(bytes.NewReader(hello), 16<<10))
+	if err != bufio.ErrBufferFull {
+		t.Errorf("ServerName with 16KB buffer should get ErrBufferFull, but got: %v", err)
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	hello := clientHello(t, "www.example.com")
+
+	host, err := TLSServerName(hello)
+	if err != nil {
+		t.Fatalf("TLSServerName should get no err, but got: %v", err)
+	}
+	if host != "www.example.com" {
+		t.Errorf("TLSServerName should get www.example.com, but got: %s", host)
+	}
+
+	_, err = TLSServerName(hello[:len(hello)-1])
+	if err != ErrIncomplete {
+		t.Errorf("TLSServerName should get ErrIncomplete, but got: %v", err)
+	}
+
+	_, err = TLSServerName([]byte("G"))
+	if err != ErrNotMatched {
+		t.Errorf("TLSServerName should get ErrNotMatched of HTTP, but got: %v", err)
+	}
+
+	_, err = TLSServerName(clientHello(t, "127.0.0.1"))
+	if err != ErrNoHostname {
+		t.Errorf("TLSServerName should get ErrNoHostname without SNI, but got: %v", err)
+	}
+}
+
+func TestHTTPHost(t *testing.T) {
+	head := []byte("GET /index.html HTTP/1.1\r\nUser-Agent: test\r\nhost: a.over.b.hybrid:8080\r\n\r\n")
+
+	host, err := HTTPHost(head)
+	if err != nil {
+		t.Fatalf("HTTPHost should get no err, but got: %v", err)
+	}
+	if host != "a.over.b.hybrid" {
+		t.Errorf("HTTPHost should get a.over.b.hybrid, but got: %s", host)
+	}
+
+	_, err = HTTPHost(head[:20])
+	if err != ErrIncomplete {
+		t.Errorf("HTTPHost should get ErrIncomplete, but got: %v", err)
+	}
+
+	_, err = HTTPHost([]byte("SSH-2.0-OpenSSH\r\n"))
+	if err != ErrNotMatched {
+		t.Errorf("HTTPHost should get ErrNotMatched, but got: %v", err)
+	}
+
+	_, err = HTTPHost([]byte("GET / HTTP/1.0\r\n\r\n"))
+	if err != ErrNoHostname {
+		t.Errorf("HTTPHost should get ErrNoHostname, but got: %v", err)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	hello := clientHello(t, "www.example.com")
+	client, server := net.Pipe()
+	go func() {
+		// written in pieces
+		client.Write(hello[:3])
+		client.Write(hello[3:100])
+		client.Write(hello[100:])
+		client.Close()
+	}()
+
+	br := bufio.NewReaderSize(server, BufferSize)
+	host, err := Hostname(br)
+	if err != nil {
+		t.Fatalf("Hostname should get no err, but got: %v", err)
+	}
+	if host != "www.example.com" {
+		t.Errorf("Hostname should get www.example.com, but got: %s", host)
+	}
+
+	all, _ := ioutil.ReadAll(br)
+	if !bytes.Equal(all, hello) {
+		t.Errorf("Hostname should keep peeked bytes, but got %d bytes", len(all))
+	}
+}
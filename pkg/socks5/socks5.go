@@ -0,0 +1,217 @@
+// Package socks5 implements the server side of RFC 1928 and RFC 1929,
+// only CONNECT is supported.
+package socks5
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	Version = 0x05
+
+	MethodNoAuth       = 0x00
+	MethodUserPass     = 0x02
+	MethodNoAcceptable = 0xff
+
+	UserPassVersion = 0x01
+
+	CmdConnect   = 0x01
+	CmdBind      = 0x02
+	CmdAssociate = 0x03
+
+	AddrIPv4   = 0x01
+	AddrDomain = 0x03
+	AddrIPv6   = 0x04
+)
+
+const (
+	RepSucceeded           = 0x00
+	RepGeneralFailure      = 0x01
+	RepNotAllowed          = 0x02
+	RepNetworkUnreachable  = 0x03
+	RepHostUnreachable     = 0x04
+	RepConnectionRefused   = 0x05
+	RepTTLExpired          = 0x06
+	RepCommandNotSupported = 0x07
+	RepAddressNotSupported = 0x08
+)
+
+var (
+	ErrVersion          = errors.New("socks5: bad version")
+	ErrNoMethods        = errors.New("socks5: no acceptable methods")
+	ErrAuthFailed       = errors.New("socks5: username/password authentication failed")
+	ErrCommand          = errors.New("socks5: command not supported")
+	ErrAddressType      = errors.New("socks5: address type not supported")
+	ErrUserPassVersion  = errors.New("socks5: bad username/password version")
+	ErrEmptyDestination = errors.New("socks5: empty destination")
+)
+
+// VerifyFunc checks username and password of RFC 1929.
+type VerifyFunc func(username, password []byte) bool
+
+// Server reads the SOCKS5 handshake from client conns.
+type Server struct {
+	// Verify enables username/password authentication if not nil.
+	Verify VerifyFunc
+}
+
+// Handshake negotiates method, authenticates and reads the CONNECT request.
+// It returns the requested host:port. The success reply is not written,
+// caller should write Reply(RepSucceeded) after the remote is ready.
+// Failure replies are written before any error returned after negotiation.
+func (s *Server) Handshake(rw io.ReadWriter) (hostport string, err error) {
+	err = s.negotiate(rw)
+	if err != nil {
+		return "", err
+	}
+
+	// +----+-----+-------+------+----------+----------+
+	// |VER | CMD |  RSV  | ATYP | DST.ADDR | DST.PORT |
+	// +----+-----+-------+------+----------+----------+
+	var head [4]byte
+	if _, err = io.ReadFull(rw, head[:]); err != nil {
+		return "", err
+	}
+	if head[0] != Version {
+		return "", ErrVersion
+	}
+
+	host, err := readAddr(rw, head[3])
+	if err == ErrAddressType {
+		rw.Write(Reply(RepAddressNotSupported))
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var port [2]byte
+	if _, err = io.ReadFull(rw, port[:]); err != nil {
+		return "", err
+	}
+
+	if head[1] != CmdConnect {
+		rw.Write(Reply(RepCommandNotSupported))
+		return "", ErrCommand
+	}
+	if host == "" {
+		rw.Write(Reply(RepGeneralFailure))
+		return "", ErrEmptyDestination
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+func (s *Server) negotiate(rw io.ReadWriter) error {
+	// +----+----------+----------+
+	// |VER | NMETHODS | METHODS  |
+	// +----+----------+----------+
+	var head [2]byte
+	if _, err := io.ReadFull(rw, head[:]); err != nil {
+		return err
+	}
+	if head[0] != Version {
+		return ErrVersion
+	}
+
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(rw, methods); err != nil {
+		return err
+	}
+
+	want := byte(MethodNoAuth)
+	if s.Verify != nil {
+		want = MethodUserPass
+	}
+
+	for _, m := range methods {
+		if m == want {
+			if _, err := rw.Write([]byte{Version, want}); err != nil {
+				return err
+			}
+			if want == MethodUserPass {
+				return s.authenticate(rw)
+			}
+			return nil
+		}
+	}
+
+	rw.Write([]byte{Version, MethodNoAcceptable})
+	return ErrNoMethods
+}
+
+func (s *Server) authenticate(rw io.ReadWriter) error {
+	// +----+------+----------+------+----------+
+	// |VER | ULEN |  UNAME   | PLEN |  PASSWD  |
+	// +----+------+----------+------+----------+
+	var ver [1]byte
+	if _, err := io.ReadFull(rw, ver[:]); err != nil {
+		return err
+	}
+	if ver[0] != UserPassVersion {
+		return ErrUserPassVersion
+	}
+
+	username, err := readBytes(rw)
+	if err != nil {
+		return err
+	}
+	password, err := readBytes(rw)
+	if err != nil {
+		return err
+	}
+
+	if !s.Verify(username, password) {
+		rw.Write([]byte{UserPassVersion, 0x01})
+		return ErrAuthFailed
+	}
+	_, err = rw.Write([]byte{UserPassVersion, 0x00})
+	return err
+}
+
+func readAddr(r io.Reader, atyp byte) (string, error) {
+	switch atyp {
+	case AddrIPv4:
+		ip := make(net.IP, net.IPv4len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		return ip.String(), nil
+	case AddrIPv6:
+		ip := make(net.IP, net.IPv6len)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		return ip.String(), nil
+	case AddrDomain:
+		b, err := readBytes(r)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", ErrAddressType
+	}
+}
+
+// readBytes reads one byte length, then the content.
+func readBytes(r io.Reader) ([]byte, error) {
+	var n [1]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n[0])
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reply returns reply with zero bind address, since clients rarely use it.
+func Reply(rep byte) []byte {
+	return []byte{Version, rep, 0x00, AddrIPv4, 0, 0, 0, 0, 0, 0}
+}
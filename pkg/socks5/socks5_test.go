@@ -0,0 +1,102 @@
+package socks5
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func handshake(s *Server, in []byte) (string, []byte, error) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	type result struct {
+		hostport string
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		hostport, err := s.Handshake(server)
+		server.Close()
+		done <- result{hostport, err}
+	}()
+
+	go client.Write(in)
+	out, _ := ioutil.ReadAll(client)
+	r := <-done
+	return r.hostport, out, r.err
+}
+
+func TestHandshakeNoAuth(t *testing.T) {
+	in := []byte{Version, 1, MethodNoAuth}
+	in = append(in, Version, CmdConnect, 0, AddrDomain, 15)
+	in = append(in, "a.over.b.hybrid"...)
+	in = append(in, 0x01, 0xbb)
+
+	hostport, out, err := handshake(&Server{}, in)
+	if err != nil {
+		t.Fatalf("Handshake should get no err, but got: %v", err)
+	}
+	if hostport != "a.over.b.hybrid:443" {
+		t.Errorf("Handshake should get a.over.b.hybrid:443, but got: %s", hostport)
+	}
+	if !bytes.Equal(out, []byte{Version, MethodNoAuth}) {
+		t.Errorf("Handshake should select no auth, but got: %v", out)
+	}
+}
+
+func TestHandshakeUserPass(t *testing.T) {
+	s := &Server{
+		Verify: func(username, password []byte) bool {
+			return string(username) == "u" && string(password) == "p"
+		},
+	}
+
+	in := []byte{Version, 2, MethodNoAuth, MethodUserPass}
+	in = append(in, UserPassVersion, 1, 'u', 1, 'p')
+	in = append(in, Version, CmdConnect, 0, AddrIPv4, 127, 0, 0, 1, 0, 80)
+
+	hostport, out, err := handshake(s, in)
+	if err != nil {
+		t.Fatalf("Handshake should get no err, but got: %v", err)
+	}
+	if hostport != "127.0.0.1:80" {
+		t.Errorf("Handshake should get 127.0.0.1:80, but got: %s", hostport)
+	}
+	if !bytes.Equal(out, []byte{Version, MethodUserPass, UserPassVersion, 0}) {
+		t.Errorf("Handshake should auth ok, but got: %v", out)
+	}
+
+	in = []byte{Version, 1, MethodUserPass, UserPassVersion, 1, 'u', 1, 'x'}
+	_, out, err = handshake(s, in)
+	if err != ErrAuthFailed {
+		t.Fatalf("Handshake should get ErrAuthFailed, but got: %v", err)
+	}
+	if !bytes.Equal(out, []byte{Version, MethodUserPass, UserPassVersion, 1}) {
+		t.Errorf("Handshake should reply auth failure, but got: %v", out)
+	}
+
+	in = []byte{Version, 1, MethodNoAuth}
+	_, out, err = handshake(s, in)
+	if err != ErrNoMethods {
+		t.Fatalf("Handshake should get ErrNoMethods, but got: %v", err)
+	}
+	if !bytes.Equal(out, []byte{Version, MethodNoAcceptable}) {
+		t.Errorf("Handshake should reply no acceptable, but got: %v", out)
+	}
+}
+
+func TestHandshakeCommand(t *testing.T) {
+	in := []byte{Version, 1, MethodNoAuth}
+	in = append(in, Version, CmdAssociate, 0, AddrIPv4, 0, 0, 0, 0, 0, 0)
+
+	_, out, err := handshake(&Server{}, in)
+	if err != ErrCommand {
+		t.Fatalf("Handshake should get ErrCommand, but got: %v", err)
+	}
+	want := append([]byte{Version, MethodNoAuth}, Reply(RepCommandNotSupported)...)
+	if !bytes.Equal(out, want) {
+		t.Errorf("Handshake should reply command not supported, but got: %v", out)
+	}
+}
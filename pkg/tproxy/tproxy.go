@@ -0,0 +1,94 @@
+// Package tproxy accepts conns redirected by iptables REDIRECT or TPROXY,
+// and recovers their destinations.
+package tproxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/sniff"
+)
+
+const (
+	// BufferSize is the reader size that buffers a ClientHello.
+	BufferSize = sniff.BufferSize
+
+	DefaultSniffTimeout = 300 * time.Millisecond
+)
+
+var (
+	ErrNotSupported  = errors.New("tproxy: not supported on this platform")
+	ErrNotRedirected = errors.New("tproxy: conn is not redirected")
+)
+
+// OriginalDstFunc recovers the destination of a redirected conn.
+type OriginalDstFunc func(conn net.Conn) (*net.TCPAddr, error)
+
+// LocalAddr is the OriginalDstFunc of TPROXY, which keeps the destination as
+// the local address.
+func LocalAddr(conn net.Conn) (*net.TCPAddr, error) {
+	addr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return nil, ErrNotRedirected
+	}
+	return addr, nil
+}
+
+// Server reads the destination of conns accepted by a transparent listener.
+type Server struct {
+	// OriginalDst defaults to OriginalDst, that is REDIRECT mode.
+	OriginalDst OriginalDstFunc
+
+	// Listen is the listening address, conns to it are not redirected.
+	Listen net.Addr
+
+	// SniffTimeout waits for client first bytes, server first protocols fall
+	// back to the destination IP after it. Zero means DefaultSniffTimeout.
+	SniffTimeout time.Duration
+}
+
+// Handshake returns host:port of the hostname sniffed from HTTP Host or TLS
+// SNI, or the original IP:port. The returned reader must be used to read conn,
+// it keeps the sniffed bytes.
+func (s *Server) Handshake(conn net.Conn) (hostport string, br *bufio.Reader, err error) {
+	originalDst := s.OriginalDst
+	if originalDst == nil {
+		originalDst = OriginalDst
+	}
+	dst, err := originalDst(conn)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.Listen != nil && isSameAddr(dst, s.Listen) {
+		return "", nil, ErrNotRedirected
+	}
+
+	timeout := s.SniffTimeout
+	if timeout == 0 {
+		timeout = DefaultSniffTimeout
+	}
+	br = bufio.NewReaderSize(conn, BufferSize)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	host, err := sniff.Hostname(br)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && !ne.Timeout() || err == io.EOF {
+			return "", nil, err
+		}
+		// not sniffed, or server first
+		host = dst.IP.String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(dst.Port)), br, nil
+}
+
+func isSameAddr(dst *net.TCPAddr, listen net.Addr) bool {
+	ln, ok := listen.(*net.TCPAddr)
+	if !ok || dst.Port != ln.Port {
+		return false
+	}
+	return ln.IP.IsUnspecified() || ln.IP.Equal(dst.IP)
+}
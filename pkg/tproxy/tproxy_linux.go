@@ -0,0 +1,84 @@
+package tproxy
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	soOriginalDst     = 80 // SO_ORIGINAL_DST of netfilter_ipv4.h
+	ip6tSoOriginalDst = 80 // IP6T_SO_ORIGINAL_DST of ip6_tables.h
+	ipv6Transparent   = 75 // IPV6_TRANSPARENT of in6.h
+)
+
+// OriginalDst reads SO_ORIGINAL_DST of conn redirected by iptables REDIRECT.
+func OriginalDst(conn net.Conn) (*net.TCPAddr, error) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, ErrNotRedirected
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	local, _ := conn.LocalAddr().(*net.TCPAddr)
+	var dst *net.TCPAddr
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		if local != nil && local.IP.To4() == nil {
+			// struct sockaddr_in6 fits in ipv6_mtuinfo
+			var info *syscall.IPv6MTUInfo
+			info, serr = syscall.GetsockoptIPv6MTUInfo(int(fd), syscall.IPPROTO_IPV6, ip6tSoOriginalDst)
+			if serr == nil {
+				// port is in network byte order
+				port := (*[2]byte)(unsafe.Pointer(&info.Addr.Port))
+				dst = &net.TCPAddr{
+					IP:   append(net.IP(nil), info.Addr.Addr[:]...),
+					Port: int(port[0])<<8 | int(port[1]),
+				}
+			}
+			return
+		}
+		// struct sockaddr_in fits in ipv6_mreq
+		var mreq *syscall.IPv6Mreq
+		mreq, serr = syscall.GetsockoptIPv6Mreq(int(fd), syscall.IPPROTO_IP, soOriginalDst)
+		if serr == nil {
+			b := mreq.Multiaddr
+			dst = &net.TCPAddr{
+				IP:   net.IPv4(b[4], b[5], b[6], b[7]),
+				Port: int(b[2])<<8 | int(b[3]),
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return dst, nil
+}
+
+// Listen listens with IP_TRANSPARENT, which is required by iptables TPROXY.
+// Conns accepted should use LocalAddr as OriginalDstFunc.
+func Listen(network, address string) (net.Listener, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				serr = syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_TRANSPARENT, 1)
+				if serr == nil && network == "tcp6" {
+					serr = syscall.SetsockoptInt(int(fd), syscall.SOL_IPV6, ipv6Transparent, 1)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	return lc.Listen(context.Background(), network, address)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package tproxy
+
+import "net"
+
+// OriginalDst is only supported on linux.
+func OriginalDst(conn net.Conn) (*net.TCPAddr, error) { return nil, ErrNotSupported }
+
+// Listen is only supported on linux.
+func Listen(network, address string) (net.Listener, error) { return nil, ErrNotSupported }
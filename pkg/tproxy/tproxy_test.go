@@ -0,0 +1,160 @@
+package tproxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/empirefox/hybrid/pkg/bufpool"
+	"github.com/empirefox/hybrid/pkg/core"
+	"github.com/empirefox/hybrid/pkg/tproxy"
+)
+
+// serveTransparent accepts one conn of ln, which is faked to be redirected
+// from dst.
+func serveTransparent(t *testing.T, ln net.Listener, dst *net.TCPAddr, sniffTimeout time.Duration) <-chan string {
+	s := &tproxy.Server{
+		OriginalDst:  func(net.Conn) (*net.TCPAddr, error) { return dst, nil },
+		Listen:       ln.Addr(),
+		SniffTimeout: sniffTimeout,
+	}
+	cc := &core.ContextConfig{
+		Transport:     http.DefaultTransport,
+		BufferPool:    bufpool.Default,
+		FlushInterval: 100 * time.Millisecond,
+	}
+	co := &core.Core{ContextConfig: cc}
+
+	hostports := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("Accept should get no err, but got: %v", err)
+			close(hostports)
+			return
+		}
+		defer conn.Close()
+		hostport, br, err := s.Handshake(conn)
+		hostports <- hostport
+		if err != nil {
+			t.Errorf("Handshake should get no err, but got: %v", err)
+			return
+		}
+		c, err := core.NewContextWithTransparent(cc, conn, br, hostport)
+		if err != nil {
+			t.Errorf("NewContextWithTransparent should get no err, but got: %v", err)
+			return
+		}
+		co.Proxy(c)
+	}()
+	return hostports
+}
+
+func TestTransparentHTTP(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	defer target.Close()
+	go http.Serve(target, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.Path)
+	}))
+	dst := target.Addr().(*net.TCPAddr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	defer ln.Close()
+	hostports := serveTransparent(t, ln, dst, time.Second)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /index.html HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+
+	want := fmt.Sprintf("localhost:%d", dst.Port)
+	if hostport := <-hostports; hostport != want {
+		t.Errorf("Handshake should sniff %s, but got: %s", want, hostport)
+	}
+
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse should get no err, but got: %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	if string(body) != "localhost /index.html" {
+		t.Errorf("Response should be replied by target, but got: %s", body)
+	}
+}
+
+func TestTransparentServerFirst(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("SSH-2.0-Test\r\n"))
+		conn.Close()
+	}()
+	dst := target.Addr().(*net.TCPAddr)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	defer ln.Close()
+	hostports := serveTransparent(t, ln, dst, 50*time.Millisecond)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+
+	if hostport := <-hostports; hostport != dst.String() {
+		t.Errorf("Handshake should fall back to %s, but got: %s", dst, hostport)
+	}
+	banner, _ := ioutil.ReadAll(conn)
+	if !strings.HasPrefix(string(banner), "SSH-2.0-") {
+		t.Errorf("Server first bytes should be copied, but got: %q", banner)
+	}
+}
+
+func TestNotRedirected(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen should get no err, but got: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("Accept should get no err, but got: %v", err)
+	}
+	defer conn.Close()
+	s := &tproxy.Server{OriginalDst: tproxy.LocalAddr, Listen: ln.Addr()}
+	_, _, err = s.Handshake(conn)
+	if err != tproxy.ErrNotRedirected {
+		t.Errorf("Handshake should get ErrNotRedirected, but got: %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package zipfs
 
 import (
 	"archive/zip"
+	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
 	"os"
@@ -78,6 +80,30 @@ func (hfs *GzipHttpfs) CanRequest(path string) bool {
 	return err == nil
 }
 
+// ReadFile reads the whole ungzipped content of path.
+func (hfs *GzipHttpfs) ReadFile(path string) ([]byte, error) {
+	name, raw := hfs.gfs.gz(path)
+	f, err := hfs.gfs.FileSystem.Open(name)
+	if os.IsNotExist(err) && !raw {
+		raw = true
+		f, err = hfs.gfs.FileSystem.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if raw {
+		return ioutil.ReadAll(f)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
 func (hfs *GzipHttpfs) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	info, ok, ctype := hfs.contentInfoType(req.URL.Path)
 	w.Header().Set("Content-Type", ctype)